@@ -0,0 +1,146 @@
+// Package audio cuts and caches the preview clips played in preview games.
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// ErrNoFrames is returned for data that holds no MPEG audio frames.
+var ErrNoFrames = errors.New("no MP3 frames found")
+
+// Layer III bitrates in kbit/s by bitrate index, for MPEG-1 and for MPEG-2
+// and 2.5. Index 0 is the free format, which is not supported.
+var (
+	bitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	bitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+)
+
+// Sample rates in Hz by sample rate index, for each MPEG version.
+var (
+	sampleRatesV1  = [4]int{44100, 48000, 32000, 0}
+	sampleRatesV2  = [4]int{22050, 24000, 16000, 0}
+	sampleRatesV25 = [4]int{11025, 12000, 8000, 0}
+)
+
+// frame is an MPEG Layer III frame header.
+type frame struct {
+	length     int
+	samples    int
+	sampleRate int
+	// sideInfo is the length of the side information after the header,
+	// where a Xing or Info header would start.
+	sideInfo int
+}
+
+// duration returns how long the frame plays for.
+func (f frame) duration() time.Duration {
+	return time.Duration(f.samples) * time.Second / time.Duration(f.sampleRate)
+}
+
+// parseFrame parses the frame header at the start of b, returning false if
+// there is no valid Layer III header there.
+func parseFrame(b []byte) (frame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return frame{}, false
+	}
+
+	version := (b[1] >> 3) & 0x03
+	layer := (b[1] >> 1) & 0x03
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 0x03
+	padding := int((b[2] >> 1) & 0x01)
+	mono := b[3]>>6 == 0x03
+
+	if layer != 0x01 || version == 0x01 {
+		return frame{}, false
+	}
+
+	var f frame
+	switch version {
+	case 0x03:
+		f.sampleRate = sampleRatesV1[sampleRateIndex]
+		bitrate := bitratesV1[bitrateIndex] * 1000
+		f.samples = 1152
+		f.sideInfo = 32
+		if mono {
+			f.sideInfo = 17
+		}
+		if bitrate == 0 || f.sampleRate == 0 {
+			return frame{}, false
+		}
+		f.length = 144*bitrate/f.sampleRate + padding
+	default:
+		f.sampleRate = sampleRatesV2[sampleRateIndex]
+		if version == 0x00 {
+			f.sampleRate = sampleRatesV25[sampleRateIndex]
+		}
+		bitrate := bitratesV2[bitrateIndex] * 1000
+		f.samples = 576
+		f.sideInfo = 17
+		if mono {
+			f.sideInfo = 9
+		}
+		if bitrate == 0 || f.sampleRate == 0 {
+			return frame{}, false
+		}
+		f.length = 72*bitrate/f.sampleRate + padding
+	}
+	return f, true
+}
+
+// isInfoFrame reports whether the frame at the start of b carries a Xing or
+// Info header, which describes the whole file rather than holding audio.
+func isInfoFrame(b []byte, f frame) bool {
+	offset := 4 + f.sideInfo
+	if len(b) < offset+4 {
+		return false
+	}
+	tag := b[offset : offset+4]
+	return bytes.Equal(tag, []byte("Xing")) || bytes.Equal(tag, []byte("Info"))
+}
+
+// skipID3v2 returns the offset of the data after an ID3v2 tag at the start
+// of b, or 0 if there is none.
+func skipID3v2(b []byte) int {
+	if len(b) < 10 || !bytes.Equal(b[:3], []byte("ID3")) {
+		return 0
+	}
+	size := int(b[6]&0x7F)<<21 | int(b[7]&0x7F)<<14 | int(b[8]&0x7F)<<7 | int(b[9]&0x7F)
+	offset := 10 + size
+	if b[5]&0x10 != 0 {
+		offset += 10 // footer
+	}
+	return min(offset, len(b))
+}
+
+// Clip returns the whole MP3 frames at the start of data that play for at
+// least limit, or all of them if data is shorter. Tags and Xing headers are
+// left out, so the clip carries nothing about the track it was cut from.
+// Bytes between frames are skipped.
+func Clip(data []byte, limit time.Duration) ([]byte, error) {
+	clip := make([]byte, 0)
+	var played time.Duration
+	found := false
+
+	for i := skipID3v2(data); i < len(data) && played < limit; {
+		f, ok := parseFrame(data[i:])
+		if !ok || i+f.length > len(data) {
+			i++
+			continue
+		}
+
+		if !isInfoFrame(data[i:], f) {
+			clip = append(clip, data[i:i+f.length]...)
+			played += f.duration()
+			found = true
+		}
+		i += f.length
+	}
+
+	if !found {
+		return nil, ErrNoFrames
+	}
+	return clip, nil
+}
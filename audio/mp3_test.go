@@ -0,0 +1,94 @@
+// Package audio cuts and caches the preview clips played in preview games.
+package audio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testFrameLength is the length of an MPEG-1 Layer III frame at 128 kbit/s
+// and 44.1 kHz without padding, which plays for 1152 samples, about 26ms.
+const testFrameLength = 417
+
+// testFrame returns a silent 128 kbit/s, 44.1 kHz stereo frame.
+func testFrame() []byte {
+	frame := make([]byte, testFrameLength)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return frame
+}
+
+// testMP3 returns an MP3 of n frames after an ID3v2 tag and a Xing frame.
+func testMP3(n int) []byte {
+	var data bytes.Buffer
+	data.Write([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20})
+	data.Write(bytes.Repeat([]byte("T"), 20))
+
+	xing := testFrame()
+	copy(xing[4+32:], "Xing")
+	data.Write(xing)
+
+	for i := 0; i < n; i++ {
+		data.Write(testFrame())
+	}
+	return data.Bytes()
+}
+
+func TestClip(t *testing.T) {
+	data := testMP3(200)
+
+	// One second takes 39 frames of 1152 samples at 44.1 kHz.
+	clip, err := Clip(data, time.Second)
+	if err != nil {
+		t.Fatalf("Clip() failed: %v", err)
+	}
+	if len(clip) != 39*testFrameLength {
+		t.Errorf("Clip() length = %d, want %d", len(clip), 39*testFrameLength)
+	}
+	if !bytes.Equal(clip[:testFrameLength], testFrame()) {
+		t.Error("Clip() does not start with the first audio frame")
+	}
+
+	clip, err = Clip(data, time.Minute)
+	if err != nil || len(clip) != 200*testFrameLength {
+		t.Errorf("Clip() longer than the data = %d bytes, %v", len(clip), err)
+	}
+}
+
+func TestClipSkipsJunk(t *testing.T) {
+	data := append([]byte{0x00, 0xFF, 0x12}, testFrame()...)
+	data = append(data, testFrame()[:100]...)
+
+	clip, err := Clip(data, time.Second)
+	if err != nil || len(clip) != testFrameLength {
+		t.Errorf("Clip() = %d bytes, %v, want one frame", len(clip), err)
+	}
+}
+
+func TestClipNoFrames(t *testing.T) {
+	if _, err := Clip([]byte("<html>Not found</html>"), time.Second); err != ErrNoFrames {
+		t.Errorf("Clip() error = %v, want %v", err, ErrNoFrames)
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   frame
+		ok     bool
+	}{
+		{"MPEG-1 with padding", []byte{0xFF, 0xFB, 0x92, 0x00}, frame{length: 418, samples: 1152, sampleRate: 44100, sideInfo: 32}, true},
+		{"MPEG-2 mono", []byte{0xFF, 0xF3, 0x80, 0xC0}, frame{length: 208, samples: 576, sampleRate: 22050, sideInfo: 9}, true},
+		{"layer II", []byte{0xFF, 0xFD, 0x90, 0x00}, frame{}, false},
+		{"free format", []byte{0xFF, 0xFB, 0x00, 0x00}, frame{}, false},
+		{"no sync", []byte{0xFF, 0x00, 0x90, 0x00}, frame{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseFrame(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: parseFrame() = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
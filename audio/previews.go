@@ -0,0 +1,115 @@
+// Package audio cuts and caches the preview clips played in preview games.
+package audio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultPreviewTTL is how long a downloaded preview is kept. A game asks for
+// the same preview again with every guess.
+const DefaultPreviewTTL = 10 * time.Minute
+
+// maxPreviewEntries is the cache size above which expired previews are swept
+// out when a new one is added.
+const maxPreviewEntries = 200
+
+// maxPreviewBytes caps the size of a preview download. Spotify's 30 second
+// previews are well under a megabyte.
+const maxPreviewBytes = 2 << 20
+
+// previewTimeout bounds a preview download, so that a stalled connection to
+// the preview host cannot hold up the clip request for good.
+const previewTimeout = 10 * time.Second
+
+// Previews downloads preview MP3s and keeps them for a while, so that the
+// clips of a game can be cut without fetching the preview on every guess.
+// It is safe for concurrent use.
+type Previews struct {
+	ttl     time.Duration
+	now     func() time.Time
+	client  *http.Client
+	entries map[string]previewEntry
+	mu      sync.Mutex
+}
+
+type previewEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewPreviews creates a preview cache whose downloads expire after ttl.
+func NewPreviews(ttl time.Duration) *Previews {
+	return &Previews{
+		ttl:     ttl,
+		now:     time.Now,
+		client:  &http.Client{Timeout: previewTimeout},
+		entries: make(map[string]previewEntry),
+	}
+}
+
+// Get returns the preview MP3 at url, downloading it unless it is cached.
+// Failed downloads are not cached, and a download is abandoned when ctx is
+// done. The returned data must not be modified.
+func (p *Previews) Get(ctx context.Context, url string) ([]byte, error) {
+	p.mu.Lock()
+	entry, ok := p.entries[url]
+	p.mu.Unlock()
+	if ok && p.now().Before(entry.expiresAt) {
+		return entry.data, nil
+	}
+
+	data, err := p.download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.store(url, data)
+	p.mu.Unlock()
+	return data, nil
+}
+
+// store adds an entry, first sweeping out expired entries if the cache has
+// grown large. The caller must hold p.mu.
+func (p *Previews) store(url string, data []byte) {
+	now := p.now()
+	if len(p.entries) >= maxPreviewEntries {
+		for k, entry := range p.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(p.entries, k)
+			}
+		}
+	}
+	p.entries[url] = previewEntry{data: data, expiresAt: now.Add(p.ttl)}
+}
+
+func (p *Previews) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("downloading preview: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading preview: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading preview: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPreviewBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading preview: %w", err)
+	}
+	if len(data) > maxPreviewBytes {
+		return nil, fmt.Errorf("preview is larger than %d bytes", maxPreviewBytes)
+	}
+	return data, nil
+}
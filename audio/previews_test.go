@@ -0,0 +1,76 @@
+// Package audio cuts and caches the preview clips played in preview games.
+package audio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPreviewsGet(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(testMP3(1))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	previews := NewPreviews(time.Minute)
+	previews.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		data, err := previews.Get(context.Background(), server.URL+"/preview")
+		if err != nil || len(data) != len(testMP3(1)) {
+			t.Fatalf("Get() = %d bytes, %v", len(data), err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want the preview downloaded once", requests)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := previews.Get(context.Background(), server.URL+"/preview"); err != nil || requests != 2 {
+		t.Errorf("Get() after expiry: requests = %d, %v", requests, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := previews.Get(context.Background(), server.URL+"/missing"); err == nil {
+			t.Error("Get() for a missing preview did not fail")
+		}
+	}
+	if requests != 4 {
+		t.Errorf("requests = %d, want failed downloads not cached", requests)
+	}
+}
+
+func TestPreviewsGetTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	previews := NewPreviews(time.Minute)
+	previews.client.Timeout = 50 * time.Millisecond
+	if _, err := previews.Get(context.Background(), server.URL+"/preview"); err == nil {
+		t.Error("Get() from a stalled server did not fail")
+	}
+
+	previews = NewPreviews(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := previews.Get(ctx, server.URL+"/preview"); err == nil {
+		t.Error("Get() after the request was canceled did not fail")
+	}
+}
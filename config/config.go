@@ -3,7 +3,16 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
+	"strings"
+)
+
+// Log output formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
 )
 
 // Config holds application configuration.
@@ -13,6 +22,21 @@ type Config struct {
 	SpotifyRedirectURI  string
 	SessionSecret       string
 	Port                string
+	// BaseURL is the scheme and host the app is served from, such as
+	// https://heardle.example.com, used for links that leave the app like
+	// share permalinks. It comes from BASE_URL, or from the host of
+	// SPOTIFY_REDIRECT_URI, and never from the request.
+	BaseURL string
+	// DebugAddr is the address, from DEBUG_ADDR, of a separate listener
+	// serving runtime counters at /debug/vars, such as 127.0.0.1:6060. It is
+	// empty, and the counters are not served, unless set. It should not be
+	// reachable from outside, since the counters include the command line.
+	DebugAddr string
+	// LogLevel is the least severe level logged, from LOG_LEVEL: debug,
+	// info, warn or error. Spotify requests are logged at debug level.
+	LogLevel slog.Level
+	// LogFormat is LogFormatText or LogFormatJSON, from LOG_FORMAT.
+	LogFormat string
 }
 
 // Load reads configuration from environment variables.
@@ -42,11 +66,54 @@ func Load() (*Config, error) {
 		port = "8080"
 	}
 
+	baseURL, err := loadBaseURL(redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var logLevel slog.Level
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error: %w", err)
+		}
+	}
+
+	logFormat := os.Getenv("LOG_FORMAT")
+	switch logFormat {
+	case "":
+		logFormat = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT must be %s or %s", LogFormatText, LogFormatJSON)
+	}
+
 	return &Config{
 		SpotifyClientID:     clientID,
 		SpotifyClientSecret: clientSecret,
 		SpotifyRedirectURI:  redirectURI,
 		SessionSecret:       sessionSecret,
 		Port:                port,
+		BaseURL:             baseURL,
+		DebugAddr:           os.Getenv("DEBUG_ADDR"),
+		LogLevel:            logLevel,
+		LogFormat:           logFormat,
 	}, nil
 }
+
+// loadBaseURL returns BASE_URL, or the scheme and host of redirectURI when it
+// is unset, without a trailing slash.
+func loadBaseURL(redirectURI string) (string, error) {
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("BASE_URL must be an http or https URL")
+		}
+		return strings.TrimSuffix(baseURL, "/"), nil
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("SPOTIFY_REDIRECT_URI must be an absolute URL, or BASE_URL must be set")
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
@@ -2,6 +2,7 @@
 package config
 
 import (
+	"log/slog"
 	"testing"
 )
 
@@ -11,6 +12,7 @@ func TestLoad(t *testing.T) {
 	t.Setenv("SPOTIFY_REDIRECT_URI", "http://localhost:8080/callback")
 	t.Setenv("SESSION_SECRET", "test_session_secret")
 	t.Setenv("PORT", "3000")
+	t.Setenv("DEBUG_ADDR", "127.0.0.1:6060")
 
 	cfg, err := Load()
 	if err != nil {
@@ -36,6 +38,10 @@ func TestLoad(t *testing.T) {
 	if cfg.Port != "3000" {
 		t.Errorf("Port = %q, want %q", cfg.Port, "3000")
 	}
+
+	if cfg.DebugAddr != "127.0.0.1:6060" {
+		t.Errorf("DebugAddr = %q, want %q", cfg.DebugAddr, "127.0.0.1:6060")
+	}
 }
 
 func TestLoadDefaults(t *testing.T) {
@@ -52,6 +58,67 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Port != "8080" {
 		t.Errorf("Port = %q, want default %q", cfg.Port, "8080")
 	}
+
+	if cfg.DebugAddr != "" {
+		t.Errorf("DebugAddr = %q, want the debug server off by default", cfg.DebugAddr)
+	}
+
+	if cfg.BaseURL != "http://localhost:8080" {
+		t.Errorf("BaseURL = %q, want the redirect URI's host", cfg.BaseURL)
+	}
+
+	if cfg.LogLevel != slog.LevelInfo || cfg.LogFormat != LogFormatText {
+		t.Errorf("logging = %v %q, want info text", cfg.LogLevel, cfg.LogFormat)
+	}
+}
+
+func TestLoadLogging(t *testing.T) {
+	t.Setenv("SPOTIFY_CLIENT_ID", "test_id")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "test_secret")
+	t.Setenv("SPOTIFY_REDIRECT_URI", "http://localhost:8080/callback")
+	t.Setenv("SESSION_SECRET", "test_session")
+	t.Setenv("LOG_LEVEL", "DEBUG")
+	t.Setenv("LOG_FORMAT", "json")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.LogLevel != slog.LevelDebug || cfg.LogFormat != LogFormatJSON {
+		t.Errorf("logging = %v %q, want debug json", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	for env, value := range map[string]string{"LOG_LEVEL": "loud", "LOG_FORMAT": "xml"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, value)
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() with %s=%s succeeded, want error", env, value)
+			}
+		})
+	}
+}
+
+func TestLoadBaseURL(t *testing.T) {
+	t.Setenv("SPOTIFY_CLIENT_ID", "test_id")
+	t.Setenv("SPOTIFY_CLIENT_SECRET", "test_secret")
+	t.Setenv("SPOTIFY_REDIRECT_URI", "http://127.0.0.1:8080/callback")
+	t.Setenv("SESSION_SECRET", "test_session")
+	t.Setenv("BASE_URL", "https://heardle.example.com/")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.BaseURL != "https://heardle.example.com" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "https://heardle.example.com")
+	}
+
+	for _, value := range []string{"heardle.example.com", "ftp://heardle.example.com", "https://"} {
+		t.Setenv("BASE_URL", value)
+		if _, err := Load(); err == nil {
+			t.Errorf("Load() with BASE_URL=%s succeeded, want error", value)
+		}
+	}
 }
 
 func TestLoadMissingRequired(t *testing.T) {
@@ -0,0 +1,106 @@
+// Package events provides publish/subscribe delivery of game state changes.
+package events
+
+import "sync"
+
+// Type identifies the kind of game event.
+type Type string
+
+// Event types emitted while a game is played.
+const (
+	GameStarted   Type = "game_started"
+	GuessRecorded Type = "guess_recorded"
+	HintRevealed  Type = "hint_revealed"
+	GameCompleted Type = "game_completed"
+)
+
+// subscriberBuffer is the number of events queued per subscriber before
+// further events are dropped for that subscriber.
+const subscriberBuffer = 16
+
+// Event represents a change to a game session.
+type Event struct {
+	Type      Type        `json:"type"`
+	SessionID string      `json:"sessionId"`
+	UserID    string      `json:"-"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Broker fans events out to subscribers of a session or user topic.
+type Broker struct {
+	subscribers map[string]map[chan Event]struct{}
+	mu          sync.RWMutex
+}
+
+// NewBroker creates a new event broker.
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// SessionTopic returns the topic carrying events for a single game session.
+func SessionTopic(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// UserTopic returns the topic carrying events for all of a user's games.
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// Subscribe registers a subscriber for a topic. The returned function
+// unsubscribes and closes the channel.
+func (b *Broker) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan Event]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[topic], ch)
+			if len(b.subscribers[topic]) == 0 {
+				delete(b.subscribers, topic)
+			}
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers an event to subscribers of its session and user topics.
+// Slow subscribers whose buffer is full miss the event rather than blocking
+// the publisher.
+func (b *Broker) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	topics := []string{SessionTopic(event.SessionID)}
+	if event.UserID != "" {
+		topics = append(topics, UserTopic(event.UserID))
+	}
+
+	for _, topic := range topics {
+		for ch := range b.subscribers[topic] {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+func (b *Broker) subscriberCount(topic string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers[topic])
+}
@@ -0,0 +1,93 @@
+// Package events provides publish/subscribe delivery of game state changes.
+package events
+
+import (
+	"testing"
+)
+
+func TestNewBroker(t *testing.T) {
+	broker := NewBroker()
+	if broker == nil {
+		t.Fatal("NewBroker() returned nil")
+	}
+}
+
+func TestPublishDeliversToSessionAndUserTopics(t *testing.T) {
+	broker := NewBroker()
+
+	sessionEvents, unsubscribeSession := broker.Subscribe(SessionTopic("session1"))
+	defer unsubscribeSession()
+	userEvents, unsubscribeUser := broker.Subscribe(UserTopic("user1"))
+	defer unsubscribeUser()
+
+	broker.Publish(Event{Type: GuessRecorded, SessionID: "session1", UserID: "user1"})
+
+	select {
+	case event := <-sessionEvents:
+		if event.Type != GuessRecorded {
+			t.Errorf("session event Type = %q, want %q", event.Type, GuessRecorded)
+		}
+	default:
+		t.Error("session subscriber did not receive event")
+	}
+
+	select {
+	case event := <-userEvents:
+		if event.SessionID != "session1" {
+			t.Errorf("user event SessionID = %q, want %q", event.SessionID, "session1")
+		}
+	default:
+		t.Error("user subscriber did not receive event")
+	}
+}
+
+func TestPublishIgnoresOtherTopics(t *testing.T) {
+	broker := NewBroker()
+
+	events, unsubscribe := broker.Subscribe(SessionTopic("other"))
+	defer unsubscribe()
+
+	broker.Publish(Event{Type: GameCompleted, SessionID: "session1", UserID: "user1"})
+
+	select {
+	case event := <-events:
+		t.Errorf("received unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestPublishDropsWhenBufferFull(t *testing.T) {
+	broker := NewBroker()
+
+	events, unsubscribe := broker.Subscribe(SessionTopic("session1"))
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+5; i++ {
+		broker.Publish(Event{Type: HintRevealed, SessionID: "session1"})
+	}
+
+	if len(events) != subscriberBuffer {
+		t.Errorf("len(events) = %d, want %d", len(events), subscriberBuffer)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	broker := NewBroker()
+	topic := SessionTopic("session1")
+
+	events, unsubscribe := broker.Subscribe(topic)
+	if broker.subscriberCount(topic) != 1 {
+		t.Fatalf("subscriberCount() = %d, want 1", broker.subscriberCount(topic))
+	}
+
+	unsubscribe()
+	unsubscribe()
+
+	if broker.subscriberCount(topic) != 0 {
+		t.Errorf("subscriberCount() = %d, want 0", broker.subscriberCount(topic))
+	}
+
+	if _, ok := <-events; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+}
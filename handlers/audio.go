@@ -0,0 +1,81 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"spotify-heardle/audio"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"time"
+)
+
+// AudioHandler serves the clips of preview games.
+type AudioHandler struct {
+	auth     *AuthHandler
+	store    *storage.MemoryStore
+	previews *audio.Previews
+}
+
+// NewAudioHandler creates a new audio clip handler.
+func NewAudioHandler(auth *AuthHandler, store *storage.MemoryStore, previews *audio.Previews) *AudioHandler {
+	return &AudioHandler{
+		auth:     auth,
+		store:    store,
+		previews: previews,
+	}
+}
+
+// HandleGetClip streams the part of the current song's preview the player
+// may hear so far, cut from the preview on the server so the client never
+// learns its URL or the track it belongs to.
+func (h *AudioHandler) HandleGetClip(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	session, err := h.store.GetSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	}
+
+	if session.UserID != user.ID {
+		writeError(w, errForbidden)
+		return
+	}
+
+	if session.Playback != models.PlaybackPreview || session.CorrectSong.PreviewURL == "" {
+		writeError(w, notFound("Game has no preview clips"))
+		return
+	}
+
+	preview, err := h.previews.Get(r.Context(), session.CorrectSong.PreviewURL)
+	if err != nil {
+		writeError(w, internalError("Failed to get preview").withCause(err))
+		return
+	}
+
+	clip, err := audio.Clip(preview, time.Duration(session.GetAudioDuration())*time.Second)
+	if err != nil {
+		writeError(w, internalError("Failed to cut preview").withCause(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(clip))
+}
+
+// clipURL returns where the client plays the current song of a preview game
+// from, or "" for games played with the SDK. The number of guesses makes the
+// URL change as longer clips are unlocked.
+func clipURL(session *models.GameSession) string {
+	if session.Playback != models.PlaybackPreview {
+		return ""
+	}
+	return fmt.Sprintf("/api/game/%s/audio?guesses=%d", session.ID, session.GuessesUsed)
+}
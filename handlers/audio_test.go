@@ -0,0 +1,100 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/audio"
+	"spotify-heardle/config"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"testing"
+)
+
+// testPreview is 30 seconds of silent 128 kbit/s, 44.1 kHz MP3 frames, each
+// 417 bytes long and about 26ms.
+var testPreview = bytes.Repeat(append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...), 1149)
+
+func newTestAudioHandler(t *testing.T) (*AudioHandler, *storage.MemoryStore, string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPreview)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	handler := NewAudioHandler(NewAuthHandler(cfg, store), store, audio.NewPreviews(audio.DefaultPreviewTTL))
+	return handler, store, server.URL + "/mp3-preview/track1"
+}
+
+func TestHandleGetClip(t *testing.T) {
+	handler, store, previewURL := newTestAudioHandler(t)
+	cookie := loginTestUser(t, store, "user1")
+
+	session := models.NewGameSession("session123", "user1", []string{"liked"}, models.Track{ID: "track1", PreviewURL: previewURL})
+	session.Playback = models.PlaybackPreview
+	store.SaveSession(session)
+
+	// 1 second takes 39 frames, and 2 seconds 77.
+	for guesses, frames := range []int{39, 77} {
+		session.GuessesUsed = guesses
+		store.SaveSession(session)
+
+		req := httptest.NewRequest("GET", clipURL(session), nil)
+		req.SetPathValue("id", session.ID)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleGetClip(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("Content-Type"); got != "audio/mpeg" {
+			t.Errorf("Content-Type = %q, want audio/mpeg", got)
+		}
+		if got := w.Body.Len(); got != frames*417 {
+			t.Errorf("after %d guesses: clip length = %d, want %d", guesses, got, frames*417)
+		}
+	}
+}
+
+func TestHandleGetClipRejected(t *testing.T) {
+	handler, store, previewURL := newTestAudioHandler(t)
+
+	preview := models.NewGameSession("preview", "owner", []string{"liked"}, models.Track{ID: "track1", PreviewURL: previewURL})
+	preview.Playback = models.PlaybackPreview
+	store.SaveSession(preview)
+	store.SaveSession(models.NewGameSession("sdk", "owner", []string{"liked"}, models.Track{ID: "track1", PreviewURL: previewURL}))
+
+	tests := []struct {
+		sessionID string
+		userID    string
+		want      int
+	}{
+		{"preview", "intruder", http.StatusForbidden},
+		{"sdk", "owner", http.StatusNotFound},
+		{"missing", "owner", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/game/"+tt.sessionID+"/audio", nil)
+		req.SetPathValue("id", tt.sessionID)
+		req.AddCookie(loginTestUser(t, store, tt.userID))
+		w := httptest.NewRecorder()
+
+		handler.HandleGetClip(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s game for %s: status = %d, want %d", tt.sessionID, tt.userID, w.Code, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
@@ -35,7 +36,7 @@ func NewAuthHandler(cfg *config.Config, store *storage.MemoryStore) *AuthHandler
 func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	state, err := generateState()
 	if err != nil {
-		http.Error(w, "Failed to generate state", http.StatusInternalServerError)
+		writeError(w, internalError("Failed to generate state").withCause(err))
 		return
 	}
 
@@ -47,26 +48,29 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		http.Error(w, "Missing authorization code", http.StatusBadRequest)
+		writeError(w, invalidRequest("Missing authorization code"))
 		return
 	}
 
-	token, err := h.auth.ExchangeCodeForToken(code)
+	token, err := h.auth.ExchangeCodeForToken(r.Context(), code)
 	if err != nil {
-		http.Error(w, "Failed to exchange code for token", http.StatusInternalServerError)
+		writeError(w, spotifyError(err, "Failed to exchange code for token"))
 		return
 	}
 
 	client := spotify.NewClient(token)
+	client.SetContext(r.Context())
 	profile, err := client.GetUserProfile()
 	if err != nil {
-		http.Error(w, "Failed to get user profile", http.StatusInternalServerError)
+		writeError(w, spotifyError(err, "Failed to get user profile"))
 		return
 	}
 
 	user := models.NewUser(profile.ID, profile.DisplayName, token)
+	user.Country = profile.Country
+	user.Product = profile.Product
 	if err := h.store.SaveUser(user); err != nil {
-		http.Error(w, "Failed to save user", http.StatusInternalServerError)
+		writeError(w, internalError("Failed to save user").withCause(err))
 		return
 	}
 
@@ -116,20 +120,34 @@ func (h *AuthHandler) GetUserFromSession(r *http.Request) (*models.User, error)
 		return nil, fmt.Errorf("invalid session data: %w", err)
 	}
 
-	user, err := h.store.GetUser(sessionData.UserID)
+	user, err := h.currentUser(r.Context(), sessionData.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	logUser(r, user.ID)
+	return user, nil
+}
+
+// currentUser retrieves a user from the store, refreshing their token if it
+// has expired. The refreshed user is saved as a copy rather than changed in
+// place, since other requests and background work may hold the stored user.
+func (h *AuthHandler) currentUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := h.store.GetUser(userID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
 	if user.Token.IsExpired() {
-		newToken, err := h.auth.RefreshToken(user.Token.RefreshToken, user.Token)
+		newToken, err := h.auth.RefreshToken(ctx, user.Token.RefreshToken, user.Token)
 		if err != nil {
 			return nil, fmt.Errorf("failed to refresh token: %w", err)
 		}
-		user.Token = newToken
-		h.store.SaveUser(user)
+		refreshed := *user
+		refreshed.Token = newToken
+		h.store.SaveUser(&refreshed)
+		user = &refreshed
 	}
-
 	return user, nil
 }
 
@@ -137,7 +155,7 @@ func (h *AuthHandler) GetUserFromSession(r *http.Request) (*models.User, error)
 func (h *AuthHandler) HandleGetToken(w http.ResponseWriter, r *http.Request) {
 	user, err := h.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
 		return
 	}
 
@@ -162,3 +180,20 @@ func generateSessionID() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b), nil
 }
+
+func generateShareID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newSpotifyClient creates a Spotify client for the user that fetches tracks
+// as available in their country, with requests made in ctx.
+func newSpotifyClient(ctx context.Context, user *models.User) *spotify.Client {
+	client := spotify.NewClient(user.Token)
+	client.SetContext(ctx)
+	client.SetMarket(user.Country)
+	return client
+}
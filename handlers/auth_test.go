@@ -2,12 +2,16 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"spotify-heardle/config"
+	"spotify-heardle/models"
 	"spotify-heardle/storage"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewAuthHandler(t *testing.T) {
@@ -110,3 +114,50 @@ func TestGenerateSessionID(t *testing.T) {
 		t.Error("generateSessionID() returned same ID twice")
 	}
 }
+
+// loginTestUser stores a user with a valid token and returns a session cookie for it.
+func loginTestUser(t *testing.T, store *storage.MemoryStore, userID string) *http.Cookie {
+	t.Helper()
+
+	user := models.NewUser(userID, "Test User", &models.Token{
+		AccessToken:  "access_token",
+		RefreshToken: "refresh_token",
+		ExpiresAt:    time.Now().Add(1 * time.Hour),
+	})
+	if err := store.SaveUser(user); err != nil {
+		t.Fatalf("SaveUser() failed: %v", err)
+	}
+
+	sessionJSON, err := json.Marshal(sessionCookie{UserID: userID})
+	if err != nil {
+		t.Fatalf("marshaling session cookie: %v", err)
+	}
+
+	return &http.Cookie{
+		Name:  "session",
+		Value: base64.StdEncoding.EncodeToString(sessionJSON),
+	}
+}
+
+func TestGetUserFromSession(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	handler := NewAuthHandler(cfg, store)
+
+	req := httptest.NewRequest("GET", "/api/token", nil)
+	req.AddCookie(loginTestUser(t, store, "user123"))
+
+	user, err := handler.GetUserFromSession(req)
+	if err != nil {
+		t.Fatalf("GetUserFromSession() failed: %v", err)
+	}
+
+	if user.ID != "user123" {
+		t.Errorf("user.ID = %q, want %q", user.ID, "user123")
+	}
+}
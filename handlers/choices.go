@@ -0,0 +1,153 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"spotify-heardle/models"
+	"strings"
+)
+
+const (
+	defaultChoiceCount = 4
+	minChoiceCount     = 2
+	maxChoiceCount     = 8
+)
+
+// choiceResponse is an option as shown to the player. It carries no track ID
+// so the answer cannot be picked out of the payload.
+type choiceResponse struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Artists []string `json:"artists"`
+}
+
+// newChoices builds count shuffled options for a multiple-choice game: the
+// answer plus decoys from the same pool, preferring tracks by the same artists
+// or from around the same year. Fewer options are returned when the pool is
+// too small.
+func newChoices(tracks []models.Track, answer models.Track, count int) ([]models.Choice, error) {
+	decoys := pickDecoys(tracks, answer, count-1)
+	if len(decoys) == 0 {
+		return nil, errors.New("not enough distinct tracks for multiple choice")
+	}
+
+	options := append([]models.Track{answer}, decoys...)
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+
+	choices := make([]models.Choice, len(options))
+	for i, track := range options {
+		id, err := generateShareID()
+		if err != nil {
+			return nil, err
+		}
+		choices[i] = models.Choice{ID: id, Track: track}
+	}
+	return choices, nil
+}
+
+// pickDecoys returns up to n tracks other than the answer, most similar first.
+// Tracks with the same name and artists as an earlier pick are skipped so
+// duplicates across playlists don't show up twice.
+func pickDecoys(tracks []models.Track, answer models.Track, n int) []models.Track {
+	seen := map[string]bool{choiceKey(answer): true}
+	candidates := make([]models.Track, 0, len(tracks))
+	for _, track := range tracks {
+		key := choiceKey(track)
+		if track.ID == answer.ID || seen[key] {
+			continue
+		}
+		seen[key] = true
+		candidates = append(candidates, track)
+	}
+
+	// Shuffle first so equally similar tracks are picked at random.
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return similarity(candidates[i], answer) > similarity(candidates[j], answer)
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// similarity scores how plausible a decoy is: sharing an artist counts most,
+// then being released within a few years of the answer.
+func similarity(track, answer models.Track) int {
+	score := 0
+	if (models.Guess{Artists: track.Artists}).SharesArtist(answer) {
+		score += 2
+	}
+
+	year, answerYear := track.ReleaseYear(), answer.ReleaseYear()
+	if year > 0 && answerYear > 0 {
+		if proximity, _ := models.CompareYears(year, answerYear); proximity != models.ProximityFar {
+			score++
+		}
+	}
+	return score
+}
+
+func choiceKey(track models.Track) string {
+	return strings.ToLower(track.Name + "\x00" + strings.Join(track.Artists, ","))
+}
+
+// choiceCount validates the number of options requested for a game, where
+// zero selects the default.
+func choiceCount(requested int) (int, error) {
+	if requested == 0 {
+		return defaultChoiceCount, nil
+	}
+	if requested < minChoiceCount || requested > maxChoiceCount {
+		return 0, errors.New("choices must be between 2 and 8")
+	}
+	return requested, nil
+}
+
+func newChoiceResponses(choices []models.Choice) []choiceResponse {
+	if len(choices) == 0 {
+		return nil
+	}
+
+	responses := make([]choiceResponse, len(choices))
+	for i, choice := range choices {
+		responses[i] = choiceResponse{
+			ID:      choice.ID,
+			Name:    choice.Track.Name,
+			Artists: choice.Track.Artists,
+		}
+	}
+	return responses
+}
+
+func evaluateChoiceGuess(session *models.GameSession, req submitGuessRequest) (models.Guess, error) {
+	if req.ChoiceID == "" {
+		return models.Guess{}, errors.New("choiceId is required in choice mode")
+	}
+
+	choice, ok := session.Choice(req.ChoiceID)
+	if !ok {
+		return models.Guess{}, errors.New("unknown choiceId")
+	}
+
+	for _, guess := range session.Guesses {
+		if guess.ChoiceID == choice.ID {
+			return models.Guess{}, errors.New("choice already guessed")
+		}
+	}
+
+	return models.Guess{
+		ChoiceID:  choice.ID,
+		TrackID:   choice.Track.ID,
+		TrackName: choice.Track.Name,
+		Artists:   choice.Track.Artists,
+		IsCorrect: choice.Track.ID == session.CorrectSong.ID,
+	}, nil
+}
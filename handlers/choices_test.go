@@ -0,0 +1,161 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/events"
+	"spotify-heardle/models"
+	"spotify-heardle/search"
+	"spotify-heardle/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewChoices(t *testing.T) {
+	answer := models.Track{ID: "answer", Name: "Song", Artists: []string{"Artist"}}
+	tracks := []models.Track{
+		answer,
+		{ID: "t1", Name: "One", Artists: []string{"Other"}},
+		{ID: "t2", Name: "Two", Artists: []string{"Other"}},
+		{ID: "t3", Name: "Three", Artists: []string{"Other"}},
+		{ID: "t4", Name: "Four", Artists: []string{"Other"}},
+	}
+
+	choices, err := newChoices(tracks, answer, 4)
+	if err != nil {
+		t.Fatalf("newChoices() failed: %v", err)
+	}
+
+	if len(choices) != 4 {
+		t.Fatalf("got %d choices, want 4", len(choices))
+	}
+
+	answers := 0
+	ids := make(map[string]bool)
+	for _, choice := range choices {
+		if choice.Track.ID == answer.ID {
+			answers++
+		}
+		if choice.ID == "" || choice.ID == choice.Track.ID || ids[choice.ID] {
+			t.Errorf("choice ID %q is not a unique opaque ID", choice.ID)
+		}
+		ids[choice.ID] = true
+	}
+
+	if answers != 1 {
+		t.Errorf("answer offered %d times, want 1", answers)
+	}
+}
+
+func TestNewChoicesPoolTooSmall(t *testing.T) {
+	answer := models.Track{ID: "answer", Name: "Song", Artists: []string{"Artist"}}
+	duplicate := models.Track{ID: "other", Name: "song", Artists: []string{"Artist"}}
+
+	if _, err := newChoices([]models.Track{answer, duplicate}, answer, 4); err == nil {
+		t.Error("newChoices() succeeded without decoys, want error")
+	}
+}
+
+func TestPickDecoysPrefersSimilarTracks(t *testing.T) {
+	answer := models.Track{ID: "answer", Name: "Song", Artists: []string{"Artist"}, ReleaseDate: "1999"}
+	tracks := []models.Track{
+		{ID: "far", Name: "Far", Artists: []string{"Other"}, ReleaseDate: "1960"},
+		{ID: "era", Name: "Era", Artists: []string{"Other"}, ReleaseDate: "2001"},
+		{ID: "artist", Name: "Same Artist", Artists: []string{"artist"}, ReleaseDate: "1970"},
+	}
+
+	decoys := pickDecoys(tracks, answer, 2)
+
+	if len(decoys) != 2 || decoys[0].ID != "artist" || decoys[1].ID != "era" {
+		t.Errorf("decoys = %v, want artist then era", decoys)
+	}
+}
+
+func TestChoiceCount(t *testing.T) {
+	if n, err := choiceCount(0); err != nil || n != defaultChoiceCount {
+		t.Errorf("choiceCount(0) = %d, %v, want default", n, err)
+	}
+
+	for _, requested := range []int{1, 9, -1} {
+		if _, err := choiceCount(requested); err == nil {
+			t.Errorf("choiceCount(%d) succeeded, want error", requested)
+		}
+	}
+}
+
+func TestEvaluateChoiceGuess(t *testing.T) {
+	session := &models.GameSession{
+		Mode:        models.ModeChoice,
+		CorrectSong: models.Track{ID: "answer"},
+		Choices: []models.Choice{
+			{ID: "c1", Track: models.Track{ID: "decoy", Name: "Decoy"}},
+			{ID: "c2", Track: models.Track{ID: "answer", Name: "Answer"}},
+		},
+	}
+
+	guess, err := evaluateChoiceGuess(session, submitGuessRequest{ChoiceID: "c1"})
+	if err != nil || guess.IsCorrect || guess.TrackName != "Decoy" {
+		t.Errorf("decoy guess = %+v, %v, want incorrect Decoy", guess, err)
+	}
+
+	session.AddGuess(guess, time.Now())
+	if _, err := evaluateChoiceGuess(session, submitGuessRequest{ChoiceID: "c1"}); err == nil {
+		t.Error("repeated choice succeeded, want error")
+	}
+
+	if _, err := evaluateChoiceGuess(session, submitGuessRequest{ChoiceID: "answer"}); err == nil {
+		t.Error("guessing a track ID succeeded, want error")
+	}
+
+	guess, err = evaluateChoiceGuess(session, submitGuessRequest{ChoiceID: "c2"})
+	if err != nil || !guess.IsCorrect {
+		t.Errorf("answer guess = %+v, %v, want correct", guess, err)
+	}
+}
+
+func TestChoiceGameHidesAnswer(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	handler := NewGameHandler(NewAuthHandler(cfg, store), store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	// Even a game saved with SDK playback must not name the answer.
+	answer := models.Track{ID: "answer7", Name: "Answer", Artists: []string{"Artist"}}
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, answer)
+	session.Mode = models.ModeChoice
+	session.Playback = models.PlaybackSDK
+	session.Choices = []models.Choice{
+		{ID: "c1", Track: models.Track{ID: "decoy", Name: "Decoy", Artists: []string{"Other"}}},
+		{ID: "c2", Track: answer},
+	}
+	store.SaveSession(session)
+
+	req := httptest.NewRequest("GET", "/api/game/session123", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(cookie)
+	state := httptest.NewRecorder()
+	handler.HandleGetGame(state, req)
+
+	req = httptest.NewRequest("POST", "/api/game/guess", bytes.NewBufferString(`{"sessionId":"session123","choiceId":"c1"}`))
+	req.AddCookie(cookie)
+	guess := httptest.NewRecorder()
+	handler.HandleSubmitGuess(guess, req)
+
+	for name, w := range map[string]*httptest.ResponseRecorder{"state": state, "guess": guess} {
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, want %d", name, w.Code, http.StatusOK)
+		}
+		if strings.Contains(w.Body.String(), answer.ID) {
+			t.Errorf("%s response names the answer: %s", name, w.Body.String())
+		}
+	}
+}
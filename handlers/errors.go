@@ -0,0 +1,177 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"spotify-heardle/spotify"
+	"spotify-heardle/storage"
+	"strconv"
+	"time"
+)
+
+// ErrorCode is a stable, machine-readable kind of error that clients can
+// switch on, unlike the message, which is meant for people and may change.
+type ErrorCode string
+
+// Error codes returned by the API.
+const (
+	// CodeInvalidRequest is a malformed body, parameter or value.
+	CodeInvalidRequest ErrorCode = "invalid_request"
+	// CodeUnauthorized means the user is not logged in.
+	CodeUnauthorized ErrorCode = "unauthorized"
+	// CodeForbidden means the resource belongs to another user.
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeNotFound means the resource or route does not exist.
+	CodeNotFound         ErrorCode = "not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	// CodeEmptyPool means the chosen sources leave no tracks a game can be
+	// played with.
+	CodeEmptyPool ErrorCode = "empty_pool"
+	// CodeGameComplete means the game is already over, and
+	// CodeGameInProgress that it is not over yet.
+	CodeGameComplete   ErrorCode = "game_complete"
+	CodeGameInProgress ErrorCode = "game_in_progress"
+	// CodeGameExpired means a guess arrived after the deadline of a timed
+	// game. The details give the game's state once the missed deadline is
+	// counted.
+	CodeGameExpired ErrorCode = "game_expired"
+	// CodeSpotifyUnauthorized means Spotify rejected the user's token, and
+	// logging in again should help.
+	CodeSpotifyUnauthorized ErrorCode = "spotify_unauthorized"
+	// CodeSpotifyRateLimited means Spotify is throttling requests. The
+	// details give retryAfterSeconds.
+	CodeSpotifyRateLimited ErrorCode = "spotify_rate_limited"
+	// CodeSpotifyError is any other failed Spotify request. The details give
+	// Spotify's status code.
+	CodeSpotifyError ErrorCode = "spotify_error"
+	CodeInternal     ErrorCode = "internal_error"
+)
+
+// apiError is an error response: an HTTP status, a code and a message, with
+// optional details about what went wrong.
+type apiError struct {
+	status  int
+	code    ErrorCode
+	message string
+	details any
+	// retryAfter is sent as the Retry-After header when set.
+	retryAfter time.Duration
+	// cause is the error behind the response, which is logged but not sent.
+	cause error
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// withCause returns a copy of the error with the error behind it, for the
+// request log.
+func (e *apiError) withCause(cause error) *apiError {
+	withCause := *e
+	withCause.cause = cause
+	return &withCause
+}
+
+// withDetails returns a copy of the error with details added.
+func (e *apiError) withDetails(details any) *apiError {
+	withDetails := *e
+	withDetails.details = details
+	return &withDetails
+}
+
+// Errors shared by many handlers.
+var (
+	errUnauthorized = newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+	errForbidden    = newAPIError(http.StatusForbidden, CodeForbidden, "Forbidden")
+	errInvalidBody  = invalidRequest("Invalid request body")
+)
+
+func newAPIError(status int, code ErrorCode, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+// invalidRequest describes a 400 for a request that fails validation.
+func invalidRequest(message string) *apiError {
+	return newAPIError(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+func notFound(message string) *apiError {
+	return newAPIError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func internalError(message string) *apiError {
+	return newAPIError(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// lookupError describes a failed store lookup: a 404 with message if the
+// record does not exist, or an internal error otherwise.
+func lookupError(err error, message string) *apiError {
+	if errors.Is(err, storage.ErrNotFound) {
+		return notFound(message)
+	}
+	return internalError(message).withCause(err)
+}
+
+// spotifyError describes a failed Spotify request by the status Spotify
+// answered with. message says what the request was for; requests that
+// failed without a response are internal errors.
+func spotifyError(err error, message string) *apiError {
+	var apiErr *spotify.APIError
+	if !errors.As(err, &apiErr) {
+		return internalError(message).withCause(err)
+	}
+
+	switch apiErr.Status {
+	case http.StatusUnauthorized:
+		return newAPIError(http.StatusUnauthorized, CodeSpotifyUnauthorized, message+": log in again").withCause(err)
+	case http.StatusNotFound:
+		return notFound(message).withCause(err)
+	case http.StatusTooManyRequests:
+		rateLimited := newAPIError(http.StatusTooManyRequests, CodeSpotifyRateLimited, message+": Spotify is busy, try again shortly").
+			withDetails(map[string]int{"retryAfterSeconds": int(math.Ceil(apiErr.RetryAfter.Seconds()))}).
+			withCause(err)
+		rateLimited.retryAfter = apiErr.RetryAfter
+		return rateLimited
+	}
+	return newAPIError(http.StatusBadGateway, CodeSpotifyError, message).
+		withDetails(map[string]int{"spotifyStatus": apiErr.Status}).
+		withCause(err)
+}
+
+// errorResponse is the body of every error response, as
+// {"error": {"code", "message", "details", "requestId"}}.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Details   any       `json:"details,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// writeError writes err as a JSON error response. The request ID is taken
+// from the response header set by RequestID, so a report of the error can be
+// matched with the server's logs, where LogRequests records the error.
+func writeError(w http.ResponseWriter, err *apiError) {
+	if logged, ok := w.(*loggedResponse); ok {
+		logged.err = err
+	}
+	if err.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.retryAfter.Seconds()))))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorBody{
+		Code:      err.code,
+		Message:   err.message,
+		Details:   err.details,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}})
+}
@@ -0,0 +1,88 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/spotify"
+	"spotify-heardle/storage"
+	"testing"
+	"time"
+)
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req-123")
+
+	writeError(w, invalidRequest("Invalid limit parameter").withDetails(map[string]string{"param": "limit"}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	var body struct {
+		Error struct {
+			Code      string            `json:"code"`
+			Message   string            `json:"message"`
+			Details   map[string]string `json:"details"`
+			RequestID string            `json:"requestId"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body: %v", err)
+	}
+	if body.Error.Code != "invalid_request" || body.Error.Message != "Invalid limit parameter" ||
+		body.Error.Details["param"] != "limit" || body.Error.RequestID != "req-123" {
+		t.Errorf("error body = %+v", body.Error)
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	_, err := storage.NewMemoryStore().GetSession("missing")
+	if got := lookupError(err, "Session not found"); got.status != http.StatusNotFound || got.code != CodeNotFound {
+		t.Errorf("lookupError(not found) = %+v", got)
+	}
+
+	if got := lookupError(errors.New("disk on fire"), "Session not found"); got.status != http.StatusInternalServerError || got.code != CodeInternal {
+		t.Errorf("lookupError(other) = %+v", got)
+	}
+}
+
+func TestSpotifyError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   ErrorCode
+	}{
+		{&spotify.APIError{Status: http.StatusUnauthorized}, http.StatusUnauthorized, CodeSpotifyUnauthorized},
+		{&spotify.APIError{Status: http.StatusNotFound}, http.StatusNotFound, CodeNotFound},
+		{&spotify.APIError{Status: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}, http.StatusTooManyRequests, CodeSpotifyRateLimited},
+		{&spotify.APIError{Status: http.StatusServiceUnavailable}, http.StatusBadGateway, CodeSpotifyError},
+		{errors.New("connection refused"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("getting playlists: %w", tt.err)
+		got := spotifyError(err, "Failed to get playlists")
+		if got.status != tt.wantStatus || got.code != tt.wantCode {
+			t.Errorf("spotifyError(%v) = %d %s, want %d %s", tt.err, got.status, got.code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
+
+func TestWriteErrorRetryAfter(t *testing.T) {
+	err := &spotify.APIError{Status: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}
+	w := httptest.NewRecorder()
+
+	writeError(w, spotifyError(err, "Failed to search"))
+
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want 3", got)
+	}
+}
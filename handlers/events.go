@@ -0,0 +1,121 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"spotify-heardle/events"
+	"spotify-heardle/storage"
+	"time"
+)
+
+// heartbeatInterval keeps idle event streams open through proxies.
+const heartbeatInterval = 15 * time.Second
+
+// EventsHandler streams game state changes as Server-Sent Events.
+type EventsHandler struct {
+	auth   *AuthHandler
+	store  *storage.MemoryStore
+	broker *events.Broker
+}
+
+// NewEventsHandler creates a new events handler.
+func NewEventsHandler(auth *AuthHandler, store *storage.MemoryStore, broker *events.Broker) *EventsHandler {
+	return &EventsHandler{
+		auth:   auth,
+		store:  store,
+		broker: broker,
+	}
+}
+
+// HandleSessionEvents streams events for a single game session, named in the
+// path or, for older clients, by the sessionId parameter.
+func (h *EventsHandler) HandleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
+		sessionID = r.URL.Query().Get("sessionId")
+	}
+	if sessionID == "" {
+		writeError(w, invalidRequest("Missing sessionId parameter"))
+		return
+	}
+
+	session, err := h.store.GetSession(sessionID)
+	if err != nil {
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	}
+
+	if session.UserID != user.ID {
+		writeError(w, errForbidden)
+		return
+	}
+
+	h.stream(w, r, events.SessionTopic(session.ID))
+}
+
+// HandleUserEvents streams events for every game of the current user.
+func (h *EventsHandler) HandleUserEvents(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	h.stream(w, r, events.UserTopic(user.ID))
+}
+
+func (h *EventsHandler) stream(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, internalError("Streaming unsupported"))
+		return
+	}
+
+	subscription, unsubscribe := h.broker.Subscribe(topic)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event, ok := <-subscription:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
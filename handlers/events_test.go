@@ -0,0 +1,145 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/events"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"strings"
+	"testing"
+)
+
+func TestHandleSessionEventsNoAuth(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewEventsHandler(authHandler, store, events.NewBroker())
+
+	req := httptest.NewRequest("GET", "/api/game/events?sessionId=session123", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleSessionEvents(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleSessionEventsOtherUser(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewEventsHandler(authHandler, store, events.NewBroker())
+
+	store.SaveSession(models.NewGameSession("session123", "owner", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	cookie := loginTestUser(t, store, "intruder")
+
+	req := httptest.NewRequest("GET", "/api/game/events?sessionId=session123", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleSessionEvents(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest("GET", "/api/game/session123/events", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+
+	handler.HandleSessionEvents(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("with the session in the path: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandleSessionEventsStreamsEvents(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	broker := events.NewBroker()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewEventsHandler(authHandler, store, broker)
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+	cookie := loginTestUser(t, store, "user1")
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleSessionEvents))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"?sessionId=session123", nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want %q", ct, "text/event-stream")
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, ": connected") {
+		t.Fatalf("first line = %q, %v; want connected comment", line, err)
+	}
+
+	broker.Publish(events.Event{Type: events.GuessRecorded, SessionID: "session123", UserID: "user1"})
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if strings.HasPrefix(line, "event: ") {
+			if got := strings.TrimSpace(strings.TrimPrefix(line, "event: ")); got != string(events.GuessRecorded) {
+				t.Errorf("event = %q, want %q", got, events.GuessRecorded)
+			}
+			break
+		}
+	}
+}
+
+func TestHandleUserEventsNoAuth(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewEventsHandler(authHandler, store, events.NewBroker())
+
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleUserEvents(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -2,36 +2,84 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"slices"
+	"spotify-heardle/events"
 	"spotify-heardle/models"
-	"spotify-heardle/spotify"
+	"spotify-heardle/search"
 	"spotify-heardle/storage"
 	"time"
 )
 
 // GameHandler handles game-related routes.
 type GameHandler struct {
-	auth  *AuthHandler
-	store *storage.MemoryStore
+	auth    *AuthHandler
+	store   *storage.MemoryStore
+	broker  *events.Broker
+	indexes *search.Indexes
+	now     func() time.Time
+	// lookupTrack fetches a guessed track from Spotify. It can be replaced
+	// in tests.
+	lookupTrack func(ctx context.Context, user *models.User, trackID string) (models.Track, error)
 }
 
+// blitzQueueSize caps the songs queued for a blitz game; nobody gets through
+// more than this in a minute.
+const blitzQueueSize = 50
+
 type startGameRequest struct {
+	PoolID      string   `json:"poolId"`
 	PlaylistIDs []string `json:"playlistIds"`
+	Mode        string   `json:"mode"`
+	Choices     int      `json:"choices"`
+	PoolSearch  bool     `json:"poolSearch"`
+	// Filters narrows the tracks of the playlists. Without filters, the
+	// default filter leaves out local files and podcast episodes.
+	Filters *models.TrackFilter `json:"filters"`
+	// Playback is "sdk" or "preview", defaulting to the SDK for Premium
+	// users and to preview clips for everyone else.
+	Playback string `json:"playback"`
 }
 
 type startGameResponse struct {
-	SessionID     string `json:"sessionId"`
-	AudioDuration int    `json:"audioDuration"`
-	TrackURI      string `json:"trackUri"`
+	SessionID     string           `json:"sessionId"`
+	Mode          models.GameMode  `json:"mode"`
+	AudioDuration int              `json:"audioDuration"`
+	TrackURI      string           `json:"trackUri,omitempty"`
+	Choices       []choiceResponse `json:"choices,omitempty"`
+	RemainingMs   *int64           `json:"remainingMs,omitempty"`
+	PoolSearch    bool             `json:"poolSearch"`
+	// PoolSize is the number of tracks the game's answers are drawn from,
+	// after filtering.
+	PoolSize int `json:"poolSize"`
+	// Playback is how the client plays the songs. Preview games play
+	// ClipURL instead of TrackURI, and NoPreview counts the tracks left out
+	// for having no preview clip.
+	Playback  models.Playback `json:"playback"`
+	ClipURL   string          `json:"clipUrl,omitempty"`
+	NoPreview int             `json:"noPreview,omitempty"`
 }
 
 type submitGuessRequest struct {
-	SessionID string `json:"sessionId"`
-	TrackID   string `json:"trackId"`
-	TrackName string `json:"trackName"`
+	SessionID  string   `json:"sessionId"`
+	ChoiceID   string   `json:"choiceId"`
+	TrackID    string   `json:"trackId"`
+	TrackName  string   `json:"trackName"`
+	Artists    []string `json:"artists"`
+	ArtistID   string   `json:"artistId"`
+	ArtistName string   `json:"artistName"`
+	AlbumID    string   `json:"albumId"`
+	AlbumName  string   `json:"albumName"`
+	Year       int      `json:"year"`
+
+	// guessed is the track named by TrackID, looked up on the server so
+	// the guess is judged on the track's real details, not ones the client
+	// sends.
+	guessed *models.Track
 }
 
 type submitGuessResponse struct {
@@ -40,23 +88,76 @@ type submitGuessResponse struct {
 	Won           bool          `json:"won"`
 	GuessesUsed   int           `json:"guessesUsed"`
 	AudioDuration int           `json:"audioDuration"`
+	YearFeedback  *yearFeedback `json:"yearFeedback,omitempty"`
+	RemainingMs   *int64        `json:"remainingMs,omitempty"`
+	Solved        int           `json:"solved,omitempty"`
+	TrackURI      string        `json:"trackUri,omitempty"`
+	ClipURL       string        `json:"clipUrl,omitempty"`
 	CorrectSong   *models.Track `json:"correctSong,omitempty"`
 }
 
+type yearFeedback struct {
+	Proximity models.YearProximity `json:"proximity"`
+	Direction models.YearDirection `json:"direction,omitempty"`
+}
+
+type gameStateResponse struct {
+	SessionID     string           `json:"sessionId"`
+	Mode          models.GameMode  `json:"mode"`
+	TrackURI      string           `json:"trackUri,omitempty"`
+	Playback      models.Playback  `json:"playback"`
+	ClipURL       string           `json:"clipUrl,omitempty"`
+	Guesses       []guessResponse  `json:"guesses"`
+	GuessesUsed   int              `json:"guessesUsed"`
+	MaxGuesses    int              `json:"maxGuesses"`
+	PoolSearch    bool             `json:"poolSearch"`
+	Choices       []choiceResponse `json:"choices,omitempty"`
+	AudioDuration int              `json:"audioDuration"`
+	IsComplete    bool             `json:"isComplete"`
+	Won           bool             `json:"won"`
+	RemainingMs   *int64           `json:"remainingMs,omitempty"`
+	Solved        int              `json:"solved,omitempty"`
+	CorrectSong   *models.Track    `json:"correctSong,omitempty"`
+}
+
+type guessResponse struct {
+	Name         string        `json:"name"`
+	TrackName    string        `json:"trackName,omitempty"`
+	ChoiceID     string        `json:"choiceId,omitempty"`
+	IsCorrect    bool          `json:"isCorrect"`
+	YearFeedback *yearFeedback `json:"yearFeedback,omitempty"`
+}
+
 type skipRequest struct {
 	SessionID string `json:"sessionId"`
 }
 
+// skipResponse reveals the skipped song. A blitz game carries on with the
+// next song, described by the remaining fields, until its queue runs out.
 type skipResponse struct {
-	CorrectSong models.Track `json:"correctSong"`
+	CorrectSong   models.Track `json:"correctSong"`
+	IsComplete    bool         `json:"isComplete"`
+	Won           bool         `json:"won"`
+	GuessesUsed   int          `json:"guessesUsed"`
+	AudioDuration int          `json:"audioDuration"`
+	TrackURI      string       `json:"trackUri,omitempty"`
+	ClipURL       string       `json:"clipUrl,omitempty"`
+	RemainingMs   *int64       `json:"remainingMs,omitempty"`
+	Solved        int          `json:"solved"`
 }
 
 // NewGameHandler creates a new game handler.
-func NewGameHandler(auth *AuthHandler, store *storage.MemoryStore) *GameHandler {
+func NewGameHandler(auth *AuthHandler, store *storage.MemoryStore, broker *events.Broker, indexes *search.Indexes) *GameHandler {
 	rand.Seed(time.Now().UnixNano())
 	return &GameHandler{
-		auth:  auth,
-		store: store,
+		auth:    auth,
+		store:   store,
+		broker:  broker,
+		indexes: indexes,
+		now:     time.Now,
+		lookupTrack: func(ctx context.Context, user *models.User, trackID string) (models.Track, error) {
+			return newSpotifyClient(ctx, user).GetTrack(trackID)
+		},
 	}
 }
 
@@ -64,154 +165,699 @@ func NewGameHandler(auth *AuthHandler, store *storage.MemoryStore) *GameHandler
 func (h *GameHandler) HandleStartGame(w http.ResponseWriter, r *http.Request) {
 	user, err := h.auth.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
 		return
 	}
 
 	var req startGameRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, errInvalidBody)
 		return
 	}
 
-	if len(req.PlaylistIDs) == 0 {
-		http.Error(w, "At least one playlist ID required", http.StatusBadRequest)
+	mode, filter, ok := prepareStart(w, h.store, user.ID, &req)
+	if !ok {
 		return
 	}
 
-	client := spotify.NewClient(user.Token)
-	tracks, err := client.GetMultiplePlaylistsTracks(req.PlaylistIDs)
+	numChoices, err := choiceCount(req.Choices)
 	if err != nil {
-		http.Error(w, "Failed to get playlist tracks", http.StatusInternalServerError)
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return
+	}
+
+	if req.PoolSearch && !guessesTracks(mode) {
+		writeError(w, invalidRequest("Pool search is only available when guessing songs"))
+		return
+	}
+
+	playback, err := choosePlayback(user, req.Playback, mode)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return
+	}
+
+	client := newSpotifyClient(r.Context(), user)
+	tracks, stats, err := buildPool(client, req.PlaylistIDs, filter, mode, playback)
+	if err != nil {
+		writeError(w, spotifyError(err, "Failed to get playlist tracks"))
 		return
 	}
 
 	if len(tracks) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Playlists are empty or have no valid tracks.",
-		})
+		message := "Playlists are empty or have no valid tracks."
+		switch {
+		case stats.NoPreview > 0:
+			message = "None of the tracks have a preview clip to play without Spotify Premium."
+		case stats.FilteredOut > 0 && req.Filters != nil:
+			message = "No tracks in the playlists match the filters."
+		}
+		writeError(w, newAPIError(http.StatusBadRequest, CodeEmptyPool, message))
 		return
 	}
 
 	selectedTrack := selectRandomTrack(tracks)
+	var queue []models.Track
+	if mode == models.ModeBlitz {
+		rand.Shuffle(len(tracks), func(i, j int) {
+			tracks[i], tracks[j] = tracks[j], tracks[i]
+		})
+		selectedTrack, queue = tracks[0], tracks[1:]
+		if len(queue) > blitzQueueSize {
+			queue = queue[:blitzQueueSize]
+		}
+	}
 
 	sessionID, err := generateSessionID()
 	if err != nil {
-		http.Error(w, "Failed to generate session ID", http.StatusInternalServerError)
+		writeError(w, internalError("Failed to generate session ID").withCause(err))
 		return
 	}
 
 	session := models.NewGameSession(sessionID, user.ID, req.PlaylistIDs, selectedTrack)
+	session.Mode = mode
+	session.Queue = queue
+	session.PoolSearch = req.PoolSearch
+	session.Playback = playback
+	session.StartClock(h.now())
+	if mode == models.ModeChoice {
+		if session.Choices, err = newChoices(tracks, selectedTrack, numChoices); err != nil {
+			writeError(w, newAPIError(http.StatusBadRequest, CodeEmptyPool, "Playlists need at least two different tracks for multiple choice"))
+			return
+		}
+	}
 	if err := h.store.SaveSession(session); err != nil {
-		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		writeError(w, internalError("Failed to save session").withCause(err))
 		return
 	}
 
-	trackURI := fmt.Sprintf("spotify:track:%s", selectedTrack.ID)
+	if session.PoolSearch {
+		h.indexes.Put(session.ID, search.NewIndex(tracks))
+	}
+
+	h.broker.Publish(events.Event{
+		Type:      events.GameStarted,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Data:      gameStartedEvent{AudioDuration: session.GetAudioDuration()},
+	})
 
 	response := startGameResponse{
 		SessionID:     sessionID,
+		Mode:          session.Mode,
 		AudioDuration: session.GetAudioDuration(),
-		TrackURI:      trackURI,
+		TrackURI:      sessionTrackURI(session),
+		Choices:       newChoiceResponses(session.Choices),
+		RemainingMs:   remainingMillis(session, session.StartedAt),
+		PoolSearch:    session.PoolSearch,
+		PoolSize:      len(tracks),
+		Playback:      session.Playback,
+		ClipURL:       clipURL(session),
+		NoPreview:     stats.NoPreview,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// prepareStart applies the saved pool named in the request, then validates
+// its sources, filters and mode. It writes an error response and returns
+// false if they are invalid.
+func prepareStart(w http.ResponseWriter, store *storage.MemoryStore, userID string, req *startGameRequest) (models.GameMode, models.TrackFilter, bool) {
+	if req.PoolID != "" {
+		if len(req.PlaylistIDs) > 0 {
+			writeError(w, invalidRequest("Invalid request: give either poolId or playlistIds"))
+			return "", models.TrackFilter{}, false
+		}
+
+		pool, err := store.GetPool(req.PoolID)
+		if err != nil {
+			writeError(w, lookupError(err, "Pool not found"))
+			return "", models.TrackFilter{}, false
+		}
+
+		if pool.UserID != userID {
+			writeError(w, errForbidden)
+			return "", models.TrackFilter{}, false
+		}
+		req.applyPool(pool)
+	}
+
+	if len(req.PlaylistIDs) == 0 {
+		writeError(w, invalidRequest("At least one playlist ID required"))
+		return "", models.TrackFilter{}, false
+	}
+
+	if err := validateSources(req.PlaylistIDs); err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return "", models.TrackFilter{}, false
+	}
+
+	var filter models.TrackFilter
+	if req.Filters != nil {
+		filter = *req.Filters
+	}
+	if err := filter.Validate(); err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return "", models.TrackFilter{}, false
+	}
+
+	mode, err := models.ParseGameMode(req.Mode)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid game mode"))
+		return "", models.TrackFilter{}, false
+	}
+	return mode, filter, true
+}
+
+// applyPool takes the sources of a saved pool, and its rules and filters
+// where the request does not set them.
+func (req *startGameRequest) applyPool(pool *models.Pool) {
+	req.PlaylistIDs = pool.PlaylistIDs
+	if req.Mode == "" {
+		req.Mode = string(pool.Mode)
+	}
+	if req.Choices == 0 {
+		req.Choices = pool.Choices
+	}
+	req.PoolSearch = req.PoolSearch || pool.PoolSearch
+	if req.Filters == nil {
+		filters := pool.Filters
+		req.Filters = &filters
+	}
+}
+
 // HandleSubmitGuess processes a user's guess.
 func (h *GameHandler) HandleSubmitGuess(w http.ResponseWriter, r *http.Request) {
 	user, err := h.auth.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
 		return
 	}
 
 	var req submitGuessRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, errInvalidBody)
 		return
 	}
 
 	session, err := h.store.GetSession(req.SessionID)
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeError(w, lookupError(err, "Session not found"))
 		return
 	}
 
 	if session.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		writeError(w, errForbidden)
 		return
 	}
 
 	if session.IsComplete {
-		http.Error(w, "Game already complete", http.StatusBadRequest)
+		writeError(w, newAPIError(http.StatusBadRequest, CodeGameComplete, "Game already complete"))
 		return
 	}
 
-	isCorrect := req.TrackID == session.CorrectSong.ID
-	guess := models.Guess{
-		TrackID:   req.TrackID,
-		TrackName: req.TrackName,
-		IsCorrect: isCorrect,
+	now := h.now()
+	session, expired := h.expire(session, now)
+	if expired {
+		writeExpiredGuess(w, session, now)
+		return
 	}
 
-	session.AddGuess(guess)
-	h.store.SaveSession(session)
+	evaluate, ok := guessEvaluators[session.Mode]
+	if !ok {
+		writeError(w, internalError("Unsupported game mode"))
+		return
+	}
+
+	if guessesTracks(session.Mode) {
+		if req.TrackID == "" {
+			writeError(w, invalidRequest("Invalid guess: trackId is required"))
+			return
+		}
+		track, err := h.guessedTrack(r.Context(), user, session, req.TrackID)
+		if err != nil {
+			writeError(w, spotifyError(err, "Failed to look up the guessed track"))
+			return
+		}
+		req.guessed = &track
+	}
 
+	// The game may have ended or run out of time while the guessed track was
+	// looked up, so it is checked again under the store's lock, where the
+	// guess is recorded only if the game is still being played.
+	now = h.now()
+	var (
+		guess    models.Guess
+		previous models.Track
+		missed   []models.Guess
+		complete bool
+		invalid  error
+	)
+	session, err = h.store.UpdateSession(session.ID, func(session *models.GameSession) {
+		if session.IsComplete {
+			complete = true
+			return
+		}
+		if missed, expired = applyExpiry(session, now); expired {
+			return
+		}
+		if guess, invalid = evaluate(session, req); invalid != nil {
+			return
+		}
+		previous = session.CorrectSong
+		session.AddGuess(guess, now)
+		session.StartRound(now)
+	})
+	switch {
+	case err != nil:
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	case complete:
+		writeError(w, newAPIError(http.StatusBadRequest, CodeGameComplete, "Game already complete"))
+		return
+	case expired:
+		h.publishExpiry(session, missed)
+		writeExpiredGuess(w, session, now)
+		return
+	case invalid != nil:
+		writeError(w, invalidRequest("Invalid guess: "+invalid.Error()))
+		return
+	}
+	h.publishGuess(session, guess)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSubmitGuessResponse(session, guess, previous, now))
+}
+
+// writeExpiredGuess answers a guess that came after the session's deadline
+// with the state of the game once the missed deadline is counted.
+func writeExpiredGuess(w http.ResponseWriter, session *models.GameSession, now time.Time) {
+	// Expiry never changes the song, so it is still the one guessed at.
+	state := newSubmitGuessResponse(session, models.Guess{}, session.CorrectSong, now)
+	writeError(w, newAPIError(http.StatusConflict, CodeGameExpired, "Time ran out before the guess arrived").withDetails(state))
+}
+
+// guessedTrack returns the track a player guessed, from the game's pool index
+// if it has one, or from Spotify.
+func (h *GameHandler) guessedTrack(ctx context.Context, user *models.User, session *models.GameSession, trackID string) (models.Track, error) {
+	if index, ok := h.indexes.Get(session.ID); ok {
+		if track, ok := index.Track(trackID); ok {
+			return track, nil
+		}
+	}
+	return h.lookupTrack(ctx, user, trackID)
+}
+
+// newSubmitGuessResponse describes the session after a guess. previous is the
+// song the guess was made against: it is revealed once the game is over, or
+// in blitz mode once the game has moved on to the next song.
+func newSubmitGuessResponse(session *models.GameSession, guess models.Guess, previous models.Track, now time.Time) submitGuessResponse {
 	response := submitGuessResponse{
-		IsCorrect:     isCorrect,
+		IsCorrect:     guess.IsCorrect,
 		IsComplete:    session.IsComplete,
 		Won:           session.Won,
 		GuessesUsed:   session.GuessesUsed,
 		AudioDuration: session.GetAudioDuration(),
+		YearFeedback:  newYearFeedback(guess),
+		RemainingMs:   remainingMillis(session, now),
+		Solved:        session.Solved,
 	}
 
-	if session.IsComplete {
+	switch {
+	case session.IsComplete:
 		response.CorrectSong = &session.CorrectSong
+	case session.CorrectSong.ID != previous.ID:
+		response.CorrectSong = &previous
+		response.TrackURI = sessionTrackURI(session)
+		response.ClipURL = clipURL(session)
+	default:
+		// The clip of a preview game grows with every guess.
+		response.ClipURL = clipURL(session)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response
 }
 
-// HandleSkip skips the current game and reveals the answer.
+// HandleSkip gives up on the current song and reveals it. A blitz game moves
+// on to the next song, and any other game ends.
 func (h *GameHandler) HandleSkip(w http.ResponseWriter, r *http.Request) {
 	user, err := h.auth.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
 		return
 	}
 
 	var req skipRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, errInvalidBody)
 		return
 	}
 
 	session, err := h.store.GetSession(req.SessionID)
 	if err != nil {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeError(w, lookupError(err, "Session not found"))
 		return
 	}
 
 	if session.UserID != user.ID {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+		writeError(w, errForbidden)
+		return
+	}
+
+	if session.IsComplete {
+		writeError(w, newAPIError(http.StatusBadRequest, CodeGameComplete, "Game already complete"))
 		return
 	}
 
-	session.MarkComplete(false)
-	h.store.SaveSession(session)
+	// Missed deadlines are applied first, under the same lock as the skip,
+	// so a game that has just run out of time is not skipped as well.
+	now := h.now()
+	var (
+		skipped     models.Track
+		missed      []models.Guess
+		expired     bool
+		afterExpiry *models.GameSession
+		complete    bool
+	)
+	session, err = h.store.UpdateSession(session.ID, func(session *models.GameSession) {
+		if session.IsComplete {
+			complete = true
+			return
+		}
+		if missed, expired = applyExpiry(session, now); expired {
+			afterExpiry = session.Clone()
+		}
+		skipped = session.CorrectSong
+		if !session.IsComplete {
+			session.Skip(now)
+		}
+	})
+	switch {
+	case err != nil:
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	case complete:
+		writeError(w, newAPIError(http.StatusBadRequest, CodeGameComplete, "Game already complete"))
+		return
+	}
+	if expired {
+		h.publishExpiry(afterExpiry, missed)
+	}
+	if session.IsComplete && (!expired || !afterExpiry.IsComplete) {
+		h.publishCompleted(session)
+	}
 
 	response := skipResponse{
-		CorrectSong: session.CorrectSong,
+		CorrectSong:   skipped,
+		IsComplete:    session.IsComplete,
+		Won:           session.Won,
+		GuessesUsed:   session.GuessesUsed,
+		AudioDuration: session.GetAudioDuration(),
+		RemainingMs:   remainingMillis(session, now),
+		Solved:        session.Solved,
+	}
+	if !session.IsComplete {
+		response.TrackURI = sessionTrackURI(session)
+		response.ClipURL = clipURL(session)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleGetGame returns the state of a game session so a client can resume it.
+func (h *GameHandler) HandleGetGame(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	session, err := h.store.GetSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	}
+
+	if session.UserID != user.ID {
+		writeError(w, errForbidden)
+		return
+	}
+
+	now := h.now()
+	session, _ = h.expire(session, now)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newGameStateResponse(session, now))
+}
+
+// HandleGetCurrentGame returns the state of the user's game in progress.
+func (h *GameHandler) HandleGetCurrentGame(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	session, err := h.store.GetCurrentSession(user.ID)
+	if err != nil {
+		writeError(w, lookupError(err, "No game in progress"))
+		return
+	}
+
+	now := h.now()
+	session, _ = h.expire(session, now)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newGameStateResponse(session, now))
+}
+
+// newGameStateResponse builds the client view of a session, withholding the
+// answer until the game is complete.
+func newGameStateResponse(session *models.GameSession, now time.Time) gameStateResponse {
+	response := gameStateResponse{
+		SessionID:     session.ID,
+		Mode:          session.Mode,
+		TrackURI:      sessionTrackURI(session),
+		Playback:      session.Playback,
+		ClipURL:       clipURL(session),
+		Guesses:       newGuessResponses(session.Guesses),
+		GuessesUsed:   session.GuessesUsed,
+		MaxGuesses:    models.MaxGuesses,
+		PoolSearch:    session.PoolSearch,
+		Choices:       newChoiceResponses(session.Choices),
+		AudioDuration: session.GetAudioDuration(),
+		IsComplete:    session.IsComplete,
+		Won:           session.Won,
+		RemainingMs:   remainingMillis(session, now),
+		Solved:        session.Solved,
+	}
+
+	if session.IsComplete {
+		correctSong := session.CorrectSong
+		response.CorrectSong = &correctSong
+	}
+
+	return response
+}
+
+func newGuessResponses(guesses []models.Guess) []guessResponse {
+	responses := make([]guessResponse, len(guesses))
+	for i, guess := range guesses {
+		responses[i] = guessResponse{
+			Name:         guess.Label(),
+			TrackName:    guess.TrackName,
+			ChoiceID:     guess.ChoiceID,
+			IsCorrect:    guess.IsCorrect,
+			YearFeedback: newYearFeedback(guess),
+		}
+	}
+	return responses
+}
+
+// newYearFeedback returns the proximity feedback for a year guess, or nil for
+// guesses in other modes.
+func newYearFeedback(guess models.Guess) *yearFeedback {
+	if guess.Proximity == "" {
+		return nil
+	}
+	return &yearFeedback{
+		Proximity: guess.Proximity,
+		Direction: guess.Direction,
+	}
+}
+
+// remainingMillis returns the time left on a timed session's deadline, or nil
+// for untimed sessions.
+func remainingMillis(session *models.GameSession, now time.Time) *int64 {
+	if !session.IsTimed() {
+		return nil
+	}
+	ms := session.TimeRemaining(now).Milliseconds()
+	return &ms
+}
+
+// expire applies the deadlines the session missed by now, if any, and
+// publishes the resulting events. It returns the session as updated and
+// whether it had expired. The deadline is checked and applied under the
+// store's lock, so when the state and guess requests both notice it has
+// passed, only one of them applies it.
+func (h *GameHandler) expire(session *models.GameSession, now time.Time) (*models.GameSession, bool) {
+	// A deadline only ever moves later, so a session that had not expired
+	// when it was read cannot have expired in the store.
+	if !session.Expired(now) {
+		return session, false
+	}
+
+	var missed []models.Guess
+	expired := false
+	updated, err := h.store.UpdateSession(session.ID, func(session *models.GameSession) {
+		missed, expired = applyExpiry(session, now)
+	})
+	if err != nil {
+		return session, false
+	}
+	if expired {
+		h.publishExpiry(updated, missed)
+	}
+	return updated, expired
+}
+
+// applyExpiry applies the deadlines the session missed by now, returning the
+// timed-out guesses recorded and whether there were any deadlines to apply.
+// It is called from store.UpdateSession, under the store's lock.
+func applyExpiry(session *models.GameSession, now time.Time) ([]models.Guess, bool) {
+	if !session.Expired(now) {
+		return nil, false
+	}
+	before := len(session.Guesses)
+	session.Expire(now)
+	return slices.Clone(session.Guesses[before:]), true
+}
+
+// publishExpiry emits the events of the deadlines a session missed: the
+// timed-out guesses of a timed game, or the end of a blitz game.
+func (h *GameHandler) publishExpiry(session *models.GameSession, missed []models.Guess) {
+	if session.Mode == models.ModeTimed {
+		for _, guess := range missed {
+			h.publishGuess(session, guess)
+		}
+		return
+	}
+	h.publishCompleted(session)
+}
+
+// sessionTrackURI returns the URI the SDK plays the current song from. It is
+// withheld from preview games, which play clips instead, since it names the
+// track, and from choice games, where it would single out the right choice.
+func sessionTrackURI(session *models.GameSession) string {
+	if session.Playback == models.PlaybackPreview || session.Mode == models.ModeChoice {
+		return ""
+	}
+	return trackURI(session.CorrectSong)
+}
+
+func trackURI(track models.Track) string {
+	if track.LinkedID != "" {
+		return fmt.Sprintf("spotify:track:%s", track.LinkedID)
+	}
+	switch {
+	case track.IsLocal:
+		return track.ID
+	case track.IsEpisode:
+		return fmt.Sprintf("spotify:episode:%s", track.ID)
+	}
+	return fmt.Sprintf("spotify:track:%s", track.ID)
+}
+
+// choosePlayback validates the requested playback method, defaulting to the
+// user's. Only users who can stream full tracks may ask for the SDK. Choice
+// games always play preview clips: the SDK needs the track's URI, which
+// would give away the answer next to the choices.
+func choosePlayback(user *models.User, requested string, mode models.GameMode) (models.Playback, error) {
+	playback, err := models.ParsePlayback(requested)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case mode == models.ModeChoice && playback == models.PlaybackSDK:
+		return "", fmt.Errorf("choice mode plays preview clips")
+	case mode == models.ModeChoice:
+		return models.PlaybackPreview, nil
+	case playback == "":
+		return user.DefaultPlayback(), nil
+	case playback == models.PlaybackSDK && !user.CanStream():
+		return "", fmt.Errorf("sdk playback needs Spotify Premium")
+	}
+	return playback, nil
+}
+
+type gameStartedEvent struct {
+	AudioDuration int `json:"audioDuration"`
+}
+
+type guessRecordedEvent struct {
+	Name        string `json:"name"`
+	IsCorrect   bool   `json:"isCorrect"`
+	GuessesUsed int    `json:"guessesUsed"`
+}
+
+type hintRevealedEvent struct {
+	AudioDuration int `json:"audioDuration"`
+}
+
+type gameCompletedEvent struct {
+	Won         bool         `json:"won"`
+	GuessesUsed int          `json:"guessesUsed"`
+	CorrectSong models.Track `json:"correctSong"`
+}
+
+// publishGuess emits the events caused by a recorded guess: the guess itself,
+// then either the longer clip it unlocked or the end of the game.
+func (h *GameHandler) publishGuess(session *models.GameSession, guess models.Guess) {
+	h.broker.Publish(events.Event{
+		Type:      events.GuessRecorded,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Data: guessRecordedEvent{
+			Name:        guess.Label(),
+			IsCorrect:   guess.IsCorrect,
+			GuessesUsed: session.GuessesUsed,
+		},
+	})
+
+	if session.IsComplete {
+		h.publishCompleted(session)
+		return
+	}
+
+	h.broker.Publish(events.Event{
+		Type:      events.HintRevealed,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Data:      hintRevealedEvent{AudioDuration: session.GetAudioDuration()},
+	})
+}
+
+// publishCompleted announces the end of a game. The game's pool index is no
+// longer needed at that point, so it is dropped here too.
+func (h *GameHandler) publishCompleted(session *models.GameSession) {
+	h.indexes.Delete(session.ID)
+	h.broker.Publish(events.Event{
+		Type:      events.GameCompleted,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Data: gameCompletedEvent{
+			Won:         session.Won,
+			GuessesUsed: session.GuessesUsed,
+			CorrectSong: session.CorrectSong,
+		},
+	})
+}
+
+// filterTracksWithPreview keeps the tracks that have a preview clip, for
+// games played without the SDK.
 func filterTracksWithPreview(tracks []models.Track) []models.Track {
 	filtered := make([]models.Track, 0)
 	for _, track := range tracks {
@@ -3,12 +3,19 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"spotify-heardle/config"
+	"spotify-heardle/events"
 	"spotify-heardle/models"
+	"spotify-heardle/search"
+	"spotify-heardle/spotify"
 	"spotify-heardle/storage"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewGameHandler(t *testing.T) {
@@ -21,13 +28,45 @@ func TestNewGameHandler(t *testing.T) {
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
 
-	handler := NewGameHandler(authHandler, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
 
 	if handler == nil {
 		t.Fatal("NewGameHandler() returned nil")
 	}
 }
 
+// stubTracks makes the handler look guessed tracks up in tracks rather than
+// on Spotify.
+func stubTracks(handler *GameHandler, tracks ...models.Track) {
+	handler.lookupTrack = func(_ context.Context, _ *models.User, trackID string) (models.Track, error) {
+		for _, track := range tracks {
+			if track.ID == trackID {
+				return track, nil
+			}
+		}
+		return models.Track{}, &spotify.APIError{Status: http.StatusNotFound, Message: "Not found"}
+	}
+}
+
+// decodeExpiredGuess decodes the error answering a guess made too late and
+// returns the game state in its details.
+func decodeExpiredGuess(t *testing.T, w *httptest.ResponseRecorder) submitGuessResponse {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code    ErrorCode           `json:"code"`
+			Details submitGuessResponse `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error: %v", err)
+	}
+	if body.Error.Code != CodeGameExpired {
+		t.Errorf("error code = %q, want %q", body.Error.Code, CodeGameExpired)
+	}
+	return body.Error.Details
+}
+
 func TestHandleStartGameNoAuth(t *testing.T) {
 	cfg := &config.Config{
 		SpotifyClientID:     "test_id",
@@ -37,7 +76,7 @@ func TestHandleStartGameNoAuth(t *testing.T) {
 	}
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
-	handler := NewGameHandler(authHandler, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
 
 	body := bytes.NewBufferString(`{"playlistIds":["playlist123"]}`)
 	req := httptest.NewRequest("POST", "/api/game/start", body)
@@ -50,6 +89,154 @@ func TestHandleStartGameNoAuth(t *testing.T) {
 	}
 }
 
+func TestHandleStartGameInvalidSource(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	for _, body := range []string{
+		`{"playlistIds":["liked","top:forever"]}`,
+		`{"playlistIds":["liked"],"filters":{"minYear":1990,"maxYear":1980}}`,
+		`{"playlistIds":["liked"],"playback":"radio"}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/game/start", bytes.NewBufferString(body))
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleStartGame(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTrackURI(t *testing.T) {
+	tests := []struct {
+		track models.Track
+		want  string
+	}{
+		{models.Track{ID: "abc"}, "spotify:track:abc"},
+		{models.Track{ID: "abc", IsEpisode: true}, "spotify:episode:abc"},
+		{models.Track{ID: "abc", LinkedID: "def"}, "spotify:track:def"},
+		{models.Track{ID: "spotify:local:Artist:Album:Song:180", IsLocal: true}, "spotify:local:Artist:Album:Song:180"},
+	}
+
+	for _, tt := range tests {
+		if got := trackURI(tt.track); got != tt.want {
+			t.Errorf("trackURI(%+v) = %q, want %q", tt.track, got, tt.want)
+		}
+	}
+}
+
+func TestChoosePlayback(t *testing.T) {
+	premium := &models.User{Product: "premium"}
+	free := &models.User{Product: "free"}
+
+	tests := []struct {
+		user      *models.User
+		requested string
+		mode      models.GameMode
+		want      models.Playback
+		wantErr   bool
+	}{
+		{premium, "", models.ModeTrack, models.PlaybackSDK, false},
+		{premium, "preview", models.ModeTrack, models.PlaybackPreview, false},
+		{free, "", models.ModeTrack, models.PlaybackPreview, false},
+		{free, "preview", models.ModeTrack, models.PlaybackPreview, false},
+		{free, "sdk", models.ModeTrack, "", true},
+		{premium, "radio", models.ModeTrack, "", true},
+		{premium, "", models.ModeChoice, models.PlaybackPreview, false},
+		{premium, "sdk", models.ModeChoice, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := choosePlayback(tt.user, tt.requested, tt.mode)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("choosePlayback(%q, %q, %q) = %q, %v", tt.user.Product, tt.requested, tt.mode, got, err)
+		}
+	}
+}
+
+func TestPreviewGameWithholdsTrack(t *testing.T) {
+	track := models.Track{ID: "abc", PreviewURL: "https://p.scdn.co/mp3-preview/abc"}
+	session := models.NewGameSession("session1", "user1", []string{"liked"}, track)
+
+	if response := newGameStateResponse(session, time.Now()); response.TrackURI != "spotify:track:abc" || response.ClipURL != "" {
+		t.Errorf("SDK game state = %+v, want a track URI only", response)
+	}
+
+	session.Playback = models.PlaybackPreview
+	response := newGameStateResponse(session, time.Now())
+	if response.TrackURI != "" || response.ClipURL != "/api/game/session1/audio?guesses=0" {
+		t.Errorf("preview game state = %+v, want a clip URL only", response)
+	}
+}
+
+func TestHandleStartGameWithPool(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+	store.SavePool(models.NewPool("pool1", "user2", "Road trip", []string{"liked"}))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing pool", `{"poolId":"missing"}`, http.StatusNotFound},
+		{"another user's pool", `{"poolId":"pool1"}`, http.StatusForbidden},
+		{"pool and playlists", `{"poolId":"pool1","playlistIds":["liked"]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/game/start", bytes.NewBufferString(tt.body))
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandleStartGame(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartGameRequestApplyPool(t *testing.T) {
+	pool := models.NewPool("pool1", "user1", "Road trip", []string{"liked"})
+	pool.Mode = models.ModeChoice
+	pool.Choices = 6
+	pool.Filters = models.TrackFilter{MinYear: 1980}
+
+	req := startGameRequest{PoolID: "pool1"}
+	req.applyPool(pool)
+	if len(req.PlaylistIDs) != 1 || req.Mode != "choice" || req.Choices != 6 || req.Filters == nil || req.Filters.MinYear != 1980 {
+		t.Errorf("applyPool() = %+v, want the pool's sources, rules and filters", req)
+	}
+
+	req = startGameRequest{PoolID: "pool1", Mode: "track", Choices: 3, Filters: &models.TrackFilter{MaxYear: 1990}}
+	req.applyPool(pool)
+	if req.Mode != "track" || req.Choices != 3 || req.Filters.MinYear != 0 {
+		t.Errorf("applyPool() = %+v, want the request's rules and filters kept", req)
+	}
+}
+
 func TestSelectRandomTrack(t *testing.T) {
 	tracks := []models.Track{
 		{ID: "track1", Name: "Song 1", PreviewURL: "http://preview1"},
@@ -106,7 +293,7 @@ func TestHandleSubmitGuessNoSession(t *testing.T) {
 	}
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
-	handler := NewGameHandler(authHandler, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
 
 	body := bytes.NewBufferString(`{"sessionId":"session123","trackId":"track1","trackName":"Song"}`)
 	req := httptest.NewRequest("POST", "/api/game/guess", body)
@@ -128,7 +315,7 @@ func TestHandleSkipNoSession(t *testing.T) {
 	}
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
-	handler := NewGameHandler(authHandler, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
 
 	body := bytes.NewBufferString(`{"sessionId":"session123"}`)
 	req := httptest.NewRequest("POST", "/api/game/skip", body)
@@ -140,3 +327,606 @@ func TestHandleSkipNoSession(t *testing.T) {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
 	}
 }
+
+func TestHandleSubmitGuessPublishesEvents(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	broker := events.NewBroker()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, broker, search.NewIndexes(search.DefaultIndexTTL))
+	stubTracks(handler, models.Track{ID: "wrong", Name: "Wrong Song"})
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+	subscription, unsubscribe := broker.Subscribe(events.UserTopic("user1"))
+	defer unsubscribe()
+
+	body := bytes.NewBufferString(`{"sessionId":"session123","trackId":"wrong","trackName":"Wrong Song"}`)
+	req := httptest.NewRequest("POST", "/api/game/guess", body)
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	want := []events.Type{events.GuessRecorded, events.HintRevealed}
+	for _, wantType := range want {
+		select {
+		case event := <-subscription:
+			if event.Type != wantType {
+				t.Errorf("event.Type = %q, want %q", event.Type, wantType)
+			}
+		default:
+			t.Fatalf("missing %q event", wantType)
+		}
+	}
+}
+
+func TestHandleGetGameHidesAnswerUntilComplete(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1", Name: "Answer"})
+	session.AddGuess(models.Guess{TrackID: "wrong", TrackName: "Wrong Song"}, time.Now())
+	store.SaveSession(session)
+	cookie := loginTestUser(t, store, "user1")
+
+	req := httptest.NewRequest("GET", "/api/game/session123", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetGame(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var state gameStateResponse
+	if err := json.NewDecoder(w.Body).Decode(&state); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if state.GuessesUsed != 1 || len(state.Guesses) != 1 {
+		t.Errorf("GuessesUsed = %d, len(Guesses) = %d, want 1 and 1", state.GuessesUsed, len(state.Guesses))
+	}
+
+	if state.AudioDuration != 2 {
+		t.Errorf("AudioDuration = %d, want 2", state.AudioDuration)
+	}
+
+	if state.CorrectSong != nil {
+		t.Error("CorrectSong revealed for game in progress")
+	}
+
+	session.MarkComplete(false, time.Now())
+	store.SaveSession(session)
+
+	w = httptest.NewRecorder()
+	handler.HandleGetGame(w, req)
+
+	state = gameStateResponse{}
+	json.NewDecoder(w.Body).Decode(&state)
+
+	if state.CorrectSong == nil || state.CorrectSong.ID != "track1" {
+		t.Errorf("CorrectSong = %+v, want track1 after completion", state.CorrectSong)
+	}
+}
+
+func TestHandleGetGameOtherUser(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+
+	store.SaveSession(models.NewGameSession("session123", "owner", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	req := httptest.NewRequest("GET", "/api/game/session123", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(loginTestUser(t, store, "intruder"))
+	w := httptest.NewRecorder()
+
+	handler.HandleGetGame(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandleGetCurrentGame(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	req := httptest.NewRequest("GET", "/api/game/current", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetCurrentGame(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d with no game in progress", w.Code, http.StatusNotFound)
+	}
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	w = httptest.NewRecorder()
+	handler.HandleGetCurrentGame(w, req)
+
+	var state gameStateResponse
+	json.NewDecoder(w.Body).Decode(&state)
+
+	if state.SessionID != "session123" {
+		t.Errorf("SessionID = %q, want %q", state.SessionID, "session123")
+	}
+}
+
+func TestHandleSubmitGuessArtistMode(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1", ArtistIDs: []string{"artist1"}})
+	session.Mode = models.ModeArtist
+	store.SaveSession(session)
+
+	body := bytes.NewBufferString(`{"sessionId":"session123","trackId":"track1"}`)
+	req := httptest.NewRequest("POST", "/api/game/guess", body)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d for track guess in artist mode", w.Code, http.StatusBadRequest)
+	}
+
+	body = bytes.NewBufferString(`{"sessionId":"session123","artistId":"artist1","artistName":"Artist"}`)
+	req = httptest.NewRequest("POST", "/api/game/guess", body)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	var response submitGuessResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if !response.IsCorrect || !response.Won {
+		t.Errorf("response = %+v, want correct winning guess", response)
+	}
+}
+
+func TestHandleSubmitGuessYearMode(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1", ReleaseDate: "1999-04-01"})
+	session.Mode = models.ModeYear
+	store.SaveSession(session)
+
+	body := bytes.NewBufferString(`{"sessionId":"session123","year":1996}`)
+	req := httptest.NewRequest("POST", "/api/game/guess", body)
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	var response submitGuessResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if response.IsCorrect {
+		t.Error("IsCorrect = true, want false")
+	}
+
+	if response.YearFeedback == nil {
+		t.Fatal("YearFeedback = nil, want feedback")
+	}
+
+	if response.YearFeedback.Proximity != models.ProximityWithin5 || response.YearFeedback.Direction != models.DirectionHigher {
+		t.Errorf("YearFeedback = %+v, want within5 and higher", response.YearFeedback)
+	}
+}
+
+func TestHandleSubmitGuessTimedMode(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	stubTracks(handler, models.Track{ID: "wrong", Name: "Wrong Song"})
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	handler.now = func() time.Time { return now }
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"})
+	session.Mode = models.ModeTimed
+	session.StartClock(start)
+	store.SaveSession(session)
+
+	submit := func() (int, submitGuessResponse) {
+		body := bytes.NewBufferString(`{"sessionId":"session123","trackId":"wrong"}`)
+		req := httptest.NewRequest("POST", "/api/game/guess", body)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleSubmitGuess(w, req)
+
+		if w.Code != http.StatusOK {
+			return w.Code, decodeExpiredGuess(t, w)
+		}
+		var response submitGuessResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	now = start.Add(5 * time.Second)
+	code, response := submit()
+	if code != http.StatusOK {
+		t.Fatalf("on-time guess: status %d, want 200", code)
+	}
+	if response.RemainingMs == nil || *response.RemainingMs != models.TimedRoundDuration.Milliseconds() {
+		t.Errorf("remainingMs = %v, want a fresh round of %d", response.RemainingMs, models.TimedRoundDuration.Milliseconds())
+	}
+
+	now = now.Add(models.TimedRoundDuration + time.Second)
+	code, response = submit()
+	if code != http.StatusConflict {
+		t.Fatalf("late guess: status %d, want 409", code)
+	}
+	if response.GuessesUsed != 2 || response.IsCorrect {
+		t.Errorf("late guess response = %+v, want the round counted as a miss", response)
+	}
+}
+
+func TestHandleSubmitGuessBlitzMode(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return start.Add(10 * time.Second) }
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1", Name: "First"})
+	stubTracks(handler, session.CorrectSong)
+	session.Mode = models.ModeBlitz
+	session.Queue = []models.Track{{ID: "track2"}}
+	session.StartClock(start)
+	store.SaveSession(session)
+
+	body := bytes.NewBufferString(`{"sessionId":"session123","trackId":"track1"}`)
+	req := httptest.NewRequest("POST", "/api/game/guess", body)
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	var response submitGuessResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if !response.IsCorrect || response.IsComplete || response.Solved != 1 {
+		t.Errorf("response = %+v, want a solved song and the game still running", response)
+	}
+	if response.TrackURI != "spotify:track:track2" {
+		t.Errorf("trackUri = %q, want the next song", response.TrackURI)
+	}
+	if response.CorrectSong == nil || response.CorrectSong.Name != "First" {
+		t.Errorf("correctSong = %+v, want the solved song revealed", response.CorrectSong)
+	}
+	if response.RemainingMs == nil || *response.RemainingMs != 50000 {
+		t.Errorf("remainingMs = %v, want 50000", response.RemainingMs)
+	}
+}
+
+func TestHandleSkipBlitzMode(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return start.Add(10 * time.Second) }
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1", Name: "First"})
+	session.Mode = models.ModeBlitz
+	session.Queue = []models.Track{{ID: "track2", Name: "Second"}}
+	session.StartClock(start)
+	session.AddGuess(models.Guess{TrackID: "wrong"}, time.Now())
+	store.SaveSession(session)
+
+	skip := func() skipResponse {
+		req := httptest.NewRequest("POST", "/api/game/skip", bytes.NewBufferString(`{"sessionId":"session123"}`))
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleSkip(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var response skipResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return response
+	}
+
+	response := skip()
+	if response.IsComplete || response.CorrectSong.Name != "First" || response.TrackURI != "spotify:track:track2" {
+		t.Errorf("first skip = %+v, want First revealed and the game moved on to track2", response)
+	}
+	if response.RemainingMs == nil || *response.RemainingMs != 50000 {
+		t.Errorf("remainingMs = %v, want 50000", response.RemainingMs)
+	}
+	if stored, _ := store.GetSession("session123"); len(stored.Guesses) != 1 {
+		t.Errorf("guesses = %+v, want the first song's guess kept", stored.Guesses)
+	}
+
+	response = skip()
+	if !response.IsComplete || response.CorrectSong.Name != "Second" {
+		t.Errorf("last skip = %+v, want Second revealed and the game over", response)
+	}
+}
+
+func TestExpireAppliesOnce(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return start.Add(models.TimedRoundDuration + time.Second) }
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"})
+	session.Mode = models.ModeTimed
+	session.StartClock(start)
+	store.SaveSession(session)
+
+	// Requests noticing the same missed deadline count it once between them.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/game/session123", nil)
+			req.SetPathValue("id", "session123")
+			req.AddCookie(cookie)
+			handler.HandleGetGame(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	session, _ = store.GetSession("session123")
+	if session.GuessesUsed != 1 {
+		t.Errorf("GuessesUsed = %d, want the missed round counted once", session.GuessesUsed)
+	}
+}
+
+func TestHandleSubmitGuessJudgesRealTrack(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+	stubTracks(handler, models.Track{ID: "wrong", Name: "Other Song", Artists: []string{"Other Artist"}})
+
+	answer := models.Track{ID: "track1", Name: "Heroes", Artists: []string{"David Bowie"}}
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, answer))
+
+	guess := func(body string) (int, submitGuessResponse) {
+		req := httptest.NewRequest("POST", "/api/game/guess", bytes.NewBufferString(body))
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleSubmitGuess(w, req)
+
+		var response submitGuessResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response
+	}
+
+	// The answer's name and artist with another track's ID is that track.
+	code, response := guess(`{"sessionId":"session123","trackId":"wrong","trackName":"Heroes","artists":["David Bowie"]}`)
+	if code != http.StatusOK || response.IsCorrect {
+		t.Errorf("made-up guess: status %d, correct %v, want 200 and incorrect", code, response.IsCorrect)
+	}
+	session, _ := store.GetSession("session123")
+	if recorded := session.Guesses[0]; recorded.TrackName != "Other Song" || recorded.Artists[0] != "Other Artist" {
+		t.Errorf("recorded guess = %+v, want the real track's details", recorded)
+	}
+
+	if code, _ := guess(`{"sessionId":"session123","trackName":"Heroes","artists":["David Bowie"]}`); code != http.StatusBadRequest {
+		t.Errorf("guess without trackId: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code, _ := guess(`{"sessionId":"session123","trackId":"unknown","trackName":"Heroes","artists":["David Bowie"]}`); code != http.StatusNotFound {
+		t.Errorf("guess of an unknown track: status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestGuessedTrackFromPoolIndex(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	indexes := search.NewIndexes(search.DefaultIndexTTL)
+	handler := NewGameHandler(NewAuthHandler(cfg, store), store, events.NewBroker(), indexes)
+	stubTracks(handler)
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"})
+	indexes.Put(session.ID, search.NewIndex([]models.Track{{ID: "track2", Name: "Pool Song"}}))
+
+	track, err := handler.guessedTrack(context.Background(), &models.User{ID: "user1"}, session, "track2")
+	if err != nil || track.Name != "Pool Song" {
+		t.Errorf("guessedTrack() = %+v, %v, want the track from the pool index", track, err)
+	}
+}
+
+func TestHandleSubmitGuessAfterLateLookup(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start.Add(models.TimedRoundDuration - time.Second)
+	handler.now = func() time.Time { return now }
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"})
+	session.Mode = models.ModeTimed
+	session.StartClock(start)
+	session.AddGuess(models.Guess{TrackID: "wrong"}, start)
+	session.AddGuess(models.Guess{TrackID: "wrong"}, start)
+	store.SaveSession(session)
+
+	// The last round runs out while the guessed track is looked up.
+	handler.lookupTrack = func(_ context.Context, _ *models.User, trackID string) (models.Track, error) {
+		now = start.Add(models.TimedRoundDuration + time.Second)
+		return models.Track{ID: trackID}, nil
+	}
+
+	req := httptest.NewRequest("POST", "/api/game/guess", bytes.NewBufferString(`{"sessionId":"session123","trackId":"track1"}`))
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleSubmitGuess(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	} else if state := decodeExpiredGuess(t, w); !state.IsComplete {
+		t.Errorf("expired guess details = %+v, want the lost game", state)
+	}
+	session, _ = store.GetSession("session123")
+	if session.Won || session.GuessesUsed != models.MaxGuesses || !session.Guesses[2].TimedOut {
+		t.Errorf("session = won %v, guesses %+v, want lost on time with the late guess left out", session.Won, session.Guesses)
+	}
+}
+
+func TestHandleSubmitGuessConcurrentReads(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewGameHandler(authHandler, store, events.NewBroker(), search.NewIndexes(search.DefaultIndexTTL))
+	cookie := loginTestUser(t, store, "user1")
+	stubTracks(handler, models.Track{ID: "wrong", Name: "Wrong Song"})
+
+	session := models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"})
+	session.Mode = models.ModeBlitz
+	session.Queue = []models.Track{{ID: "track2"}, {ID: "track3"}}
+	session.StartClock(handler.now())
+	store.SaveSession(session)
+
+	// Run with -race: guesses change the session while the state is read.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/api/game/guess", bytes.NewBufferString(`{"sessionId":"session123","trackId":"wrong"}`))
+			req.AddCookie(cookie)
+			handler.HandleSubmitGuess(httptest.NewRecorder(), req)
+		}()
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/api/game/session123", nil)
+			req.SetPathValue("id", "session123")
+			req.AddCookie(cookie)
+			handler.HandleGetGame(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	session, _ = store.GetSession("session123")
+	if len(session.Guesses) != 4 {
+		t.Errorf("len(Guesses) = %d, want every guess recorded", len(session.Guesses))
+	}
+}
@@ -0,0 +1,165 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"strconv"
+	"time"
+)
+
+// HistoryHandler handles game history routes.
+type HistoryHandler struct {
+	auth  *AuthHandler
+	store *storage.MemoryStore
+}
+
+type historyResponse struct {
+	Games      []historyEntry `json:"games"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type historyEntry struct {
+	SessionID   string          `json:"sessionId"`
+	Mode        models.GameMode `json:"mode"`
+	PlaylistIDs []string        `json:"playlistIds"`
+	CorrectSong models.Track    `json:"correctSong"`
+	Guesses     []guessResponse `json:"guesses"`
+	// GuessesUsed counts the guesses on the song, or every guess of a blitz
+	// game, which reports the songs it solved in Solved instead and whose
+	// CorrectSong is the last song played.
+	GuessesUsed int       `json:"guessesUsed"`
+	Solved      int       `json:"solved,omitempty"`
+	Won         bool      `json:"won"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// NewHistoryHandler creates a new history handler.
+func NewHistoryHandler(auth *AuthHandler, store *storage.MemoryStore) *HistoryHandler {
+	return &HistoryHandler{
+		auth:  auth,
+		store: store,
+	}
+}
+
+// HandleGetHistory lists the user's completed games, newest first.
+//
+// Supported query parameters are from and to (RFC 3339 or YYYY-MM-DD),
+// playlist, mode, outcome (won or lost), cursor and limit.
+func (h *HistoryHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	query, err := parseHistoryQuery(r)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid query parameter: "+err.Error()))
+		return
+	}
+	query.UserID = user.ID
+
+	sessions, nextCursor, err := h.store.ListCompletedSessions(query)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid cursor"))
+		return
+	}
+
+	response := historyResponse{
+		Games:      make([]historyEntry, len(sessions)),
+		NextCursor: nextCursor,
+	}
+	for i, session := range sessions {
+		response.Games[i] = newHistoryEntry(session)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseHistoryQuery(r *http.Request) (storage.SessionQuery, error) {
+	params := r.URL.Query()
+	query := storage.SessionQuery{
+		PlaylistID: params.Get("playlist"),
+		Cursor:     params.Get("cursor"),
+	}
+
+	var err error
+	if query.From, err = parseDateParam(params.Get("from"), false); err != nil {
+		return query, errors.New("from must be RFC 3339 or YYYY-MM-DD")
+	}
+	if query.To, err = parseDateParam(params.Get("to"), true); err != nil {
+		return query, errors.New("to must be RFC 3339 or YYYY-MM-DD")
+	}
+
+	if mode := params.Get("mode"); mode != "" {
+		if query.Mode, err = models.ParseGameMode(mode); err != nil {
+			return query, errors.New("mode must be track, artist, album, year, choice, timed or blitz")
+		}
+	}
+
+	switch params.Get("outcome") {
+	case "":
+	case "won":
+		won := true
+		query.Won = &won
+	case "lost":
+		won := false
+		query.Won = &won
+	default:
+		return query, errors.New("outcome must be won or lost")
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			return query, errors.New("limit must be a positive integer")
+		}
+	}
+
+	return query, nil
+}
+
+// parseDateParam parses an RFC 3339 timestamp or a calendar date. A date used
+// as an upper bound covers the whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func newHistoryEntry(session *models.GameSession) historyEntry {
+	entry := historyEntry{
+		SessionID:   session.ID,
+		Mode:        session.Mode,
+		PlaylistIDs: session.PlaylistIDs,
+		CorrectSong: session.CorrectSong,
+		Guesses:     newGuessResponses(session.Guesses),
+		GuessesUsed: session.GuessesUsed,
+		Won:         session.Won,
+		StartedAt:   session.StartedAt,
+		CompletedAt: session.CompletedAt,
+	}
+	if session.Mode == models.ModeBlitz {
+		entry.GuessesUsed = len(session.Guesses)
+		entry.Solved = session.Solved
+	}
+	return entry
+}
@@ -0,0 +1,127 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"testing"
+	"time"
+)
+
+func TestHandleGetHistoryNoAuth(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewHistoryHandler(authHandler, store)
+
+	req := httptest.NewRequest("GET", "/api/history", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetHistory(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleGetHistory(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewHistoryHandler(authHandler, store)
+	cookie := loginTestUser(t, store, "user1")
+
+	completedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.SaveSession(&models.GameSession{ID: "won", UserID: "user1", PlaylistIDs: []string{"p1"}, IsComplete: true, Won: true, CompletedAt: completedAt})
+	store.SaveSession(&models.GameSession{ID: "lost", UserID: "user1", PlaylistIDs: []string{"p1"}, IsComplete: true, CompletedAt: completedAt.AddDate(0, 0, 1)})
+	store.SaveSession(&models.GameSession{ID: "playing", UserID: "user1", PlaylistIDs: []string{"p1"}})
+
+	req := httptest.NewRequest("GET", "/api/history?outcome=won&from=2024-05-01&to=2024-05-01", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var response historyResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(response.Games) != 1 || response.Games[0].SessionID != "won" {
+		t.Errorf("games = %+v, want only the won game", response.Games)
+	}
+}
+
+func TestNewHistoryEntryBlitz(t *testing.T) {
+	session := &models.GameSession{
+		ID:          "blitz",
+		Mode:        models.ModeBlitz,
+		Guesses:     []models.Guess{{IsCorrect: true}, {}, {IsCorrect: true}, {}},
+		GuessesUsed: 1,
+		Solved:      2,
+		Won:         true,
+		IsComplete:  true,
+	}
+
+	entry := newHistoryEntry(session)
+	if entry.Solved != 2 || entry.GuessesUsed != 4 {
+		t.Errorf("entry = solved %d, guesses %d, want 2 and every guess of the game", entry.Solved, entry.GuessesUsed)
+	}
+
+	entry = newHistoryEntry(&models.GameSession{ID: "track", GuessesUsed: 2, Won: true})
+	if entry.Solved != 0 || entry.GuessesUsed != 2 {
+		t.Errorf("entry = solved %d, guesses %d, want 0 and 2 outside blitz", entry.Solved, entry.GuessesUsed)
+	}
+}
+
+func TestHandleGetHistoryInvalidParams(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewHistoryHandler(authHandler, store)
+	cookie := loginTestUser(t, store, "user1")
+
+	tests := []string{
+		"/api/history?outcome=draw",
+		"/api/history?from=yesterday",
+		"/api/history?limit=-1",
+		"/api/history?cursor=bogus",
+	}
+
+	for _, target := range tests {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", target, nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandleGetHistory(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
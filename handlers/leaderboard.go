@@ -0,0 +1,167 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"spotify-heardle/leaderboard"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LeaderboardHandler handles leaderboard routes.
+type LeaderboardHandler struct {
+	auth    *AuthHandler
+	store   *storage.MemoryStore
+	service *leaderboard.Service
+}
+
+type leaderboardResponse struct {
+	Metric   leaderboard.Metric `json:"metric"`
+	Window   leaderboard.Window `json:"window"`
+	Mode     models.GameMode    `json:"mode,omitempty"`
+	Scope    string             `json:"scope"`
+	MinGames int                `json:"minGames,omitempty"`
+	Entries  []leaderboardEntry `json:"entries"`
+}
+
+type leaderboardEntry struct {
+	leaderboard.Entry
+	IsYou bool `json:"isYou"`
+}
+
+type privacyRequest struct {
+	OptOut bool `json:"optOut"`
+}
+
+// NewLeaderboardHandler creates a new leaderboard handler.
+func NewLeaderboardHandler(auth *AuthHandler, store *storage.MemoryStore, service *leaderboard.Service) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		auth:    auth,
+		store:   store,
+		service: service,
+	}
+}
+
+// HandleGetLeaderboard returns a ranked leaderboard.
+//
+// Supported query parameters are metric (score, winRate or streak), window
+// (all, week or month), mode, scope (global or pool), pool (comma-separated
+// playlist IDs, required for the pool scope), minGames and limit.
+func (h *LeaderboardHandler) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := leaderboard.Query{
+		Metric: leaderboard.Metric(params.Get("metric")),
+		Window: leaderboard.Window(params.Get("window")),
+		Now:    time.Now(),
+	}
+	if query.Metric == "" {
+		query.Metric = leaderboard.MetricScore
+	}
+	if query.Window == "" {
+		query.Window = leaderboard.WindowAll
+	}
+
+	if mode := params.Get("mode"); mode != "" {
+		if query.Mode, err = models.ParseGameMode(mode); err != nil {
+			writeError(w, invalidRequest("Invalid mode parameter"))
+			return
+		}
+	}
+
+	scope := params.Get("scope")
+	switch scope {
+	case "", "global":
+		scope = "global"
+	case "pool":
+		pool := params.Get("pool")
+		if pool == "" {
+			writeError(w, invalidRequest("Missing pool parameter"))
+			return
+		}
+		query.PoolKey = models.NewPoolKey(strings.Split(pool, ","))
+	default:
+		writeError(w, invalidRequest("Invalid scope parameter"))
+		return
+	}
+
+	if query.MinGames, err = intParam(params.Get("minGames"), leaderboard.DefaultMinGames); err != nil {
+		writeError(w, invalidRequest("Invalid minGames parameter"))
+		return
+	}
+	if query.Limit, err = intParam(params.Get("limit"), 0); err != nil {
+		writeError(w, invalidRequest("Invalid limit parameter"))
+		return
+	}
+
+	entries, err := h.service.Rank(query)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid leaderboard query"))
+		return
+	}
+
+	response := leaderboardResponse{
+		Metric:  query.Metric,
+		Window:  query.Window,
+		Mode:    query.Mode,
+		Scope:   scope,
+		Entries: make([]leaderboardEntry, len(entries)),
+	}
+	if query.Metric == leaderboard.MetricWinRate {
+		response.MinGames = query.MinGames
+	}
+	for i, entry := range entries {
+		response.Entries[i] = leaderboardEntry{Entry: entry, IsYou: entry.UserID == user.ID}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleSetPrivacy opts the user in to or out of leaderboards.
+func (h *LeaderboardHandler) HandleSetPrivacy(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	var req privacyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	// The stored user is saved as a copy rather than changed in place, since
+	// leaderboards may be reading it.
+	updated := *user
+	updated.LeaderboardOptOut = req.OptOut
+	if err := h.store.SaveUser(&updated); err != nil {
+		writeError(w, internalError("Failed to save user").withCause(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(privacyRequest{OptOut: updated.LeaderboardOptOut})
+}
+
+// intParam parses an optional non-negative integer query parameter.
+func intParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
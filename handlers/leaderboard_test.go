@@ -0,0 +1,136 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/leaderboard"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"testing"
+	"time"
+)
+
+func TestHandleGetLeaderboardNoAuth(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewLeaderboardHandler(authHandler, store, leaderboard.NewService(store))
+
+	req := httptest.NewRequest("GET", "/api/leaderboard", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetLeaderboard(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleGetLeaderboard(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewLeaderboardHandler(authHandler, store, leaderboard.NewService(store))
+	cookie := loginTestUser(t, store, "user1")
+
+	store.SaveSession(&models.GameSession{ID: "s1", UserID: "user1", PlaylistIDs: []string{"p1", "p2"}, GuessesUsed: 1, IsComplete: true, Won: true, CompletedAt: time.Now()})
+
+	req := httptest.NewRequest("GET", "/api/leaderboard?scope=pool&pool=p2,p1", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetLeaderboard(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var response leaderboardResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(response.Entries) != 1 || !response.Entries[0].IsYou || response.Entries[0].Score != 3 {
+		t.Errorf("entries = %+v, want the current user with score 3", response.Entries)
+	}
+}
+
+func TestHandleGetLeaderboardInvalidParams(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewLeaderboardHandler(authHandler, store, leaderboard.NewService(store))
+	cookie := loginTestUser(t, store, "user1")
+
+	tests := []string{
+		"/api/leaderboard?metric=fastest",
+		"/api/leaderboard?window=decade",
+		"/api/leaderboard?scope=pool",
+		"/api/leaderboard?scope=galaxy",
+		"/api/leaderboard?minGames=-2",
+	}
+
+	for _, target := range tests {
+		t.Run(target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", target, nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandleGetLeaderboard(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandleSetPrivacy(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewLeaderboardHandler(authHandler, store, leaderboard.NewService(store))
+
+	req := httptest.NewRequest("POST", "/api/leaderboard/privacy", bytes.NewBufferString(`{"optOut":true}`))
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+	before, _ := store.GetUser("user1")
+
+	handler.HandleSetPrivacy(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	user, _ := store.GetUser("user1")
+	if !user.LeaderboardOptOut {
+		t.Error("LeaderboardOptOut = false, want true")
+	}
+	if before.LeaderboardOptOut {
+		t.Error("stored user was changed in place, want a copy saved")
+	}
+}
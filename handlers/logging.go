@@ -0,0 +1,97 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// loggedResponse records what LogRequests logs about a response: its status
+// and size, written through it, and the user and error, filled in by the
+// handler.
+type loggedResponse struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	userID string
+	err    *apiError
+}
+
+type loggedResponseKey struct{}
+
+func (w *loggedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggedResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush passes flushes through, so event streams keep working behind the
+// request log.
+func (w *loggedResponse) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *loggedResponse) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LogRequests logs every request once it has been served, with its request
+// ID, status, duration and size, the logged-in user and any error response.
+// Server errors are logged at error level and everything else at info.
+func LogRequests(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logged := &loggedResponse{ResponseWriter: w}
+		next.ServeHTTP(logged, r.WithContext(context.WithValue(r.Context(), loggedResponseKey{}, logged)))
+
+		status := logged.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("request_id", RequestIDFromContext(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", logged.bytes),
+		}
+		if logged.userID != "" {
+			attrs = append(attrs, slog.String("user_id", logged.userID))
+		}
+		if logged.err != nil {
+			attrs = append(attrs, slog.String("error_code", string(logged.err.code)), slog.String("error", logged.err.message))
+			if logged.err.cause != nil {
+				attrs = append(attrs, slog.String("cause", logged.err.cause.Error()))
+			}
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// logUser records the logged-in user of a request for the request log.
+func logUser(r *http.Request, userID string) {
+	if logged, ok := r.Context().Value(loggedResponseKey{}).(*loggedResponse); ok {
+		logged.userID = userID
+	}
+}
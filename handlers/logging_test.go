@@ -0,0 +1,138 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/models"
+	"strings"
+	"testing"
+)
+
+// serveLogged serves req through RequestID and LogRequests and returns the
+// response and the one log entry written for it.
+func serveLogged(t *testing.T, handler http.Handler, req *http.Request) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	w := httptest.NewRecorder()
+
+	RequestID(LogRequests(logger, handler)).ServeHTTP(w, req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry %q: %v", buf.String(), err)
+	}
+	return w, entry
+}
+
+func TestLogRequests(t *testing.T) {
+	handler, store, _ := newTestAudioHandler(t)
+	cookie := loginTestUser(t, store, "user1")
+
+	req := httptest.NewRequest("GET", "/api/game/missing/audio", nil)
+	req.SetPathValue("id", "missing")
+	req.AddCookie(cookie)
+
+	w, entry := serveLogged(t, http.HandlerFunc(handler.HandleGetClip), req)
+
+	want := map[string]any{
+		"level":      "INFO",
+		"msg":        "request",
+		"request_id": w.Header().Get(RequestIDHeader),
+		"method":     "GET",
+		"path":       "/api/game/missing/audio",
+		"status":     float64(http.StatusNotFound),
+		"user_id":    "user1",
+		"error_code": string(CodeNotFound),
+		"error":      "Session not found",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["cause"]; ok {
+		t.Errorf("cause = %v, want none for a missing session", entry["cause"])
+	}
+}
+
+func TestLogRequestsServerError(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, internalError("Failed to save session").withCause(errors.New("disk full")))
+	})
+
+	_, entry := serveLogged(t, handler, httptest.NewRequest("POST", "/api/game/start", nil))
+
+	if entry["level"] != "ERROR" || entry["cause"] != "disk full" {
+		t.Errorf("log entry = %v, want an error with its cause", entry)
+	}
+	if _, ok := entry["user_id"]; ok {
+		t.Errorf("user_id = %v, want none without a session", entry["user_id"])
+	}
+}
+
+func TestLogRequestsFlush(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("response writer is not an http.Flusher")
+		}
+		w.Write([]byte("data: hello\n\n"))
+		flusher.Flush()
+	})
+
+	w, entry := serveLogged(t, handler, httptest.NewRequest("GET", "/api/game/session123/events", nil))
+
+	if !w.Flushed {
+		t.Error("response was not flushed")
+	}
+	if entry["status"] != float64(http.StatusOK) || entry["bytes"] != float64(len("data: hello\n\n")) {
+		t.Errorf("log entry = %v, want status 200 and the bytes written", entry)
+	}
+}
+
+// roundTripFunc answers HTTP requests without a network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRequestIDReachesSpotifyLog(t *testing.T) {
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"id":"user1"}`)),
+			Request:    req,
+		}, nil
+	})
+	defer func() { http.DefaultClient.Transport = transport }()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := models.NewUser("user1", "User One", &models.Token{AccessToken: "token"})
+		if _, err := newSpotifyClient(r.Context(), user).GetUserProfile(); err != nil {
+			t.Errorf("GetUserProfile() failed: %v", err)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/api/user", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	RequestID(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if line := buf.String(); !strings.Contains(line, "msg=\"spotify request\" request_id=req-123") {
+		t.Errorf("spotify request logged %q, want request_id=req-123", line)
+	}
+}
@@ -0,0 +1,119 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"errors"
+	"spotify-heardle/models"
+	"spotify-heardle/search"
+)
+
+// guessEvaluator checks a guess against the session's answer for one game mode.
+// It returns an error when the request lacks the input the mode needs.
+type guessEvaluator func(session *models.GameSession, req submitGuessRequest) (models.Guess, error)
+
+var guessEvaluators = map[models.GameMode]guessEvaluator{
+	models.ModeTrack:  evaluateTrackGuess,
+	models.ModeArtist: evaluateArtistGuess,
+	models.ModeAlbum:  evaluateAlbumGuess,
+	models.ModeYear:   evaluateYearGuess,
+	models.ModeChoice: evaluateChoiceGuess,
+	models.ModeTimed:  evaluateTrackGuess,
+	models.ModeBlitz:  evaluateTrackGuess,
+}
+
+// evaluateTrackGuess judges the guessed track the server looked up, never
+// the name and artists the client sent, which it could make up. Any release
+// of the answer's recording is accepted, since the player may not pick the
+// exact one: the same ID, relinked ID or ISRC. Titles are not compared, as
+// they cannot tell apart songs such as "Part 1" and "Part 2" or two intros.
+func evaluateTrackGuess(session *models.GameSession, req submitGuessRequest) (models.Guess, error) {
+	if req.TrackID == "" {
+		return models.Guess{}, errors.New("trackId is required")
+	}
+	if req.guessed == nil || req.guessed.ID != req.TrackID {
+		return models.Guess{}, errors.New("unknown trackId")
+	}
+	guessed := *req.guessed
+
+	return models.Guess{
+		TrackID:   guessed.ID,
+		TrackName: guessed.Name,
+		Artists:   guessed.Artists,
+		IsCorrect: search.SameRecording(guessed, session.CorrectSong),
+	}, nil
+}
+
+func evaluateArtistGuess(session *models.GameSession, req submitGuessRequest) (models.Guess, error) {
+	if req.ArtistID == "" {
+		return models.Guess{}, errors.New("artistId is required in artist mode")
+	}
+
+	isCorrect := false
+	for _, id := range session.CorrectSong.ArtistIDs {
+		if id == req.ArtistID {
+			isCorrect = true
+			break
+		}
+	}
+
+	return models.Guess{
+		ArtistID:   req.ArtistID,
+		ArtistName: req.ArtistName,
+		Artists:    []string{req.ArtistName},
+		IsCorrect:  isCorrect,
+	}, nil
+}
+
+func evaluateAlbumGuess(session *models.GameSession, req submitGuessRequest) (models.Guess, error) {
+	if req.AlbumID == "" {
+		return models.Guess{}, errors.New("albumId is required in album mode")
+	}
+
+	return models.Guess{
+		AlbumID:   req.AlbumID,
+		AlbumName: req.AlbumName,
+		Artists:   req.Artists,
+		IsCorrect: req.AlbumID == session.CorrectSong.AlbumID,
+	}, nil
+}
+
+func evaluateYearGuess(session *models.GameSession, req submitGuessRequest) (models.Guess, error) {
+	if req.Year <= 0 {
+		return models.Guess{}, errors.New("year is required in year mode")
+	}
+
+	proximity, direction := models.CompareYears(req.Year, session.CorrectSong.ReleaseYear())
+
+	return models.Guess{
+		Year:      req.Year,
+		Proximity: proximity,
+		Direction: direction,
+		IsCorrect: proximity == models.ProximityExact,
+	}, nil
+}
+
+// guessesTracks reports whether players name a song in a mode, which is what
+// pool search can help with.
+func guessesTracks(mode models.GameMode) bool {
+	switch mode {
+	case models.ModeTrack, models.ModeTimed, models.ModeBlitz:
+		return true
+	}
+	return false
+}
+
+// filterTracksForMode drops tracks that cannot be played in a mode, such as
+// tracks without a known release year in year mode.
+func filterTracksForMode(tracks []models.Track, mode models.GameMode) []models.Track {
+	if mode != models.ModeYear {
+		return tracks
+	}
+
+	filtered := make([]models.Track, 0, len(tracks))
+	for _, track := range tracks {
+		if track.ReleaseYear() > 0 {
+			filtered = append(filtered, track)
+		}
+	}
+	return filtered
+}
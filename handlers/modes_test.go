@@ -0,0 +1,80 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"spotify-heardle/models"
+	"testing"
+)
+
+func TestGuessEvaluators(t *testing.T) {
+	session := &models.GameSession{
+		CorrectSong: models.Track{
+			ID:          "track1",
+			Name:        "Heroes",
+			Artists:     []string{"David Bowie"},
+			ArtistIDs:   []string{"artist1", "artist2"},
+			AlbumID:     "album1",
+			ReleaseDate: "1999-04-01",
+			ISRC:        "GBAYE7700001",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		mode    models.GameMode
+		req     submitGuessRequest
+		want    bool
+		wantErr bool
+	}{
+		{"track correct", models.ModeTrack, trackGuess(models.Track{ID: "track1"}), true, false},
+		{"track wrong", models.ModeTrack, trackGuess(models.Track{ID: "track2"}), false, false},
+		{"track other release", models.ModeTrack, trackGuess(models.Track{ID: "track3", Name: "Heroes - 2017 Remaster", Artists: []string{"David Bowie"}, ISRC: "GBAYE7700001"}), true, false},
+		{"track same title", models.ModeTrack, trackGuess(models.Track{ID: "track5", Name: "Heroes (Live)", Artists: []string{"David Bowie"}}), false, false},
+		{"track cover", models.ModeTrack, trackGuess(models.Track{ID: "track4", Name: "Heroes", Artists: []string{"Peter Gabriel"}}), false, false},
+		{"track made up", models.ModeTrack, submitGuessRequest{TrackID: "track4", TrackName: "Heroes", Artists: []string{"David Bowie"}, guessed: &models.Track{ID: "track4", Name: "Other"}}, false, false},
+		{"track not looked up", models.ModeTrack, submitGuessRequest{TrackID: "track1", TrackName: "Heroes"}, false, true},
+		{"track missing", models.ModeTrack, submitGuessRequest{TrackName: "Heroes"}, false, true},
+		{"artist featured", models.ModeArtist, submitGuessRequest{ArtistID: "artist2"}, true, false},
+		{"artist wrong", models.ModeArtist, submitGuessRequest{ArtistID: "artist3"}, false, false},
+		{"artist missing", models.ModeArtist, submitGuessRequest{TrackID: "track1"}, false, true},
+		{"album correct", models.ModeAlbum, submitGuessRequest{AlbumID: "album1"}, true, false},
+		{"album wrong", models.ModeAlbum, submitGuessRequest{AlbumID: "album2"}, false, false},
+		{"album missing", models.ModeAlbum, submitGuessRequest{}, false, true},
+		{"year exact", models.ModeYear, submitGuessRequest{Year: 1999}, true, false},
+		{"year close", models.ModeYear, submitGuessRequest{Year: 1998}, false, false},
+		{"year missing", models.ModeYear, submitGuessRequest{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guess, err := guessEvaluators[tt.mode](session, tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if guess.IsCorrect != tt.want {
+				t.Errorf("IsCorrect = %v, want %v", guess.IsCorrect, tt.want)
+			}
+		})
+	}
+}
+
+// trackGuess is a guess of track as the server looked it up.
+func trackGuess(track models.Track) submitGuessRequest {
+	return submitGuessRequest{TrackID: track.ID, guessed: &track}
+}
+
+func TestFilterTracksForMode(t *testing.T) {
+	tracks := []models.Track{
+		{ID: "track1", ReleaseDate: "1999"},
+		{ID: "track2"},
+	}
+
+	if got := filterTracksForMode(tracks, models.ModeTrack); len(got) != 2 {
+		t.Errorf("track mode kept %d tracks, want 2", len(got))
+	}
+
+	got := filterTracksForMode(tracks, models.ModeYear)
+	if len(got) != 1 || got[0].ID != "track1" {
+		t.Errorf("year mode kept %v, want only track1", got)
+	}
+}
@@ -4,45 +4,262 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
+	"spotify-heardle/models"
 	"spotify-heardle/spotify"
+	"strings"
+	"sync"
+	"time"
 )
 
+// likedTotalTTL is how long a user's Liked Songs total is reused before it
+// is looked up again.
+const likedTotalTTL = 10 * time.Minute
+
 // PlaylistHandler handles playlist-related routes.
 type PlaylistHandler struct {
-	auth *AuthHandler
+	auth        *AuthHandler
+	likedTotals *likedTotals
+}
+
+// likedTotals caches the number of Liked Songs per user, which takes a
+// Spotify request to look up on every visit to the playlist page otherwise.
+type likedTotals struct {
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]likedTotal
+	mu      sync.Mutex
+}
+
+type likedTotal struct {
+	total     int
+	expiresAt time.Time
+}
+
+type resolveRequest struct {
+	URL string `json:"url"`
+}
+
+// resolvedPool describes a pasted playlist, album or artist in the same shape
+// as a playlist, with its ID set to the pool ID to start a game with.
+type resolvedPool struct {
+	spotify.Playlist
+	Type spotify.LinkType `json:"type"`
 }
 
 // NewPlaylistHandler creates a new playlist handler.
 func NewPlaylistHandler(auth *AuthHandler) *PlaylistHandler {
-	return &PlaylistHandler{auth: auth}
+	return &PlaylistHandler{
+		auth: auth,
+		likedTotals: &likedTotals{
+			ttl:     likedTotalTTL,
+			now:     time.Now,
+			entries: make(map[string]likedTotal),
+		},
+	}
 }
 
-// HandleGetPlaylists returns user's playlists.
+// HandleGetPlaylists returns user's playlists, after their Liked Songs, top
+// tracks and recently played tracks. The playlists can be narrowed with the
+// owner ("me" or "others") and minTracks query parameters, and sorted by
+// "name" or "tracks" with the sort parameter instead of Spotify's order.
 func (h *PlaylistHandler) HandleGetPlaylists(w http.ResponseWriter, r *http.Request) {
 	user, err := h.auth.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	owner := params.Get("owner")
+	if owner != "" && owner != "me" && owner != "others" {
+		writeError(w, invalidRequest("Invalid owner parameter"))
+		return
+	}
+	minTracks, err := intParam(params.Get("minTracks"), 0)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid minTracks parameter"))
+		return
+	}
+	sortBy := params.Get("sort")
+	if sortBy != "" && sortBy != "name" && sortBy != "tracks" {
+		writeError(w, invalidRequest("Invalid sort parameter"))
 		return
 	}
 
-	client := spotify.NewClient(user.Token)
+	client := newSpotifyClient(r.Context(), user)
 	playlists, err := client.GetUserPlaylists()
 	if err != nil {
-		http.Error(w, "Failed to get playlists", http.StatusInternalServerError)
+		writeError(w, spotifyError(err, "Failed to get playlists"))
 		return
 	}
 
-	// Add "Liked Songs" as a special playlist option
-	likedSongsPlaylist := spotify.Playlist{
-		ID:     "liked_songs",
-		Name:   "Liked Songs",
-		Images: []spotify.PlaylistImage{},
-		Tracks: spotify.TracksInfo{Total: 0}, // Total is set to 0 to avoid an additional API call
+	playlists = filterPlaylists(playlists, user.ID, owner, minTracks)
+	sortPlaylists(playlists, sortBy)
+
+	// Offer the user's liked songs, top tracks and recently played tracks
+	// before their playlists. Top and recently played tracks are left
+	// uncounted; Spotify returns at most 50 of each.
+	sources := []spotify.Playlist{
+		{ID: spotify.SourceRef{Kind: spotify.SourceLiked}.String(), Name: "Liked Songs"},
+		{ID: spotify.SourceRef{Kind: spotify.SourceTop, Arg: "short_term"}.String(), Name: "Your Top Tracks (4 weeks)"},
+		{ID: spotify.SourceRef{Kind: spotify.SourceRecent}.String(), Name: "Recently Played"},
+	}
+	sources[0].Tracks.Total = h.likedTotals.get(user.ID, client.GetLikedSongsTotal)
+	for i := range sources {
+		sources[i].Owner = spotify.PlaylistOwner{ID: user.ID, DisplayName: user.DisplayName}
+		sources[i].Images = []spotify.PlaylistImage{}
 	}
 
-	// Prepend liked songs to the list
-	allPlaylists := append([]spotify.Playlist{likedSongsPlaylist}, playlists...)
+	allPlaylists := append(sources, playlists...)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(allPlaylists)
 }
+
+// HandleResolvePlaylist resolves a pasted Spotify playlist, album or artist
+// URL or URI into a pool that can be passed in a game's playlist IDs. It
+// fails if the linked object has no tracks a game could be played with, by
+// the default filters and the user's playback, and reports how many it has.
+func (h *PlaylistHandler) HandleResolvePlaylist(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	link, err := spotify.ParseLink(req.URL)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid link: "+err.Error()))
+		return
+	}
+
+	client := newSpotifyClient(r.Context(), user)
+	pool, err := describeLink(client, link)
+	if err != nil {
+		writeError(w, spotifyError(err, "Could not find that "+string(link.Type)))
+		return
+	}
+
+	combined, err := client.CombineSources([]string{pool.ID})
+	if err != nil {
+		writeError(w, spotifyError(err, "Failed to get tracks"))
+		return
+	}
+
+	tracks, _ := narrowPool(combined, models.TrackFilter{}, models.ModeTrack, user.DefaultPlayback())
+	if len(tracks) == 0 {
+		writeError(w, newAPIError(http.StatusUnprocessableEntity, CodeEmptyPool, "That "+string(link.Type)+" has no playable tracks"))
+		return
+	}
+	pool.Tracks.Total = len(tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pool)
+}
+
+// describeLink fetches the name and images of a linked playlist, album or artist.
+func describeLink(client *spotify.Client, link spotify.Link) (*resolvedPool, error) {
+	pool := &resolvedPool{Type: link.Type}
+
+	switch link.Type {
+	case spotify.LinkAlbum:
+		album, _, err := client.GetAlbum(link.ID)
+		if err != nil {
+			return nil, err
+		}
+		pool.Name = album.Name
+		pool.Images = album.Images
+	case spotify.LinkArtist:
+		artist, err := client.GetArtist(link.ID)
+		if err != nil {
+			return nil, err
+		}
+		pool.Name = artist.Name
+		pool.Images = artist.Images
+	default:
+		playlist, err := client.GetPlaylist(link.ID)
+		if err != nil {
+			return nil, err
+		}
+		pool.Playlist = *playlist
+	}
+
+	pool.ID = link.PoolID()
+	if pool.Images == nil {
+		pool.Images = []spotify.PlaylistImage{}
+	}
+	return pool, nil
+}
+
+// get returns the user's cached Liked Songs total, or looks it up with fetch.
+// Failed lookups count as 0 and are not cached.
+func (c *likedTotals) get(userID string, fetch func() (int, error)) int {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.total
+	}
+
+	total, err := fetch()
+	if err != nil {
+		return 0
+	}
+
+	c.store(userID, total)
+	return total
+}
+
+// store caches the user's total, evicting the entries that have expired so
+// users who never come back do not stay in the map.
+func (c *likedTotals) store(userID string, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for id, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[userID] = likedTotal{total: total, expiresAt: now.Add(c.ttl)}
+}
+
+// filterPlaylists keeps the playlists owned by userID if owner is "me", or by
+// other users if it is "others", that have at least minTracks tracks.
+func filterPlaylists(playlists []spotify.Playlist, userID, owner string, minTracks int) []spotify.Playlist {
+	filtered := make([]spotify.Playlist, 0, len(playlists))
+	for _, playlist := range playlists {
+		owned := playlist.Owner.ID == userID
+		if (owner == "me" && !owned) || (owner == "others" && owned) {
+			continue
+		}
+		if playlist.Tracks.Total < minTracks {
+			continue
+		}
+		filtered = append(filtered, playlist)
+	}
+	return filtered
+}
+
+// sortPlaylists sorts playlists by name or by most tracks, or leaves them in
+// Spotify's order if sortBy is empty.
+func sortPlaylists(playlists []spotify.Playlist, sortBy string) {
+	switch sortBy {
+	case "name":
+		sort.SliceStable(playlists, func(i, j int) bool {
+			return strings.ToLower(playlists[i].Name) < strings.ToLower(playlists[j].Name)
+		})
+	case "tracks":
+		sort.SliceStable(playlists, func(i, j int) bool {
+			return playlists[i].Tracks.Total > playlists[j].Tracks.Total
+		})
+	}
+}
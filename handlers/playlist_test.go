@@ -2,11 +2,18 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"spotify-heardle/config"
+	"spotify-heardle/spotify"
 	"spotify-heardle/storage"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewPlaylistHandler(t *testing.T) {
@@ -46,3 +53,228 @@ func TestHandleGetPlaylistsNoAuth(t *testing.T) {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
 	}
 }
+
+func TestHandleResolvePlaylistNoAuth(t *testing.T) {
+	cfg := &config.Config{SessionSecret: "test_session_secret"}
+	store := storage.NewMemoryStore()
+	handler := NewPlaylistHandler(NewAuthHandler(cfg, store))
+
+	body := bytes.NewBufferString(`{"url":"spotify:album:4aawyAB9vmqN3uQ7FjRGTy"}`)
+	req := httptest.NewRequest("POST", "/api/playlists/resolve", body)
+	w := httptest.NewRecorder()
+
+	handler.HandleResolvePlaylist(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// stubSpotifyPlaylist answers Spotify's playlist requests with a playlist
+// named Mix holding tracks, whose items are JSON track objects.
+func stubSpotifyPlaylist(t *testing.T, tracks ...string) {
+	t.Helper()
+	transport := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"id":"37i9dQZF1DXcBWIGoYBM5M","name":"Mix","images":[],"tracks":{"total":3}}`
+		if strings.HasSuffix(req.URL.Path, "/tracks") {
+			items := make([]string, len(tracks))
+			for i, track := range tracks {
+				items[i] = `{"track":` + track + `}`
+			}
+			body = `{"items":[` + strings.Join(items, ",") + `]}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestHandleResolvePlaylistPlayable(t *testing.T) {
+	const (
+		playable    = `{"id":"t1","name":"Song","artists":[{"name":"Artist"}]}`
+		unavailable = `{"id":"t2","name":"Gone","artists":[{"name":"Artist"}],"is_playable":false}`
+		local       = `{"uri":"spotify:local:a:b:c:1","name":"Local","is_local":true}`
+		episode     = `{"id":"e1","type":"episode","name":"Episode"}`
+	)
+
+	tests := []struct {
+		name       string
+		tracks     []string
+		wantStatus int
+		wantTotal  int
+	}{
+		{"playable tracks counted", []string{playable, unavailable, local}, http.StatusOK, 1},
+		{"nothing playable", []string{unavailable, local, episode}, http.StatusUnprocessableEntity, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSpotifyPlaylist(t, tt.tracks...)
+			cfg := &config.Config{SessionSecret: "test_session_secret"}
+			store := storage.NewMemoryStore()
+			handler := NewPlaylistHandler(NewAuthHandler(cfg, store))
+
+			body := bytes.NewBufferString(`{"url":"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"}`)
+			req := httptest.NewRequest("POST", "/api/playlists/resolve", body)
+			req.AddCookie(loginTestUser(t, store, "user1"))
+			w := httptest.NewRecorder()
+
+			handler.HandleResolvePlaylist(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var pool resolvedPool
+			json.NewDecoder(w.Body).Decode(&pool)
+			if pool.Tracks.Total != tt.wantTotal {
+				t.Errorf("Tracks.Total = %d, want %d", pool.Tracks.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestHandleResolvePlaylistInvalidLink(t *testing.T) {
+	cfg := &config.Config{SessionSecret: "test_session_secret"}
+	store := storage.NewMemoryStore()
+	handler := NewPlaylistHandler(NewAuthHandler(cfg, store))
+	cookie := loginTestUser(t, store, "user1")
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", `{"url":`},
+		{"not spotify", `{"url":"https://example.com/playlist/37i9dQZF1DXcBWIGoYBM5M"}`},
+		{"track link", `{"url":"spotify:track:6rqhFgbbKwnb9MLmUQDhG6"}`},
+		{"empty", `{"url":""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/playlists/resolve", bytes.NewBufferString(tt.body))
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandleResolvePlaylist(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandleGetPlaylistsInvalidParams(t *testing.T) {
+	cfg := &config.Config{SessionSecret: "test_session_secret"}
+	store := storage.NewMemoryStore()
+	handler := NewPlaylistHandler(NewAuthHandler(cfg, store))
+	cookie := loginTestUser(t, store, "user1")
+
+	for _, query := range []string{"owner=nobody", "minTracks=-1", "minTracks=many", "sort=color"} {
+		req := httptest.NewRequest("GET", "/api/playlists?"+query, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleGetPlaylists(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestFilterAndSortPlaylists(t *testing.T) {
+	playlists := []spotify.Playlist{
+		{ID: "p1", Name: "road trip", Owner: spotify.PlaylistOwner{ID: "user1"}, Tracks: spotify.TracksInfo{Total: 12}},
+		{ID: "p2", Name: "Discover Weekly", Owner: spotify.PlaylistOwner{ID: "spotify"}, Tracks: spotify.TracksInfo{Total: 30}},
+		{ID: "p3", Name: "Drafts", Owner: spotify.PlaylistOwner{ID: "user1"}, Tracks: spotify.TracksInfo{Total: 2}},
+	}
+
+	ids := func(playlists []spotify.Playlist) string {
+		var s string
+		for _, playlist := range playlists {
+			s += playlist.ID + " "
+		}
+		return s
+	}
+
+	tests := []struct {
+		owner     string
+		minTracks int
+		sortBy    string
+		want      string
+	}{
+		{"", 0, "", "p1 p2 p3 "},
+		{"me", 0, "", "p1 p3 "},
+		{"others", 0, "", "p2 "},
+		{"me", 10, "", "p1 "},
+		{"", 0, "name", "p2 p3 p1 "},
+		{"", 0, "tracks", "p2 p1 p3 "},
+	}
+
+	for _, tt := range tests {
+		got := filterPlaylists(playlists, "user1", tt.owner, tt.minTracks)
+		sortPlaylists(got, tt.sortBy)
+		if ids(got) != tt.want {
+			t.Errorf("owner=%q minTracks=%d sort=%q: got %q, want %q", tt.owner, tt.minTracks, tt.sortBy, ids(got), tt.want)
+		}
+	}
+}
+
+func TestLikedTotalsCache(t *testing.T) {
+	now := time.Now()
+	cache := &likedTotals{ttl: time.Minute, now: func() time.Time { return now }, entries: make(map[string]likedTotal)}
+
+	calls := 0
+	fetch := func() (int, error) {
+		calls++
+		return 40 + calls, nil
+	}
+
+	if got := cache.get("user1", fetch); got != 41 {
+		t.Errorf("get() = %d, want 41", got)
+	}
+	if got := cache.get("user1", fetch); got != 41 || calls != 1 {
+		t.Errorf("get() = %d after %d calls, want the cached 41", got, calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := cache.get("user1", fetch); got != 42 {
+		t.Errorf("get() after expiry = %d, want 42", got)
+	}
+
+	failed := func() (int, error) { return 0, errors.New("rate limited") }
+	if got := cache.get("user2", failed); got != 0 {
+		t.Errorf("get() with a failed lookup = %d, want 0", got)
+	}
+	if _, ok := cache.entries["user2"]; ok {
+		t.Error("failed lookup was cached")
+	}
+}
+
+func TestLikedTotalsCacheEvictsExpired(t *testing.T) {
+	now := time.Now()
+	cache := &likedTotals{ttl: time.Minute, now: func() time.Time { return now }, entries: make(map[string]likedTotal)}
+	fetch := func() (int, error) { return 40, nil }
+
+	cache.get("user1", fetch)
+	now = now.Add(30 * time.Second)
+	cache.get("user2", fetch)
+	now = now.Add(45 * time.Second)
+	cache.get("user3", fetch)
+
+	if _, ok := cache.entries["user1"]; ok {
+		t.Error("expired entry of user1 was not evicted")
+	}
+	if len(cache.entries) != 2 {
+		t.Errorf("cache has %d entries, want user2 and user3", len(cache.entries))
+	}
+}
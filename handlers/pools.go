@@ -0,0 +1,471 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"spotify-heardle/models"
+	"spotify-heardle/spotify"
+	"spotify-heardle/storage"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PoolHandler handles saved pool routes.
+type PoolHandler struct {
+	auth    *AuthHandler
+	store   *storage.MemoryStore
+	baseURL string
+}
+
+type poolRequest struct {
+	Name        string             `json:"name"`
+	PlaylistIDs []string           `json:"playlistIds"`
+	Mode        string             `json:"mode"`
+	Choices     int                `json:"choices"`
+	PoolSearch  bool               `json:"poolSearch"`
+	Filters     models.TrackFilter `json:"filters"`
+}
+
+type poolResponse struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	PlaylistIDs []string           `json:"playlistIds"`
+	Mode        models.GameMode    `json:"mode"`
+	Choices     int                `json:"choices,omitempty"`
+	PoolSearch  bool               `json:"poolSearch"`
+	Filters     models.TrackFilter `json:"filters"`
+	ShareURL    string             `json:"shareUrl,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// sharedPoolResponse is what other players see of a shared pool.
+type sharedPoolResponse struct {
+	Name        string             `json:"name"`
+	Owner       string             `json:"owner"`
+	PlaylistIDs []string           `json:"playlistIds"`
+	Mode        models.GameMode    `json:"mode"`
+	Choices     int                `json:"choices,omitempty"`
+	PoolSearch  bool               `json:"poolSearch"`
+	Filters     models.TrackFilter `json:"filters"`
+}
+
+// poolStats counts how the tracks of a pool's sources were narrowed down to
+// the tracks a game can be played with.
+type poolStats struct {
+	// Total counts the tracks of all sources, including duplicates.
+	Total             int `json:"total"`
+	DuplicatesRemoved int `json:"duplicatesRemoved"`
+	// UnavailableInMarket counts the tracks that cannot be played in the
+	// user's country, which are always left out.
+	UnavailableInMarket int `json:"unavailableInMarket"`
+	// Each track left out is counted under exactly one of the fields below,
+	// so together with Playable and DuplicatesRemoved they add up to Total.
+	//
+	// LocalFiles counts the local files in the sources, which are left out
+	// unless the filters include them.
+	LocalFiles int `json:"localFiles"`
+	// FilteredOut counts the other tracks left out by the filters or because
+	// the game mode cannot use them.
+	FilteredOut int `json:"filteredOut"`
+	// NoPreview counts the tracks left out of a preview game because Spotify
+	// has no preview clip for them.
+	NoPreview int `json:"noPreview"`
+	Playable  int `json:"playable"`
+}
+
+type previewPoolResponse struct {
+	poolStats
+	Sample []models.Track `json:"sample"`
+}
+
+// poolSampleSize is the number of tracks shown in a pool preview.
+const poolSampleSize = 10
+
+// NewPoolHandler creates a new saved pool handler. Share links are made
+// absolute with baseURL.
+func NewPoolHandler(auth *AuthHandler, store *storage.MemoryStore, baseURL string) *PoolHandler {
+	return &PoolHandler{
+		auth:    auth,
+		store:   store,
+		baseURL: baseURL,
+	}
+}
+
+// HandleListPools returns the user's saved pools.
+func (h *PoolHandler) HandleListPools(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	pools := h.store.ListPools(user.ID)
+	response := make([]poolResponse, 0, len(pools))
+	for _, pool := range pools {
+		response = append(response, newPoolResponse(h.baseURL, pool))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleCreatePool saves a new named pool.
+func (h *PoolHandler) HandleCreatePool(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	var req poolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	poolID, err := generateShareID()
+	if err != nil {
+		writeError(w, internalError("Failed to generate pool ID").withCause(err))
+		return
+	}
+
+	pool := models.NewPool(poolID, user.ID, "", nil)
+	if err := req.apply(pool); err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return
+	}
+
+	h.savePool(w, r, pool, http.StatusCreated)
+}
+
+// HandleGetPool returns one of the user's saved pools.
+func (h *PoolHandler) HandleGetPool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownPool(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPoolResponse(h.baseURL, pool))
+}
+
+// HandleUpdatePool replaces the name, sources, filters and rules of a saved
+// pool.
+func (h *PoolHandler) HandleUpdatePool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownPool(w, r)
+	if !ok {
+		return
+	}
+
+	var req poolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	updated := *pool
+	if err := req.apply(&updated); err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return
+	}
+	updated.UpdatedAt = time.Now()
+
+	h.savePool(w, r, &updated, http.StatusOK)
+}
+
+// HandleDeletePool deletes a saved pool, which also revokes its share link.
+func (h *PoolHandler) HandleDeletePool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownPool(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeletePool(pool.ID); err != nil {
+		writeError(w, lookupError(err, "Pool not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSharePool assigns a saved pool a share link on first use and
+// returns the pool with it.
+func (h *PoolHandler) HandleSharePool(w http.ResponseWriter, r *http.Request) {
+	pool, ok := h.ownPool(w, r)
+	if !ok {
+		return
+	}
+
+	if pool.ShareID != "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newPoolResponse(h.baseURL, pool))
+		return
+	}
+
+	shareID, err := generateShareID()
+	if err != nil {
+		writeError(w, internalError("Failed to generate share ID").withCause(err))
+		return
+	}
+
+	shared := *pool
+	shared.ShareID = shareID
+	h.savePool(w, r, &shared, http.StatusOK)
+}
+
+// HandleGetSharedPool shows a pool shared by another player.
+func (h *PoolHandler) HandleGetSharedPool(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.auth.GetUserFromSession(r); err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	pool, err := h.store.GetPoolByShareID(r.PathValue("shareId"))
+	if err != nil {
+		writeError(w, lookupError(err, "Pool not found"))
+		return
+	}
+
+	response := sharedPoolResponse{
+		Name:        pool.Name,
+		PlaylistIDs: pool.PlaylistIDs,
+		Mode:        pool.Mode,
+		Choices:     pool.Choices,
+		PoolSearch:  pool.PoolSearch,
+		Filters:     pool.Filters,
+	}
+	if owner, err := h.store.GetUser(pool.UserID); err == nil {
+		response.Owner = owner.DisplayName
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleCopySharedPool saves a copy of a shared pool to the user's pools.
+// Later changes by either player do not affect the other's copy.
+func (h *PoolHandler) HandleCopySharedPool(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	shared, err := h.store.GetPoolByShareID(r.PathValue("shareId"))
+	if err != nil {
+		writeError(w, lookupError(err, "Pool not found"))
+		return
+	}
+
+	poolID, err := generateShareID()
+	if err != nil {
+		writeError(w, internalError("Failed to generate pool ID").withCause(err))
+		return
+	}
+
+	pool := models.NewPool(poolID, user.ID, shared.Name, append([]string(nil), shared.PlaylistIDs...))
+	pool.Mode = shared.Mode
+	pool.Choices = shared.Choices
+	pool.PoolSearch = shared.PoolSearch
+	pool.Filters = shared.Filters
+
+	h.savePool(w, r, pool, http.StatusCreated)
+}
+
+// HandlePreviewPool resolves the sources of a game start request and applies
+// its filters without starting a game, returning how many tracks are left
+// and a random sample of them.
+func (h *PoolHandler) HandlePreviewPool(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	var req startGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	mode, filter, ok := prepareStart(w, h.store, user.ID, &req)
+	if !ok {
+		return
+	}
+
+	playback, err := choosePlayback(user, req.Playback, mode)
+	if err != nil {
+		writeError(w, invalidRequest("Invalid request: "+err.Error()))
+		return
+	}
+
+	client := newSpotifyClient(r.Context(), user)
+	tracks, stats, err := buildPool(client, req.PlaylistIDs, filter, mode, playback)
+	if err != nil {
+		writeError(w, spotifyError(err, "Failed to get playlist tracks"))
+		return
+	}
+
+	rand.Shuffle(len(tracks), func(i, j int) {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(previewPoolResponse{
+		poolStats: stats,
+		Sample:    tracks[:min(len(tracks), poolSampleSize)],
+	})
+}
+
+// ownPool looks up the pool named in the path, writing an error response
+// unless it belongs to the logged-in user.
+func (h *PoolHandler) ownPool(w http.ResponseWriter, r *http.Request) (*models.Pool, bool) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return nil, false
+	}
+
+	pool, err := h.store.GetPool(r.PathValue("id"))
+	if err != nil {
+		writeError(w, lookupError(err, "Pool not found"))
+		return nil, false
+	}
+
+	if pool.UserID != user.ID {
+		writeError(w, errForbidden)
+		return nil, false
+	}
+	return pool, true
+}
+
+func (h *PoolHandler) savePool(w http.ResponseWriter, r *http.Request, pool *models.Pool, status int) {
+	if err := h.store.SavePool(pool); err != nil {
+		writeError(w, internalError("Failed to save pool").withCause(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newPoolResponse(h.baseURL, pool))
+}
+
+// apply validates the request and copies it onto pool.
+func (req poolRequest) apply(pool *models.Pool) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > models.MaxPoolNameLength {
+		return errors.New("name is too long")
+	}
+
+	if len(req.PlaylistIDs) == 0 {
+		return errors.New("at least one playlist ID is required")
+	}
+	if err := validateSources(req.PlaylistIDs); err != nil {
+		return err
+	}
+
+	mode, err := models.ParseGameMode(req.Mode)
+	if err != nil {
+		return err
+	}
+	if req.Choices != 0 {
+		if _, err := choiceCount(req.Choices); err != nil {
+			return err
+		}
+	}
+	if req.PoolSearch && !guessesTracks(mode) {
+		return errors.New("pool search is only available when guessing songs")
+	}
+	if err := req.Filters.Validate(); err != nil {
+		return err
+	}
+
+	pool.Name = name
+	pool.PlaylistIDs = req.PlaylistIDs
+	pool.Mode = mode
+	pool.Choices = req.Choices
+	pool.PoolSearch = req.PoolSearch
+	pool.Filters = req.Filters
+	return nil
+}
+
+// buildPool fetches the tracks of the sources and narrows them down to those
+// a game in mode can be played with.
+func buildPool(client *spotify.Client, sourceIDs []string, filter models.TrackFilter, mode models.GameMode, playback models.Playback) ([]models.Track, poolStats, error) {
+	combined, err := client.CombineSources(sourceIDs)
+	if err != nil {
+		return nil, poolStats{}, err
+	}
+
+	tracks, stats := narrowPool(combined, filter, mode, playback)
+	return tracks, stats, nil
+}
+
+// narrowPool leaves out the combined tracks that are unavailable, do not pass
+// the filter, cannot be used in mode or cannot be played back, counting what
+// was left out.
+func narrowPool(combined spotify.CombinedTracks, filter models.TrackFilter, mode models.GameMode, playback models.Playback) ([]models.Track, poolStats) {
+	stats := poolStats{
+		Total:             len(combined.Tracks) + combined.Duplicates,
+		DuplicatesRemoved: combined.Duplicates,
+	}
+
+	includeLocal := filter.ExcludeLocalFiles != nil && !*filter.ExcludeLocalFiles
+	available := make([]models.Track, 0, len(combined.Tracks))
+	for _, track := range combined.Tracks {
+		switch {
+		case track.Unplayable:
+			stats.UnavailableInMarket++
+		case track.IsLocal && !includeLocal:
+			stats.LocalFiles++
+		default:
+			available = append(available, track)
+		}
+	}
+
+	tracks := filterTracksForMode(filter.Apply(available), mode)
+	stats.FilteredOut = len(available) - len(tracks)
+	if playback == models.PlaybackPreview {
+		withPreview := filterTracksWithPreview(tracks)
+		stats.NoPreview = len(tracks) - len(withPreview)
+		tracks = withPreview
+	}
+	stats.Playable = len(tracks)
+	return tracks, stats
+}
+
+// validateSources checks that every ID is a valid source reference.
+func validateSources(ids []string) error {
+	for _, id := range ids {
+		if _, err := spotify.ParseSourceRef(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPoolResponse(baseURL string, pool *models.Pool) poolResponse {
+	response := poolResponse{
+		ID:          pool.ID,
+		Name:        pool.Name,
+		PlaylistIDs: pool.PlaylistIDs,
+		Mode:        pool.Mode,
+		Choices:     pool.Choices,
+		PoolSearch:  pool.PoolSearch,
+		Filters:     pool.Filters,
+		CreatedAt:   pool.CreatedAt,
+		UpdatedAt:   pool.UpdatedAt,
+	}
+	if pool.ShareID != "" {
+		response.ShareURL = baseURL + "/playlists.html?pool=" + pool.ShareID
+	}
+	return response
+}
@@ -0,0 +1,335 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/models"
+	"spotify-heardle/spotify"
+	"spotify-heardle/storage"
+	"strings"
+	"testing"
+)
+
+func newTestPoolHandler() (*PoolHandler, *storage.MemoryStore) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	return NewPoolHandler(NewAuthHandler(cfg, store), store, "http://localhost:8080"), store
+}
+
+func decodePool(t *testing.T, w *httptest.ResponseRecorder) poolResponse {
+	t.Helper()
+	var pool poolResponse
+	if err := json.NewDecoder(w.Body).Decode(&pool); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return pool
+}
+
+func TestHandleListPoolsNoAuth(t *testing.T) {
+	handler, _ := newTestPoolHandler()
+
+	req := httptest.NewRequest("GET", "/api/pools", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleListPools(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPoolLifecycle(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	cookie := loginTestUser(t, store, "user1")
+
+	body := bytes.NewBufferString(`{"name":" Road trip ","playlistIds":["liked","album:4aawyAB9vmqN3uQ7FjRGTy"],"mode":"choice","choices":6}`)
+	req := httptest.NewRequest("POST", "/api/pools", body)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	handler.HandleCreatePool(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	created := decodePool(t, w)
+	if created.ID == "" || created.Name != "Road trip" || created.Mode != models.ModeChoice || created.Choices != 6 || len(created.PlaylistIDs) != 2 {
+		t.Fatalf("created pool = %+v", created)
+	}
+
+	body = bytes.NewBufferString(`{"name":"Long drive","playlistIds":["recent"]}`)
+	req = httptest.NewRequest("PUT", "/api/pools/"+created.ID, body)
+	req.SetPathValue("id", created.ID)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.HandleUpdatePool(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if updated := decodePool(t, w); updated.Name != "Long drive" || updated.Mode != models.ModeTrack || updated.ID != created.ID {
+		t.Errorf("updated pool = %+v", updated)
+	}
+
+	req = httptest.NewRequest("GET", "/api/pools", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.HandleListPools(w, req)
+
+	var pools []poolResponse
+	if err := json.NewDecoder(w.Body).Decode(&pools); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(pools) != 1 || pools[0].Name != "Long drive" {
+		t.Errorf("listed pools = %+v, want the updated pool", pools)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/pools/"+created.ID, nil)
+	req.SetPathValue("id", created.ID)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.HandleDeletePool(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if _, err := store.GetPool(created.ID); err == nil {
+		t.Error("pool still stored after delete")
+	}
+}
+
+func TestHandleCreatePoolInvalid(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	cookie := loginTestUser(t, store, "user1")
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", `{"name":`},
+		{"no name", `{"name":"  ","playlistIds":["liked"]}`},
+		{"long name", `{"name":"` + strings.Repeat("a", models.MaxPoolNameLength+1) + `","playlistIds":["liked"]}`},
+		{"no sources", `{"name":"Pool"}`},
+		{"invalid source", `{"name":"Pool","playlistIds":["top:forever"]}`},
+		{"invalid mode", `{"name":"Pool","playlistIds":["liked"],"mode":"karaoke"}`},
+		{"invalid choices", `{"name":"Pool","playlistIds":["liked"],"mode":"choice","choices":20}`},
+		{"pool search when guessing artists", `{"name":"Pool","playlistIds":["liked"],"mode":"artist","poolSearch":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/pools", bytes.NewBufferString(tt.body))
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandleCreatePool(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	if pools := store.ListPools("user1"); len(pools) != 0 {
+		t.Errorf("invalid requests saved pools: %+v", pools)
+	}
+}
+
+func TestPoolOwnership(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	store.SavePool(models.NewPool("pool1", "user1", "Road trip", []string{"liked"}))
+	cookie := loginTestUser(t, store, "user2")
+
+	for _, serve := range []http.HandlerFunc{handler.HandleGetPool, handler.HandleUpdatePool, handler.HandleDeletePool, handler.HandleSharePool} {
+		req := httptest.NewRequest("POST", "/api/pools/pool1", bytes.NewBufferString(`{"name":"Mine","playlistIds":["liked"]}`))
+		req.SetPathValue("id", "pool1")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		serve(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	}
+
+	pool, _ := store.GetPool("pool1")
+	if pool.Name != "Road trip" || pool.ShareID != "" {
+		t.Errorf("pool changed by another user: %+v", pool)
+	}
+}
+
+func TestShareAndCopyPool(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	owner := loginTestUser(t, store, "user1")
+	teammate := loginTestUser(t, store, "user2")
+	pool := models.NewPool("pool1", "user1", "Road trip", []string{"liked", "top:short_term"})
+	pool.Mode = models.ModeBlitz
+	store.SavePool(pool)
+
+	req := httptest.NewRequest("POST", "/api/pools/pool1/share", nil)
+	req.SetPathValue("id", "pool1")
+	req.AddCookie(owner)
+	w := httptest.NewRecorder()
+	handler.HandleSharePool(w, req)
+
+	shared := decodePool(t, w)
+	stored, _ := store.GetPool("pool1")
+	if stored.ShareID == "" || shared.ShareURL != "http://localhost:8080/playlists.html?pool="+stored.ShareID {
+		t.Fatalf("shared pool = %+v, share ID %q", shared, stored.ShareID)
+	}
+
+	req = httptest.NewRequest("GET", "/api/pools/shared/"+stored.ShareID, nil)
+	req.SetPathValue("shareId", stored.ShareID)
+	req.AddCookie(teammate)
+	w = httptest.NewRecorder()
+	handler.HandleGetSharedPool(w, req)
+
+	var view sharedPoolResponse
+	if err := json.NewDecoder(w.Body).Decode(&view); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if view.Name != "Road trip" || view.Owner != "Test User" || view.Mode != models.ModeBlitz {
+		t.Errorf("shared pool view = %+v", view)
+	}
+
+	req = httptest.NewRequest("POST", "/api/pools/shared/"+stored.ShareID+"/copy", nil)
+	req.SetPathValue("shareId", stored.ShareID)
+	req.AddCookie(teammate)
+	w = httptest.NewRecorder()
+	handler.HandleCopySharedPool(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("copy status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	copied := decodePool(t, w)
+	if copied.ID == "pool1" || copied.ShareURL != "" || copied.Mode != models.ModeBlitz || len(copied.PlaylistIDs) != 2 {
+		t.Errorf("copied pool = %+v", copied)
+	}
+	if pools := store.ListPools("user2"); len(pools) != 1 {
+		t.Errorf("teammate has %d pools, want 1", len(pools))
+	}
+}
+
+func TestHandleGetSharedPoolNotFound(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	cookie := loginTestUser(t, store, "user1")
+
+	req := httptest.NewRequest("GET", "/api/pools/shared/missing", nil)
+	req.SetPathValue("shareId", "missing")
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleGetSharedPool(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestNarrowPool(t *testing.T) {
+	combined := spotify.CombinedTracks{
+		Tracks: []models.Track{
+			{ID: "track1", ReleaseDate: "1977", PreviewURL: "https://p.scdn.co/mp3-preview/track1"},
+			{ID: "track2", ReleaseDate: "1985"},
+			{ID: "track3", ReleaseDate: "1979", Unplayable: true},
+			{ID: "spotify:local:a:b:c:1", IsLocal: true},
+			{ID: "track4"},
+		},
+		Duplicates: 2,
+	}
+
+	tracks, stats := narrowPool(combined, models.TrackFilter{}, models.ModeTrack, models.PlaybackSDK)
+	want := poolStats{Total: 7, DuplicatesRemoved: 2, UnavailableInMarket: 1, LocalFiles: 1, FilteredOut: 0, Playable: 3}
+	if stats != want || len(tracks) != 3 {
+		t.Errorf("narrowPool() stats = %+v, want %+v", stats, want)
+	}
+
+	// Year mode cannot use track4, which has no release date.
+	tracks, stats = narrowPool(combined, models.TrackFilter{MaxYear: 1980}, models.ModeYear, models.PlaybackSDK)
+	if stats.Playable != 1 || stats.FilteredOut != 2 || tracks[0].ID != "track1" {
+		t.Errorf("narrowPool() with filters = %+v, %+v", tracks, stats)
+	}
+
+	// Only track1 has a preview clip.
+	tracks, stats = narrowPool(combined, models.TrackFilter{}, models.ModeTrack, models.PlaybackPreview)
+	if stats.Playable != 1 || stats.NoPreview != 2 || stats.LocalFiles != 1 || stats.FilteredOut != 0 || tracks[0].ID != "track1" {
+		t.Errorf("narrowPool() for preview playback = %+v, %+v", tracks, stats)
+	}
+}
+
+func TestNarrowPoolCountsEachTrackOnce(t *testing.T) {
+	combined := spotify.CombinedTracks{
+		Tracks: []models.Track{
+			{ID: "track1", ReleaseDate: "1977", PreviewURL: "https://p.scdn.co/mp3-preview/track1"},
+			{ID: "track2", ReleaseDate: "1985"},
+			{ID: "track3", ReleaseDate: "1979", Unplayable: true},
+			{ID: "spotify:local:a:b:c:1", IsLocal: true},
+			{ID: "spotify:local:a:b:c:2", IsLocal: true, Unplayable: true},
+			{ID: "spotify:local:a:b:c:3", IsLocal: true, ReleaseDate: "1978"},
+			{ID: "track4"},
+		},
+		Duplicates: 2,
+	}
+	includeLocal := false
+
+	tests := []struct {
+		name     string
+		filter   models.TrackFilter
+		mode     models.GameMode
+		playback models.Playback
+	}{
+		{"no filters", models.TrackFilter{}, models.ModeTrack, models.PlaybackSDK},
+		{"year filter", models.TrackFilter{MaxYear: 1980}, models.ModeYear, models.PlaybackSDK},
+		{"local files included", models.TrackFilter{MaxYear: 1980, ExcludeLocalFiles: &includeLocal}, models.ModeTrack, models.PlaybackSDK},
+		{"preview playback", models.TrackFilter{}, models.ModeTrack, models.PlaybackPreview},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stats := narrowPool(combined, tt.filter, tt.mode, tt.playback)
+			sum := stats.DuplicatesRemoved + stats.UnavailableInMarket + stats.LocalFiles + stats.FilteredOut + stats.NoPreview + stats.Playable
+			if sum != stats.Total {
+				t.Errorf("categories of %+v add up to %d, want %d", stats, sum, stats.Total)
+			}
+		})
+	}
+}
+
+func TestHandlePreviewPoolInvalid(t *testing.T) {
+	handler, store := newTestPoolHandler()
+	cookie := loginTestUser(t, store, "user1")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid body", `{"playlistIds":`, http.StatusBadRequest},
+		{"no sources", `{}`, http.StatusBadRequest},
+		{"invalid filters", `{"playlistIds":["liked"],"filters":{"minDurationMs":-1}}`, http.StatusBadRequest},
+		{"missing pool", `{"poolId":"missing"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/pools/preview", bytes.NewBufferString(tt.body))
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+
+			handler.HandlePreviewPool(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
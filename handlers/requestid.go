@@ -0,0 +1,49 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"spotify-heardle/spotify"
+)
+
+// RequestIDHeader carries the ID of a request, so that a client's report of
+// an error can be matched with the server's logs.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID matches the request IDs accepted from clients and proxies.
+// Anything else is replaced, since the ID ends up in logs and responses.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// RequestID gives every request an ID, taken from its X-Request-ID header if
+// it has a valid one, and sets it on the response and the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		// Spotify requests made for this request are logged with its ID.
+		ctx = spotify.WithRequestID(ctx, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the ID RequestID gave the request, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
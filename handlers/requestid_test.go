@@ -0,0 +1,39 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, notFound("Not found"))
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"from client", "abc-123", true},
+		{"invalid", "bad id\nwith newline", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/nope", nil)
+		if tt.incoming != "" {
+			req.Header.Set(RequestIDHeader, tt.incoming)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) || (id == tt.incoming) != tt.keep {
+			t.Errorf("%s: request ID = %q", tt.name, id)
+		}
+	}
+}
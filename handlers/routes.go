@@ -0,0 +1,89 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// APIPrefix is the path under which every API route is registered. Requests
+// under it that match no route get a JSON error.
+const APIPrefix = "/api/"
+
+// probedMethods are the methods tried when working out which methods a path
+// allows.
+var probedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Router routes requests with method patterns on a ServeMux. API requests
+// that no route matches get a JSON error response instead of the ServeMux's
+// plain text one: a 405 with an Allow header if some route serves the path with
+// another method, and a 404 otherwise.
+type Router struct {
+	mux       *http.ServeMux
+	fallbacks map[string]bool
+}
+
+// NewRouter creates a router with no routes.
+func NewRouter() *Router {
+	rt := &Router{
+		mux:       http.NewServeMux(),
+		fallbacks: make(map[string]bool),
+	}
+	rt.Fallback(APIPrefix)
+	return rt
+}
+
+// Handle registers the handler for pattern.
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for pattern.
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.mux.HandleFunc(pattern, handler)
+}
+
+// Fallback answers requests matching pattern with a JSON 404 or 405. It
+// keeps literal paths away from wildcard routes that would otherwise match
+// them, such as GET /api/game/guess from GET /api/game/{id}.
+func (rt *Router) Fallback(pattern string) {
+	rt.fallbacks[pattern] = true
+	rt.mux.HandleFunc(pattern, rt.handleUnmatched)
+}
+
+// ServeHTTP dispatches the request to the handler whose pattern matches it.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+func (rt *Router) handleUnmatched(w http.ResponseWriter, r *http.Request) {
+	allowed := rt.allowedMethods(r)
+	if len(allowed) == 0 {
+		writeError(w, notFound("Not found"))
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"))
+}
+
+// allowedMethods returns the methods that reach a route other than a
+// fallback for the request's path.
+func (rt *Router) allowedMethods(r *http.Request) []string {
+	var allowed []string
+	for _, method := range probedMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := rt.mux.Handler(probe); pattern != "" && !rt.fallbacks[pattern] {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
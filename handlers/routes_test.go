@@ -0,0 +1,80 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	router := NewRouter()
+	router.HandleFunc("GET /api/pools/{id}", ok)
+	router.HandleFunc("DELETE /api/pools/{id}", ok)
+	router.HandleFunc("POST /api/game/guess", ok)
+	router.HandleFunc("GET /api/game/{id}", ok)
+	router.Fallback("GET /api/game/guess")
+	router.HandleFunc("GET /share/{shareId}", ok)
+	return router
+}
+
+func TestRouter(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{"GET", "/api/pools/abc", http.StatusOK, ""},
+		{"HEAD", "/api/pools/abc", http.StatusOK, ""},
+		{"PUT", "/api/pools/abc", http.StatusMethodNotAllowed, "GET, HEAD, DELETE"},
+		{"POST", "/api/game/guess", http.StatusOK, ""},
+		{"GET", "/api/game/guess", http.StatusMethodNotAllowed, "POST"},
+		{"GET", "/api/game/abc", http.StatusOK, ""},
+		{"POST", "/api/game/abc", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"GET", "/api/unknown", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, w.Code, tt.wantCode)
+		}
+		if got := w.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.wantAllow)
+		}
+	}
+}
+
+func TestRouterJSONErrors(t *testing.T) {
+	router := newTestRouter()
+
+	for _, path := range []string{"/api/unknown", "/api/game/guess"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var body errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Error.Code == "" {
+			t.Errorf("GET %s: body is not a JSON error: %v", path, err)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("GET %s: Content-Type = %q, want application/json", path, got)
+		}
+	}
+
+	// Pages outside the API keep the plain ServeMux responses.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/share/abc", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Content-Type") == "application/json" {
+		t.Errorf("POST /share/abc: status = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -2,42 +2,220 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"spotify-heardle/models"
+	"spotify-heardle/search"
 	"spotify-heardle/spotify"
+	"spotify-heardle/storage"
 )
 
+// defaultSearchLimit is the page size of search results when no limit is given.
+const defaultSearchLimit = 20
+
 // SearchHandler handles track search routes.
 type SearchHandler struct {
-	auth *AuthHandler
+	auth      *AuthHandler
+	store     *storage.MemoryStore
+	indexes   *search.Indexes
+	libraries *search.Libraries
+	cache     *spotify.SearchCache
+	// fetchLibrary loads the tracks indexed for a user's library. It can be
+	// replaced in tests.
+	fetchLibrary func(context.Context, *models.User) ([]models.Track, error)
 }
 
 // NewSearchHandler creates a new search handler.
-func NewSearchHandler(auth *AuthHandler) *SearchHandler {
-	return &SearchHandler{auth: auth}
+func NewSearchHandler(auth *AuthHandler, store *storage.MemoryStore, indexes *search.Indexes, libraries *search.Libraries, cache *spotify.SearchCache) *SearchHandler {
+	return &SearchHandler{
+		auth:      auth,
+		store:     store,
+		indexes:   indexes,
+		libraries: libraries,
+		cache:     cache,
+		fetchLibrary: func(ctx context.Context, user *models.User) ([]models.Track, error) {
+			return loadLibrary(newSpotifyClient(ctx, user))
+		},
+	}
 }
 
-// HandleSearch searches for tracks.
+// HandleSearch searches for tracks, or for artists or albums when the type
+// query parameter is "artist" or "album". With a sessionId parameter for a
+// game started with pool search, tracks are searched in the game's playlists
+// only, without calling Spotify.
+//
+// Track searches are answered from an index of the user's playlists and
+// liked songs when it has a good match, and from Spotify otherwise. Versions
+// of the same song are returned as one result with the others attached as
+// alternates. Results are paged with the limit and offset parameters.
 func (h *SearchHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	user, err := h.auth.GetUserFromSession(r)
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, errUnauthorized)
 		return
 	}
 
 	query := r.URL.Query().Get("q")
 	if query == "" {
-		http.Error(w, "Missing query parameter", http.StatusBadRequest)
+		writeError(w, invalidRequest("Missing query parameter"))
 		return
 	}
 
-	client := spotify.NewClient(user.Token)
-	tracks, err := client.SearchTracks(query)
+	limit, err := intParam(r.URL.Query().Get("limit"), defaultSearchLimit)
+	if err != nil || limit == 0 || limit > spotify.SearchLimit {
+		writeError(w, invalidRequest("Invalid limit parameter"))
+		return
+	}
+	offset, err := intParam(r.URL.Query().Get("offset"), 0)
 	if err != nil {
-		http.Error(w, "Failed to search tracks", http.StatusInternalServerError)
+		writeError(w, invalidRequest("Invalid offset parameter"))
+		return
+	}
+
+	if sessionID := r.URL.Query().Get("sessionId"); sessionID != "" {
+		h.searchPool(w, r, user.ID, sessionID, query, offset, limit)
+		return
+	}
+
+	client := newSpotifyClient(r.Context(), user)
+	client.SetSearchCache(h.cache)
+
+	var results interface{}
+	switch r.URL.Query().Get("type") {
+	case "", "track":
+		var tracks []models.Track
+		tracks, err = h.searchTracks(r.Context(), client, user.ID, query)
+		results = page(search.GroupVersions(tracks), offset, limit)
+	case "artist":
+		var artists []spotify.Artist
+		artists, err = client.SearchArtists(query)
+		results = page(artists, offset, limit)
+	case "album":
+		var albums []spotify.Album
+		albums, err = client.SearchAlbums(query)
+		results = page(albums, offset, limit)
+	default:
+		writeError(w, invalidRequest("Invalid type parameter"))
+		return
+	}
+
+	if err != nil {
+		writeError(w, spotifyError(err, "Failed to search"))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tracks)
+	json.NewEncoder(w).Encode(results)
+}
+
+// searchPool answers a search from the pool index of the user's game.
+func (h *SearchHandler) searchPool(w http.ResponseWriter, r *http.Request, userID, sessionID, query string, offset, limit int) {
+	if t := r.URL.Query().Get("type"); t != "" && t != "track" {
+		writeError(w, invalidRequest("Pool search only supports tracks"))
+		return
+	}
+
+	session, err := h.store.GetSession(sessionID)
+	if err != nil {
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	}
+
+	if session.UserID != userID {
+		writeError(w, errForbidden)
+		return
+	}
+
+	index, ok := h.indexes.Get(session.ID)
+	if !ok {
+		writeError(w, notFound("Pool search is not enabled for this game"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	tracks := index.Search(query, spotify.SearchLimit)
+	json.NewEncoder(w).Encode(page(search.GroupVersions(tracks), offset, limit))
+}
+
+// searchTracks answers a track search from the user's library index when it
+// has a match without typos. Otherwise Spotify is searched too, and library
+// matches are listed before Spotify's results.
+func (h *SearchHandler) searchTracks(ctx context.Context, client *spotify.Client, userID, query string) ([]models.Track, error) {
+	var local []models.Track
+	index, ok := h.libraries.Get(userID, h.libraryLoader(ctx, userID))
+	if ok {
+		var confident bool
+		local, confident = index.Lookup(query, spotify.SearchLimit)
+		if confident {
+			return local, nil
+		}
+	}
+
+	remote, err := client.SearchTracks(query)
+	if err != nil {
+		if len(local) > 0 {
+			return local, nil
+		}
+		return nil, err
+	}
+	return mergeTracks(local, remote), nil
+}
+
+// libraryLoader returns the loader that builds the user's library in the
+// background. It looks the user up when it runs rather than using the
+// request's client, so it gets their current token, refreshed if need be,
+// however long after the request the build starts. The build keeps the
+// request's ID for logging but is not canceled when the request ends.
+func (h *SearchHandler) libraryLoader(ctx context.Context, userID string) search.Loader {
+	ctx = context.WithoutCancel(ctx)
+	return func() ([]models.Track, error) {
+		user, err := h.auth.currentUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return h.fetchLibrary(ctx, user)
+	}
+}
+
+// loadLibrary fetches the tracks of the user's playlists and liked songs.
+func loadLibrary(client *spotify.Client) ([]models.Track, error) {
+	playlists, err := client.GetUserPlaylists()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{spotify.SourceRef{Kind: spotify.SourceLiked}.String()}
+	for _, playlist := range playlists {
+		ids = append(ids, playlist.ID)
+	}
+	return client.GetMultiplePlaylistsTracks(ids)
+}
+
+// mergeTracks appends the tracks of b to a, skipping tracks already in a.
+func mergeTracks(a, b []models.Track) []models.Track {
+	merged := make([]models.Track, 0, len(a)+len(b))
+	seen := make(map[string]bool, len(a)+len(b))
+	for _, tracks := range [][]models.Track{a, b} {
+		for _, track := range tracks {
+			if !seen[track.ID] {
+				seen[track.ID] = true
+				merged = append(merged, track)
+			}
+		}
+	}
+	return merged
+}
+
+// page returns the items in [offset, offset+limit), which is empty rather
+// than nil past the end so it encodes as a JSON array.
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
 }
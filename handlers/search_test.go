@@ -2,11 +2,17 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"spotify-heardle/config"
+	"spotify-heardle/models"
+	"spotify-heardle/search"
+	"spotify-heardle/spotify"
 	"spotify-heardle/storage"
 	"testing"
+	"time"
 )
 
 func TestNewSearchHandler(t *testing.T) {
@@ -19,7 +25,7 @@ func TestNewSearchHandler(t *testing.T) {
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
 
-	handler := NewSearchHandler(authHandler)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
 
 	if handler == nil {
 		t.Fatal("NewSearchHandler() returned nil")
@@ -35,7 +41,7 @@ func TestHandleSearchNoAuth(t *testing.T) {
 	}
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
-	handler := NewSearchHandler(authHandler)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
 
 	req := httptest.NewRequest("GET", "/api/search?q=test", nil)
 	w := httptest.NewRecorder()
@@ -56,7 +62,7 @@ func TestHandleSearchMissingQuery(t *testing.T) {
 	}
 	store := storage.NewMemoryStore()
 	authHandler := NewAuthHandler(cfg, store)
-	handler := NewSearchHandler(authHandler)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
 
 	req := httptest.NewRequest("GET", "/api/search", nil)
 	w := httptest.NewRecorder()
@@ -67,3 +73,239 @@ func TestHandleSearchMissingQuery(t *testing.T) {
 		t.Errorf("status = %d, want %d (unauthorized because no session)", w.Code, http.StatusUnauthorized)
 	}
 }
+
+func TestHandleSearchInvalidType(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+
+	req := httptest.NewRequest("GET", "/api/search?q=test&type=playlist", nil)
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleSearch(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleSearchPool(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	indexes := search.NewIndexes(search.DefaultIndexTTL)
+	handler := NewSearchHandler(authHandler, store, indexes, search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+	indexes.Put("session123", search.NewIndex([]models.Track{
+		{ID: "track1", Name: "Bohemian Rhapsody", Artists: []string{"Queen"}},
+		{ID: "track2", Name: "Under Pressure", Artists: []string{"Queen", "David Bowie"}},
+	}))
+
+	req := httptest.NewRequest("GET", "/api/search?q=bohem&sessionId=session123", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleSearch(w, req)
+
+	var tracks []models.Track
+	if err := json.NewDecoder(w.Body).Decode(&tracks); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(tracks) != 1 || tracks[0].ID != "track1" {
+		t.Errorf("tracks = %+v, want only track1", tracks)
+	}
+}
+
+func TestHandleSearchPoolNotEnabled(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	req := httptest.NewRequest("GET", "/api/search?q=queen&sessionId=session123", nil)
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleSearch(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleSearchLibrary(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	libraries := search.NewLibraries(search.DefaultLibraryMaxAge)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), libraries, spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	err := libraries.Build("user1", func() ([]models.Track, error) {
+		return []models.Track{{ID: "track1", Name: "Déjà Vu", Artists: []string{"Beyoncé"}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/search?q=deja+beyonce", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+
+	handler.HandleSearch(w, req)
+
+	var tracks []models.Track
+	if err := json.NewDecoder(w.Body).Decode(&tracks); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(tracks) != 1 || tracks[0].ID != "track1" {
+		t.Errorf("tracks = %+v, want track1 from the library", tracks)
+	}
+}
+
+func TestLibraryLoaderUsesCurrentToken(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+	loginTestUser(t, store, "user1")
+
+	var used string
+	handler.fetchLibrary = func(_ context.Context, user *models.User) ([]models.Track, error) {
+		used = user.Token.AccessToken
+		return nil, nil
+	}
+	load := handler.libraryLoader(context.Background(), "user1")
+
+	// The token is refreshed between the request and the background build.
+	user, _ := store.GetUser("user1")
+	refreshed := *user
+	refreshed.Token = &models.Token{AccessToken: "refreshed_token", ExpiresAt: time.Now().Add(time.Hour)}
+	store.SaveUser(&refreshed)
+
+	if _, err := load(); err != nil {
+		t.Fatalf("loader failed: %v", err)
+	}
+	if used != "refreshed_token" {
+		t.Errorf("library loaded with token %q, want the current one", used)
+	}
+}
+
+func TestMergeTracks(t *testing.T) {
+	local := []models.Track{{ID: "a"}, {ID: "b"}}
+	remote := []models.Track{{ID: "b"}, {ID: "c"}}
+
+	merged := mergeTracks(local, remote)
+
+	if len(merged) != 3 || merged[0].ID != "a" || merged[1].ID != "b" || merged[2].ID != "c" {
+		t.Errorf("mergeTracks() = %+v, want a, b, c", merged)
+	}
+}
+
+func TestHandleSearchInvalidPaging(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewSearchHandler(authHandler, store, search.NewIndexes(search.DefaultIndexTTL), search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	for _, params := range []string{"limit=0", "limit=51", "limit=ten", "offset=-1"} {
+		req := httptest.NewRequest("GET", "/api/search?q=test&"+params, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleSearch(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", params, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleSearchPoolGroupsAndPages(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	indexes := search.NewIndexes(search.DefaultIndexTTL)
+	handler := NewSearchHandler(authHandler, store, indexes, search.NewLibraries(search.DefaultLibraryMaxAge), spotify.NewSearchCache(spotify.DefaultSearchCacheTTL))
+	cookie := loginTestUser(t, store, "user1")
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+	indexes.Put("session123", search.NewIndex([]models.Track{
+		{ID: "track1", Name: "Heroes", Artists: []string{"David Bowie"}},
+		{ID: "track2", Name: "Heroes - 2017 Remaster", Artists: []string{"David Bowie"}},
+		{ID: "track3", Name: "Heroes (Live)", Artists: []string{"David Bowie"}},
+		{ID: "track4", Name: "Heroes", Artists: []string{"Peter Gabriel"}},
+	}))
+
+	get := func(params string) []search.Group {
+		req := httptest.NewRequest("GET", "/api/search?q=heroes&sessionId=session123&"+params, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+
+		handler.HandleSearch(w, req)
+
+		var groups []search.Group
+		if err := json.NewDecoder(w.Body).Decode(&groups); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return groups
+	}
+
+	first := get("limit=1")
+	if len(first) != 1 || first[0].ID != "track1" || len(first[0].Alternates) != 2 {
+		t.Fatalf("first page = %+v, want track1 with two alternates", first)
+	}
+
+	second := get("limit=1&offset=1")
+	if len(second) != 1 || second[0].ID != "track4" {
+		t.Errorf("second page = %+v, want track4", second)
+	}
+
+	if past := get("offset=5"); len(past) != 0 {
+		t.Errorf("page past the end = %+v, want empty", past)
+	}
+}
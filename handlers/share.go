@@ -0,0 +1,322 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"strings"
+)
+
+// Open Graph image dimensions recommended by most link unfurlers.
+const (
+	shareImageWidth  = 1200
+	shareImageHeight = 630
+)
+
+// shareSquare is the outcome of one guess slot in a result grid.
+type shareSquare int
+
+const (
+	squareUnused shareSquare = iota
+	squareWrong
+	squareArtist
+	squareCorrect
+)
+
+var squareEmoji = map[shareSquare]string{
+	squareUnused:  "⬛",
+	squareWrong:   "🟥",
+	squareArtist:  "🟨",
+	squareCorrect: "🟩",
+}
+
+var squareColor = map[shareSquare]color.RGBA{
+	squareUnused:  {R: 0x3a, G: 0x3a, B: 0x3a, A: 0xff},
+	squareWrong:   {R: 0xd3, G: 0x2f, B: 0x2f, A: 0xff},
+	squareArtist:  {R: 0xf9, G: 0xa8, B: 0x25, A: 0xff},
+	squareCorrect: {R: 0x4c, G: 0xaf, B: 0x50, A: 0xff},
+}
+
+var shareBackground = color.RGBA{R: 0x1a, G: 0x1a, B: 0x1a, A: 0xff}
+
+var sharePageTemplate = template.Must(template.New("share").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - Spotify Heardle</title>
+    <meta property="og:type" content="website">
+    <meta property="og:title" content="Spotify Heardle: {{.Title}}">
+    <meta property="og:description" content="{{.Grid}}">
+    <meta property="og:url" content="{{.Permalink}}">
+    <meta property="og:image" content="{{.ImageURL}}">
+    <meta property="og:image:width" content="1200">
+    <meta property="og:image:height" content="630">
+    <meta name="twitter:card" content="summary_large_image">
+    <link rel="stylesheet" href="/css/styles.css">
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Spotify Heardle</h1>
+            <p>{{.Title}}</p>
+        </div>
+
+        <div class="content">
+            <div class="card">
+                <h2>{{.Grid}}</h2>
+                {{if .Answer}}
+                <p><strong>{{.Answer.Name}}</strong> by {{join .Answer.Artists ", "}}</p>
+                {{else}}
+                <p>Play a game with this song to reveal the answer.</p>
+                {{end}}
+                <button class="btn-primary" onclick="window.location.href='/login'">
+                    Play Spotify Heardle
+                </button>
+            </div>
+        </div>
+    </div>
+</body>
+</html>
+`))
+
+// ShareHandler handles shareable game results.
+type ShareHandler struct {
+	auth    *AuthHandler
+	store   *storage.MemoryStore
+	baseURL string
+}
+
+type shareResponse struct {
+	Text      string `json:"text"`
+	Grid      string `json:"grid"`
+	Permalink string `json:"permalink"`
+	ImageURL  string `json:"imageUrl"`
+}
+
+type sharePage struct {
+	Title     string
+	Grid      string
+	Permalink string
+	ImageURL  string
+	Answer    *models.Track
+}
+
+// NewShareHandler creates a new share handler. Permalinks are made absolute
+// with baseURL.
+func NewShareHandler(auth *AuthHandler, store *storage.MemoryStore, baseURL string) *ShareHandler {
+	return &ShareHandler{
+		auth:    auth,
+		store:   store,
+		baseURL: baseURL,
+	}
+}
+
+// HandleCreateShare returns a spoiler-free share payload for a completed game,
+// assigning it a public permalink on first use.
+func (h *ShareHandler) HandleCreateShare(w http.ResponseWriter, r *http.Request) {
+	user, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		writeError(w, errUnauthorized)
+		return
+	}
+
+	session, err := h.store.GetSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, lookupError(err, "Session not found"))
+		return
+	}
+
+	if session.UserID != user.ID {
+		writeError(w, errForbidden)
+		return
+	}
+
+	if !session.IsComplete {
+		writeError(w, newAPIError(http.StatusBadRequest, CodeGameInProgress, "Game is not complete"))
+		return
+	}
+
+	if session.ShareID == "" {
+		shareID, err := generateShareID()
+		if err != nil {
+			writeError(w, internalError("Failed to generate share ID").withCause(err))
+			return
+		}
+		session, err = h.store.UpdateSession(session.ID, func(session *models.GameSession) {
+			// Another request may have shared the game in the meantime.
+			if session.ShareID == "" {
+				session.ShareID = shareID
+			}
+		})
+		if err != nil {
+			writeError(w, lookupError(err, "Session not found"))
+			return
+		}
+	}
+
+	permalink := sharePermalink(h.baseURL, session.ShareID)
+	grid := shareGrid(session)
+
+	response := shareResponse{
+		Text:      fmt.Sprintf("Spotify Heardle %s\n\n🔊%s\n\n%s", shareScore(session), grid, permalink),
+		Grid:      grid,
+		Permalink: permalink,
+		ImageURL:  permalink + "/image.png",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleSharePage renders the public result page. The answer is only shown to
+// viewers who have finished a game with the same song themselves.
+func (h *ShareHandler) HandleSharePage(w http.ResponseWriter, r *http.Request) {
+	session, err := h.store.GetSessionByShareID(r.PathValue("shareId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	permalink := sharePermalink(h.baseURL, session.ShareID)
+	page := sharePage{
+		Title:     shareTitle(session),
+		Grid:      shareGrid(session),
+		Permalink: permalink,
+		ImageURL:  permalink + "/image.png",
+	}
+
+	if h.viewerHasPlayed(r, session) {
+		answer := session.CorrectSong
+		page.Answer = &answer
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sharePageTemplate.Execute(w, page); err != nil {
+		writeError(w, internalError("Failed to render page").withCause(err))
+	}
+}
+
+// HandleShareImage renders the result grid as an Open Graph PNG image.
+func (h *ShareHandler) HandleShareImage(w http.ResponseWriter, r *http.Request) {
+	session, err := h.store.GetSessionByShareID(r.PathValue("shareId"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	png.Encode(w, renderShareImage(shareSquares(session)))
+}
+
+func (h *ShareHandler) viewerHasPlayed(r *http.Request, session *models.GameSession) bool {
+	viewer, err := h.auth.GetUserFromSession(r)
+	if err != nil {
+		return false
+	}
+	return viewer.ID == session.UserID || h.store.HasCompletedTrack(viewer.ID, session.CorrectSong.ID)
+}
+
+// shareSquares classifies every guess slot of a session, padding unused
+// slots when the game was won early or skipped. The guesses of a blitz game
+// span many songs, so it gets a square for each song it solved instead, or a
+// single miss if it solved none.
+func shareSquares(session *models.GameSession) []shareSquare {
+	if session.Mode == models.ModeBlitz {
+		if session.Solved == 0 {
+			return []shareSquare{squareWrong}
+		}
+		squares := make([]shareSquare, session.Solved)
+		for i := range squares {
+			squares[i] = squareCorrect
+		}
+		return squares
+	}
+
+	squares := make([]shareSquare, models.MaxGuesses)
+	for i, guess := range session.Guesses {
+		if i >= len(squares) {
+			break
+		}
+		switch {
+		case guess.IsCorrect:
+			squares[i] = squareCorrect
+		case guess.SharesArtist(session.CorrectSong):
+			squares[i] = squareArtist
+		default:
+			squares[i] = squareWrong
+		}
+	}
+	return squares
+}
+
+func shareGrid(session *models.GameSession) string {
+	var b strings.Builder
+	for _, square := range shareSquares(session) {
+		b.WriteString(squareEmoji[square])
+	}
+	return b.String()
+}
+
+func shareScore(session *models.GameSession) string {
+	if session.Mode == models.ModeBlitz {
+		return fmt.Sprintf("Blitz %d solved", session.Solved)
+	}
+	if !session.Won {
+		return fmt.Sprintf("X/%d", models.MaxGuesses)
+	}
+	return fmt.Sprintf("%d/%d", session.GuessesUsed, models.MaxGuesses)
+}
+
+func shareTitle(session *models.GameSession) string {
+	if session.Mode == models.ModeBlitz {
+		switch session.Solved {
+		case 0:
+			return "Missed every song"
+		case 1:
+			return "Solved 1 song in blitz"
+		}
+		return fmt.Sprintf("Solved %d songs in blitz", session.Solved)
+	}
+	if !session.Won {
+		return "Missed this one"
+	}
+	if session.GuessesUsed == 1 {
+		return "Got it in 1 guess"
+	}
+	return fmt.Sprintf("Got it in %d guesses", session.GuessesUsed)
+}
+
+func renderShareImage(squares []shareSquare) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, shareImageWidth, shareImageHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: shareBackground}, image.Point{}, draw.Src)
+
+	// Squares shrink to fit the longer grids of blitz games.
+	const margin = 40
+	unit := min(220, (shareImageWidth-2*margin)/len(squares))
+	size := unit * 9 / 11
+	width := len(squares)*unit - (unit - size)
+	left := (shareImageWidth - width) / 2
+	top := (shareImageHeight - size) / 2
+
+	for i, square := range squares {
+		x := left + i*unit
+		rect := image.Rect(x, top, x+size, top+size)
+		draw.Draw(img, rect, &image.Uniform{C: squareColor[square]}, image.Point{}, draw.Src)
+	}
+
+	return img
+}
+
+func sharePermalink(baseURL, shareID string) string {
+	return baseURL + "/share/" + shareID
+}
@@ -0,0 +1,264 @@
+// Package handlers provides HTTP request handlers.
+package handlers
+
+import (
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"spotify-heardle/config"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCompletedSession(id, userID string) *models.GameSession {
+	session := models.NewGameSession(id, userID, []string{"playlist1"}, models.Track{
+		ID:      "track1",
+		Name:    "Secret Song",
+		Artists: []string{"Artist A"},
+	})
+	session.AddGuess(models.Guess{TrackID: "wrong", TrackName: "Wrong", Artists: []string{"Artist B"}}, time.Now())
+	session.AddGuess(models.Guess{TrackID: "close", TrackName: "Close", Artists: []string{"Artist A"}}, time.Now())
+	session.AddGuess(models.Guess{TrackID: "track1", TrackName: "Secret Song", IsCorrect: true}, time.Now())
+	return session
+}
+
+func TestShareGrid(t *testing.T) {
+	tests := []struct {
+		name    string
+		guesses []models.Guess
+		want    string
+	}{
+		{"first guess", []models.Guess{{IsCorrect: true}}, "🟩⬛⬛"},
+		{"artist match", []models.Guess{{Artists: []string{"Artist A"}}, {IsCorrect: true}}, "🟨🟩⬛"},
+		{"all wrong", []models.Guess{{}, {}, {}}, "🟥🟥🟥"},
+		{"skipped", nil, "⬛⬛⬛"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &models.GameSession{
+				CorrectSong: models.Track{ID: "track1", Artists: []string{"Artist A"}},
+				Guesses:     tt.guesses,
+			}
+			if got := shareGrid(session); got != tt.want {
+				t.Errorf("shareGrid() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShareBlitz(t *testing.T) {
+	// Solved the first song in one guess, then ran out of time on the second.
+	session := &models.GameSession{
+		Mode:        models.ModeBlitz,
+		CorrectSong: models.Track{ID: "track2", Artists: []string{"Artist B"}},
+		Guesses:     []models.Guess{{IsCorrect: true}, {Artists: []string{"Artist B"}}},
+		Solved:      1,
+		Won:         true,
+	}
+
+	if got := shareGrid(session); got != "🟩" {
+		t.Errorf("shareGrid() = %q, want one square for the solved song", got)
+	}
+	if got := shareScore(session); got != "Blitz 1 solved" {
+		t.Errorf("shareScore() = %q, want %q", got, "Blitz 1 solved")
+	}
+	if got := shareTitle(session); got != "Solved 1 song in blitz" {
+		t.Errorf("shareTitle() = %q, want %q", got, "Solved 1 song in blitz")
+	}
+
+	session.Solved, session.Won = 0, false
+	if got := shareGrid(session); got != "🟥" {
+		t.Errorf("shareGrid() with nothing solved = %q, want a single miss", got)
+	}
+}
+
+func TestRenderShareImageFitsLongGrids(t *testing.T) {
+	squares := make([]shareSquare, 40)
+	for i := range squares {
+		squares[i] = squareCorrect
+	}
+	img := renderShareImage(squares)
+
+	// Count the squares crossed by the middle row of the image.
+	y := shareImageHeight / 2
+	count, inSquare := 0, false
+	for x := 0; x < shareImageWidth; x++ {
+		background := img.At(x, y) == color.Color(shareBackground)
+		if !background && !inSquare {
+			count++
+		}
+		inSquare = !background
+	}
+	if count != len(squares) || inSquare {
+		t.Errorf("image shows %d whole squares, want %d", count, len(squares))
+	}
+}
+
+func TestHandleCreateShare(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewShareHandler(authHandler, store, "http://localhost:8080")
+
+	store.SaveSession(newCompletedSession("session123", "user1"))
+
+	req := httptest.NewRequest("POST", "/api/game/session123/share", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	// Permalinks use the configured base URL, not the Host header.
+	req.Host = "attacker.example"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+
+	handler.HandleCreateShare(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var response shareResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if response.Grid != "🟥🟨🟩" {
+		t.Errorf("Grid = %q, want %q", response.Grid, "🟥🟨🟩")
+	}
+
+	if strings.Contains(response.Text, "Secret Song") || strings.Contains(response.Text, "track1") {
+		t.Errorf("Text leaks the answer: %q", response.Text)
+	}
+
+	if !strings.Contains(response.Text, "3/3") {
+		t.Errorf("Text = %q, want score 3/3", response.Text)
+	}
+
+	session, _ := store.GetSession("session123")
+	if response.Permalink != "http://localhost:8080/share/"+session.ShareID {
+		t.Errorf("Permalink = %q, want share ID %q", response.Permalink, session.ShareID)
+	}
+}
+
+func TestHandleCreateShareIncompleteGame(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewShareHandler(authHandler, store, "http://localhost:8080")
+
+	store.SaveSession(models.NewGameSession("session123", "user1", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	req := httptest.NewRequest("POST", "/api/game/session123/share", nil)
+	req.SetPathValue("id", "session123")
+	req.AddCookie(loginTestUser(t, store, "user1"))
+	w := httptest.NewRecorder()
+
+	handler.HandleCreateShare(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleSharePageRevealsAnswerOnlyToPlayers(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewShareHandler(authHandler, store, "http://localhost:8080")
+
+	session := newCompletedSession("session123", "user1")
+	session.ShareID = "share1"
+	store.SaveSession(session)
+	store.SaveSession(newCompletedSession("session456", "viewer"))
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		reveal bool
+	}{
+		{"anonymous", nil, false},
+		{"not played", loginTestUser(t, store, "stranger"), false},
+		{"played same song", loginTestUser(t, store, "viewer"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/share/share1", nil)
+			req.SetPathValue("shareId", "share1")
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+
+			handler.HandleSharePage(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			body := w.Body.String()
+			if !strings.Contains(body, `property="og:image"`) {
+				t.Error("page missing og:image meta tag")
+			}
+
+			if got := strings.Contains(body, "Secret Song"); got != tt.reveal {
+				t.Errorf("answer revealed = %v, want %v", got, tt.reveal)
+			}
+		})
+	}
+}
+
+func TestHandleShareImage(t *testing.T) {
+	cfg := &config.Config{
+		SpotifyClientID:     "test_id",
+		SpotifyClientSecret: "test_secret",
+		SpotifyRedirectURI:  "http://localhost:8080/callback",
+		SessionSecret:       "test_session_secret",
+	}
+	store := storage.NewMemoryStore()
+	authHandler := NewAuthHandler(cfg, store)
+	handler := NewShareHandler(authHandler, store, "http://localhost:8080")
+
+	session := newCompletedSession("session123", "user1")
+	session.ShareID = "share1"
+	store.SaveSession(session)
+
+	req := httptest.NewRequest("GET", "/share/share1/image.png", nil)
+	req.SetPathValue("shareId", "share1")
+	w := httptest.NewRecorder()
+
+	handler.HandleShareImage(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != shareImageWidth || bounds.Dy() != shareImageHeight {
+		t.Errorf("image size = %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), shareImageWidth, shareImageHeight)
+	}
+}
@@ -0,0 +1,215 @@
+// Package leaderboard ranks players by their completed games.
+package leaderboard
+
+import (
+	"fmt"
+	"sort"
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"time"
+)
+
+// DefaultMinGames is the number of games a player needs before being ranked
+// by win rate.
+const DefaultMinGames = 5
+
+const defaultLimit = 50
+
+// Metric is the statistic players are ranked by.
+type Metric string
+
+// Supported ranking metrics.
+const (
+	MetricScore   Metric = "score"
+	MetricWinRate Metric = "winRate"
+	MetricStreak  Metric = "streak"
+)
+
+// Window limits the games counted towards a leaderboard.
+type Window string
+
+// Supported time windows. Weeks start on Monday; all windows use UTC.
+const (
+	WindowAll   Window = "all"
+	WindowWeek  Window = "week"
+	WindowMonth Window = "month"
+)
+
+// Query describes which leaderboard to build.
+type Query struct {
+	Metric   Metric
+	Window   Window
+	Mode     models.GameMode
+	PoolKey  string
+	MinGames int
+	Limit    int
+	Now      time.Time
+}
+
+// Entry is one ranked player.
+type Entry struct {
+	Rank        int     `json:"rank"`
+	UserID      string  `json:"-"`
+	DisplayName string  `json:"displayName"`
+	Score       int     `json:"score"`
+	Games       int     `json:"games"`
+	Wins        int     `json:"wins"`
+	WinRate     float64 `json:"winRate"`
+	Streak      int     `json:"streak"`
+}
+
+// Service builds leaderboards from stored game sessions.
+type Service struct {
+	store *storage.MemoryStore
+}
+
+// NewService creates a new leaderboard service.
+func NewService(store *storage.MemoryStore) *Service {
+	return &Service{store: store}
+}
+
+// Rank returns the leaderboard for a query, best player first. Players who
+// opted out of leaderboards are never included.
+func (s *Service) Rank(query Query) ([]Entry, error) {
+	if !validMetric(query.Metric) {
+		return nil, fmt.Errorf("unknown metric: %s", query.Metric)
+	}
+
+	from, err := windowStart(query.Window, query.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	minGames := query.MinGames
+	if minGames <= 0 {
+		minGames = DefaultMinGames
+	}
+
+	byUser := make(map[string][]*models.GameSession)
+	for _, session := range s.store.CompletedSessionsBetween(from, time.Time{}) {
+		if query.PoolKey != "" && session.PoolKey() != query.PoolKey {
+			continue
+		}
+		if query.Mode != "" && session.Mode != query.Mode {
+			continue
+		}
+		byUser[session.UserID] = append(byUser[session.UserID], session)
+	}
+
+	entries := make([]Entry, 0, len(byUser))
+	for userID, sessions := range byUser {
+		user, err := s.store.GetUser(userID)
+		if err != nil || user.LeaderboardOptOut {
+			continue
+		}
+
+		entry := summarize(sessions)
+		entry.UserID = user.ID
+		entry.DisplayName = user.DisplayName
+
+		if query.Metric == MetricWinRate && entry.Games < minGames {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sortEntries(entries, query.Metric)
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// summarize computes a player's statistics. Streak is the longest run of
+// consecutive wins in completion order.
+func summarize(sessions []*models.GameSession) Entry {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CompletedAt.Before(sessions[j].CompletedAt)
+	})
+
+	var entry Entry
+	streak := 0
+	for _, session := range sessions {
+		entry.Games++
+		entry.Score += session.Points()
+		if session.Won {
+			entry.Wins++
+			streak++
+			if streak > entry.Streak {
+				entry.Streak = streak
+			}
+		} else {
+			streak = 0
+		}
+	}
+
+	if entry.Games > 0 {
+		entry.WinRate = float64(entry.Wins) / float64(entry.Games)
+	}
+	return entry
+}
+
+// sortEntries orders entries by metric and assigns ranks, giving tied
+// players the same rank.
+func sortEntries(entries []Entry, metric Metric) {
+	value := func(e Entry) float64 {
+		switch metric {
+		case MetricWinRate:
+			return e.WinRate
+		case MetricStreak:
+			return float64(e.Streak)
+		default:
+			return float64(e.Score)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		vi, vj := value(entries[i]), value(entries[j])
+		if vi != vj {
+			return vi > vj
+		}
+		if entries[i].Games != entries[j].Games {
+			return entries[i].Games > entries[j].Games
+		}
+		return entries[i].DisplayName < entries[j].DisplayName
+	})
+
+	for i := range entries {
+		if i > 0 && value(entries[i]) == value(entries[i-1]) {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
+
+func validMetric(metric Metric) bool {
+	switch metric {
+	case MetricScore, MetricWinRate, MetricStreak:
+		return true
+	}
+	return false
+}
+
+// windowStart returns the start of the window containing now, or the zero
+// time for all-time leaderboards.
+func windowStart(window Window, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	switch window {
+	case WindowAll, "":
+		return time.Time{}, nil
+	case WindowWeek:
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset), nil
+	case WindowMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown window: %s", window)
+}
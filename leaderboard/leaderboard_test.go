@@ -0,0 +1,159 @@
+// Package leaderboard ranks players by their completed games.
+package leaderboard
+
+import (
+	"spotify-heardle/models"
+	"spotify-heardle/storage"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC) // a Wednesday
+
+func saveGame(store *storage.MemoryStore, id, userID string, won bool, guesses int, completedAt time.Time, playlistIDs ...string) {
+	if len(playlistIDs) == 0 {
+		playlistIDs = []string{"p1"}
+	}
+	store.SaveSession(&models.GameSession{
+		ID:          id,
+		UserID:      userID,
+		PlaylistIDs: playlistIDs,
+		GuessesUsed: guesses,
+		IsComplete:  true,
+		Won:         won,
+		CompletedAt: completedAt,
+	})
+}
+
+func newTestStore() *storage.MemoryStore {
+	store := storage.NewMemoryStore()
+	store.SaveUser(&models.User{ID: "alice", DisplayName: "Alice"})
+	store.SaveUser(&models.User{ID: "bob", DisplayName: "Bob"})
+	store.SaveUser(&models.User{ID: "carol", DisplayName: "Carol", LeaderboardOptOut: true})
+
+	day := testNow.Add(-1 * time.Hour)
+	saveGame(store, "a1", "alice", true, 1, day.Add(-3*time.Minute))
+	saveGame(store, "a2", "alice", false, 3, day.Add(-2*time.Minute))
+	saveGame(store, "a3", "alice", true, 2, day.Add(-1*time.Minute), "p2")
+	saveGame(store, "b1", "bob", true, 3, day.Add(-3*time.Minute))
+	saveGame(store, "b2", "bob", true, 3, day.Add(-2*time.Minute))
+	saveGame(store, "b3", "bob", true, 3, day.Add(-1*time.Minute))
+	saveGame(store, "b-old", "bob", true, 1, testNow.AddDate(0, -2, 0))
+	saveGame(store, "c1", "carol", true, 1, day)
+	return store
+}
+
+func rankedNames(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.DisplayName
+	}
+	return names
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"score all time", Query{Metric: MetricScore, Window: WindowAll}, []string{"Bob", "Alice"}},
+		{"score this week", Query{Metric: MetricScore, Window: WindowWeek}, []string{"Alice", "Bob"}},
+		{"streak this month", Query{Metric: MetricStreak, Window: WindowMonth}, []string{"Bob", "Alice"}},
+		{"win rate threshold", Query{Metric: MetricWinRate, Window: WindowWeek, MinGames: 3}, []string{"Bob", "Alice"}},
+		{"win rate excludes few games", Query{Metric: MetricWinRate, Window: WindowAll, MinGames: 4}, []string{"Bob"}},
+		{"pool", Query{Metric: MetricScore, Window: WindowAll, PoolKey: "p2"}, []string{"Alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewService(newTestStore())
+			tt.query.Now = testNow
+
+			entries, err := service.Rank(tt.query)
+			if err != nil {
+				t.Fatalf("Rank() failed: %v", err)
+			}
+
+			got := rankedNames(entries)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ranking = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ranking = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRankStatistics(t *testing.T) {
+	service := NewService(newTestStore())
+
+	entries, err := service.Rank(Query{Metric: MetricScore, Window: WindowWeek, Now: testNow})
+	if err != nil {
+		t.Fatalf("Rank() failed: %v", err)
+	}
+
+	alice := entries[0]
+	if alice.Score != 5 || alice.Games != 3 || alice.Wins != 2 || alice.Streak != 1 {
+		t.Errorf("alice = %+v, want score 5, 3 games, 2 wins, streak 1", alice)
+	}
+}
+
+func TestRankTies(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.SaveUser(&models.User{ID: "alice", DisplayName: "Alice"})
+	store.SaveUser(&models.User{ID: "bob", DisplayName: "Bob"})
+	saveGame(store, "a1", "alice", true, 1, testNow)
+	saveGame(store, "b1", "bob", true, 1, testNow)
+
+	entries, err := NewService(store).Rank(Query{Metric: MetricScore, Now: testNow})
+	if err != nil {
+		t.Fatalf("Rank() failed: %v", err)
+	}
+
+	if entries[0].Rank != 1 || entries[1].Rank != 1 {
+		t.Errorf("ranks = %d, %d, want 1, 1", entries[0].Rank, entries[1].Rank)
+	}
+}
+
+func TestRankInvalidQuery(t *testing.T) {
+	service := NewService(storage.NewMemoryStore())
+
+	if _, err := service.Rank(Query{Metric: "fastest"}); err == nil {
+		t.Error("Rank() succeeded with unknown metric, want error")
+	}
+
+	if _, err := service.Rank(Query{Metric: MetricScore, Window: "decade"}); err == nil {
+		t.Error("Rank() succeeded with unknown window, want error")
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	week, _ := windowStart(WindowWeek, testNow)
+	if want := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC); !week.Equal(want) {
+		t.Errorf("week start = %v, want %v", week, want)
+	}
+
+	month, _ := windowStart(WindowMonth, testNow)
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !month.Equal(want) {
+		t.Errorf("month start = %v, want %v", month, want)
+	}
+}
+
+func TestRankByMode(t *testing.T) {
+	store := newTestStore()
+	store.SaveSession(&models.GameSession{ID: "artist1", UserID: "alice", Mode: models.ModeArtist, PlaylistIDs: []string{"p1"}, GuessesUsed: 1, IsComplete: true, Won: true, CompletedAt: testNow})
+
+	entries, err := NewService(store).Rank(Query{Metric: MetricScore, Mode: models.ModeArtist, Now: testNow})
+	if err != nil {
+		t.Fatalf("Rank() failed: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].DisplayName != "Alice" || entries[0].Games != 1 {
+		t.Errorf("entries = %+v, want only Alice's artist game", entries)
+	}
+}
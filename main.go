@@ -2,10 +2,18 @@
 package main
 
 import (
+	"expvar"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"spotify-heardle/audio"
 	"spotify-heardle/config"
+	"spotify-heardle/events"
 	"spotify-heardle/handlers"
+	"spotify-heardle/leaderboard"
+	"spotify-heardle/search"
+	"spotify-heardle/spotify"
 	"spotify-heardle/storage"
 )
 
@@ -15,43 +23,120 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	logger := newLogger(cfg)
+	slog.SetDefault(logger)
+
 	store := storage.NewMemoryStore()
+	broker := events.NewBroker()
+	indexes := search.NewIndexes(search.DefaultIndexTTL)
+	libraries := search.NewLibraries(search.DefaultLibraryMaxAge)
+	searchCache := spotify.NewSearchCache(spotify.DefaultSearchCacheTTL)
+	expvar.Publish("searchCache", expvar.Func(func() any { return searchCache.Stats() }))
 
 	authHandler := handlers.NewAuthHandler(cfg, store)
 	playlistHandler := handlers.NewPlaylistHandler(authHandler)
-	searchHandler := handlers.NewSearchHandler(authHandler)
-	gameHandler := handlers.NewGameHandler(authHandler, store)
+	searchHandler := handlers.NewSearchHandler(authHandler, store, indexes, libraries, searchCache)
+	gameHandler := handlers.NewGameHandler(authHandler, store, broker, indexes)
+	eventsHandler := handlers.NewEventsHandler(authHandler, store, broker)
+	historyHandler := handlers.NewHistoryHandler(authHandler, store)
+	shareHandler := handlers.NewShareHandler(authHandler, store, cfg.BaseURL)
+	poolHandler := handlers.NewPoolHandler(authHandler, store, cfg.BaseURL)
+	audioHandler := handlers.NewAudioHandler(authHandler, store, audio.NewPreviews(audio.DefaultPreviewTTL))
+	leaderboardHandler := handlers.NewLeaderboardHandler(authHandler, store, leaderboard.NewService(store))
 
-	mux := http.NewServeMux()
+	routes := []struct {
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{"GET /login", authHandler.HandleLogin},
+		{"GET /callback", authHandler.HandleCallback},
+		{"POST /api/logout", authHandler.HandleLogout},
+		{"GET /api/token", authHandler.HandleGetToken},
+		{"GET /api/playlists", playlistHandler.HandleGetPlaylists},
+		{"POST /api/playlists/resolve", playlistHandler.HandleResolvePlaylist},
+		{"GET /api/search", searchHandler.HandleSearch},
+		{"GET /api/pools", poolHandler.HandleListPools},
+		{"POST /api/pools", poolHandler.HandleCreatePool},
+		{"POST /api/pools/preview", poolHandler.HandlePreviewPool},
+		{"GET /api/pools/{id}", poolHandler.HandleGetPool},
+		{"PUT /api/pools/{id}", poolHandler.HandleUpdatePool},
+		{"DELETE /api/pools/{id}", poolHandler.HandleDeletePool},
+		{"POST /api/pools/{id}/share", poolHandler.HandleSharePool},
+		{"GET /api/pools/shared/{shareId}", poolHandler.HandleGetSharedPool},
+		{"POST /api/pools/shared/{shareId}/copy", poolHandler.HandleCopySharedPool},
+		{"POST /api/game/start", gameHandler.HandleStartGame},
+		{"POST /api/game/guess", gameHandler.HandleSubmitGuess},
+		{"POST /api/game/skip", gameHandler.HandleSkip},
+		{"GET /api/game/current", gameHandler.HandleGetCurrentGame},
+		{"GET /api/game/events", eventsHandler.HandleSessionEvents},
+		{"GET /api/game/{id}", gameHandler.HandleGetGame},
+		{"GET /api/game/{id}/events", eventsHandler.HandleSessionEvents},
+		{"GET /api/game/{id}/audio", audioHandler.HandleGetClip},
+		{"POST /api/game/{id}/share", shareHandler.HandleCreateShare},
+		{"GET /api/events", eventsHandler.HandleUserEvents},
+		{"GET /api/history", historyHandler.HandleGetHistory},
+		{"GET /api/leaderboard", leaderboardHandler.HandleGetLeaderboard},
+		{"POST /api/leaderboard/privacy", leaderboardHandler.HandleSetPrivacy},
+		{"GET /share/{shareId}", shareHandler.HandleSharePage},
+		{"GET /share/{shareId}/image.png", shareHandler.HandleShareImage},
+	}
 
-	mux.HandleFunc("/login", authHandler.HandleLogin)
-	mux.HandleFunc("/callback", authHandler.HandleCallback)
-	mux.HandleFunc("/api/logout", authHandler.HandleLogout)
-	mux.HandleFunc("/api/token", authHandler.HandleGetToken)
-	mux.HandleFunc("/api/playlists", playlistHandler.HandleGetPlaylists)
-	mux.HandleFunc("/api/search", searchHandler.HandleSearch)
-	mux.HandleFunc("/api/game/start", gameHandler.HandleStartGame)
-	mux.HandleFunc("/api/game/guess", gameHandler.HandleSubmitGuess)
-	mux.HandleFunc("/api/game/skip", gameHandler.HandleSkip)
+	router := handlers.NewRouter()
+	for _, route := range routes {
+		router.HandleFunc(route.pattern, route.handler)
+	}
+	// Game actions are POST only; GET would otherwise take them for game IDs.
+	for _, action := range []string{"start", "guess", "skip"} {
+		router.Fallback("GET /api/game/" + action)
+	}
 
 	fs := http.FileServer(http.Dir("./static"))
-	mux.Handle("/", fs)
+	router.Handle("/", fs)
 
-	corsHandler := corsMiddleware(mux)
-	loggedHandler := loggingMiddleware(corsHandler)
+	if cfg.DebugAddr != "" {
+		go serveDebug(logger, cfg.DebugAddr)
+	}
+
+	corsHandler := corsMiddleware(router)
+	loggedHandler := handlers.LogRequests(logger, corsHandler)
+	requestIDHandler := handlers.RequestID(loggedHandler)
 
 	addr := ":" + cfg.Port
-	log.Printf("Server starting on %s", addr)
-	if err := http.ListenAndServe(addr, loggedHandler); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	logger.Info("Server starting", "addr", addr)
+	if err := http.ListenAndServe(addr, requestIDHandler); err != nil {
+		logger.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newLogger creates the logger for the configured level and format, writing
+// to stderr.
+func newLogger(cfg *config.Config) *slog.Logger {
+	options := &slog.HandlerOptions{Level: cfg.LogLevel}
+	if cfg.LogFormat == config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(os.Stderr, options))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, options))
+}
+
+// serveDebug serves the expvar counters on their own listener at addr, kept
+// apart from the public server so they are only reachable where addr is.
+func serveDebug(logger *slog.Logger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	logger.Info("Debug server starting", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("Debug server failed", "error", err)
 	}
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -61,10 +146,3 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
-}
@@ -0,0 +1,105 @@
+// Package models defines data structures for the application.
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// maxFilterArtists caps the artists a filter can include or exclude.
+const maxFilterArtists = 50
+
+// TrackFilter narrows a game's pool of tracks. Zero fields do not filter,
+// except that local files and podcast episodes are left out unless
+// ExcludeLocalFiles or ExcludeEpisodes is set to false, since they cannot
+// usually be found by search.
+type TrackFilter struct {
+	// MinYear and MaxYear bound the release year. Tracks without a known
+	// release year are left out when either is set.
+	MinYear int `json:"minYear,omitempty"`
+	MaxYear int `json:"maxYear,omitempty"`
+	// IncludeArtists keeps only tracks by one of these artists, and
+	// ExcludeArtists leaves out tracks by any of them. Artists are given by
+	// Spotify ID or by name, which is matched ignoring case.
+	IncludeArtists  []string `json:"includeArtists,omitempty"`
+	ExcludeArtists  []string `json:"excludeArtists,omitempty"`
+	ExcludeExplicit bool     `json:"excludeExplicit,omitempty"`
+	// MinDurationMs and MaxDurationMs bound the track length.
+	MinDurationMs     int   `json:"minDurationMs,omitempty"`
+	MaxDurationMs     int   `json:"maxDurationMs,omitempty"`
+	ExcludeLocalFiles *bool `json:"excludeLocalFiles,omitempty"`
+	ExcludeEpisodes   *bool `json:"excludeEpisodes,omitempty"`
+}
+
+// Validate checks that the filter's bounds make sense.
+func (f TrackFilter) Validate() error {
+	switch {
+	case f.MinYear < 0 || f.MaxYear < 0:
+		return errors.New("years must not be negative")
+	case f.MaxYear > 0 && f.MinYear > f.MaxYear:
+		return errors.New("minYear must not be after maxYear")
+	case f.MinDurationMs < 0 || f.MaxDurationMs < 0:
+		return errors.New("durations must not be negative")
+	case f.MaxDurationMs > 0 && f.MinDurationMs > f.MaxDurationMs:
+		return errors.New("minDurationMs must not be more than maxDurationMs")
+	case len(f.IncludeArtists) > maxFilterArtists || len(f.ExcludeArtists) > maxFilterArtists:
+		return errors.New("too many artists in filter")
+	}
+	return nil
+}
+
+// Apply returns the tracks that pass the filter.
+func (f TrackFilter) Apply(tracks []Track) []Track {
+	filtered := make([]Track, 0, len(tracks))
+	for _, track := range tracks {
+		if f.Matches(track) {
+			filtered = append(filtered, track)
+		}
+	}
+	return filtered
+}
+
+// Matches reports whether the track passes the filter.
+func (f TrackFilter) Matches(track Track) bool {
+	if track.IsLocal && (f.ExcludeLocalFiles == nil || *f.ExcludeLocalFiles) {
+		return false
+	}
+	if track.IsEpisode && (f.ExcludeEpisodes == nil || *f.ExcludeEpisodes) {
+		return false
+	}
+	if f.ExcludeExplicit && track.Explicit {
+		return false
+	}
+
+	if f.MinYear > 0 || f.MaxYear > 0 {
+		year := track.ReleaseYear()
+		if year == 0 || year < f.MinYear || (f.MaxYear > 0 && year > f.MaxYear) {
+			return false
+		}
+	}
+
+	if f.MinDurationMs > 0 && track.DurationMs < f.MinDurationMs {
+		return false
+	}
+	if f.MaxDurationMs > 0 && track.DurationMs > f.MaxDurationMs {
+		return false
+	}
+
+	if len(f.IncludeArtists) > 0 && !byAnyArtist(track, f.IncludeArtists) {
+		return false
+	}
+	return !byAnyArtist(track, f.ExcludeArtists)
+}
+
+// byAnyArtist reports whether any of the track's artists is one of artists,
+// given by ID or name.
+func byAnyArtist(track Track, artists []string) bool {
+	for _, artist := range artists {
+		for i, name := range track.Artists {
+			if strings.EqualFold(name, artist) || (i < len(track.ArtistIDs) && track.ArtistIDs[i] == artist) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Package models defines data structures for the application.
+package models
+
+import "testing"
+
+func TestTrackFilterMatches(t *testing.T) {
+	no := false
+	track := Track{
+		ID:          "track1",
+		Name:        "Heroes",
+		Artists:     []string{"David Bowie", "Brian Eno"},
+		ArtistIDs:   []string{"bowie", "eno"},
+		ReleaseDate: "1977-10-14",
+		DurationMs:  371000,
+	}
+	explicit := track
+	explicit.Explicit = true
+	local := track
+	local.IsLocal = true
+	episode := track
+	episode.IsEpisode = true
+	undated := track
+	undated.ReleaseDate = ""
+
+	tests := []struct {
+		name   string
+		filter TrackFilter
+		track  Track
+		want   bool
+	}{
+		{"no filter", TrackFilter{}, track, true},
+		{"year in range", TrackFilter{MinYear: 1970, MaxYear: 1979}, track, true},
+		{"year before range", TrackFilter{MinYear: 1980}, track, false},
+		{"year after range", TrackFilter{MaxYear: 1976}, track, false},
+		{"unknown year with range", TrackFilter{MinYear: 1970}, undated, false},
+		{"unknown year without range", TrackFilter{}, undated, true},
+		{"included artist by name", TrackFilter{IncludeArtists: []string{"brian eno"}}, track, true},
+		{"included artist by ID", TrackFilter{IncludeArtists: []string{"bowie"}}, track, true},
+		{"other artists only", TrackFilter{IncludeArtists: []string{"Iggy Pop"}}, track, false},
+		{"excluded artist", TrackFilter{ExcludeArtists: []string{"Brian Eno"}}, track, false},
+		{"explicit excluded", TrackFilter{ExcludeExplicit: true}, explicit, false},
+		{"explicit allowed", TrackFilter{}, explicit, true},
+		{"too short", TrackFilter{MinDurationMs: 400000}, track, false},
+		{"too long", TrackFilter{MaxDurationMs: 300000}, track, false},
+		{"duration in range", TrackFilter{MinDurationMs: 60000, MaxDurationMs: 400000}, track, true},
+		{"local file by default", TrackFilter{}, local, false},
+		{"local file allowed", TrackFilter{ExcludeLocalFiles: &no}, local, true},
+		{"episode by default", TrackFilter{}, episode, false},
+		{"episode allowed", TrackFilter{ExcludeEpisodes: &no}, episode, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.track); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackFilterApply(t *testing.T) {
+	tracks := []Track{
+		{ID: "track1", ReleaseDate: "1977"},
+		{ID: "track2", ReleaseDate: "1985"},
+		{ID: "track3", ReleaseDate: "1979", IsLocal: true},
+	}
+
+	got := TrackFilter{MaxYear: 1980}.Apply(tracks)
+	if len(got) != 1 || got[0].ID != "track1" {
+		t.Errorf("Apply() = %+v, want track1", got)
+	}
+}
+
+func TestTrackFilterValidate(t *testing.T) {
+	many := make([]string, maxFilterArtists+1)
+
+	tests := []struct {
+		name    string
+		filter  TrackFilter
+		wantErr bool
+	}{
+		{"empty", TrackFilter{}, false},
+		{"valid ranges", TrackFilter{MinYear: 1970, MaxYear: 1979, MinDurationMs: 1, MaxDurationMs: 2}, false},
+		{"open year range", TrackFilter{MinYear: 1990}, false},
+		{"negative year", TrackFilter{MinYear: -1}, true},
+		{"reversed years", TrackFilter{MinYear: 1990, MaxYear: 1980}, true},
+		{"negative duration", TrackFilter{MaxDurationMs: -1}, true},
+		{"reversed durations", TrackFilter{MinDurationMs: 2, MaxDurationMs: 1}, true},
+		{"too many artists", TrackFilter{ExcludeArtists: many}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Package models defines data structures for the application.
+package models
+
+import "time"
+
+// MaxPoolNameLength is the longest name a saved pool can have, in runes.
+const MaxPoolNameLength = 100
+
+// Pool is a named set of game sources with the filters and rules to play
+// them by, saved so the player does not have to pick the same playlists
+// every time.
+type Pool struct {
+	ID          string
+	UserID      string
+	Name        string
+	PlaylistIDs []string
+	Mode        GameMode
+	Choices     int
+	PoolSearch  bool
+	Filters     TrackFilter
+	// ShareID is the public ID other players can copy the pool by. It is
+	// empty until the pool is first shared.
+	ShareID   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewPool creates a pool owned by userID.
+func NewPool(id, userID, name string, playlistIDs []string) *Pool {
+	now := time.Now()
+	return &Pool{
+		ID:          id,
+		UserID:      userID,
+		Name:        name,
+		PlaylistIDs: playlistIDs,
+		Mode:        ModeTrack,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
@@ -1,33 +1,247 @@
 // Package models defines data structures for the application.
 package models
 
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 const MaxGuesses = 3
 
+// Time limits for the timed modes.
+const (
+	TimedRoundDuration = 20 * time.Second
+	BlitzDuration      = 60 * time.Second
+)
+
+// GameMode determines what the player has to name to win a game.
+type GameMode string
+
+// Supported game modes.
+const (
+	ModeTrack  GameMode = "track"
+	ModeArtist GameMode = "artist"
+	ModeAlbum  GameMode = "album"
+	ModeYear   GameMode = "year"
+	ModeChoice GameMode = "choice"
+	ModeTimed  GameMode = "timed"
+	ModeBlitz  GameMode = "blitz"
+)
+
+// ParseGameMode validates a game mode, defaulting to ModeTrack when empty.
+func ParseGameMode(mode string) (GameMode, error) {
+	switch GameMode(mode) {
+	case "":
+		return ModeTrack, nil
+	case ModeTrack, ModeArtist, ModeAlbum, ModeYear, ModeChoice, ModeTimed, ModeBlitz:
+		return GameMode(mode), nil
+	}
+	return "", fmt.Errorf("unknown game mode: %s", mode)
+}
+
+// Playback is how the client plays a game's songs.
+type Playback string
+
+// Supported playback methods. The Web Playback SDK streams full tracks but
+// needs Spotify Premium; preview playback uses the 30 second preview clips
+// Spotify offers for most tracks, which any account can play.
+const (
+	PlaybackSDK     Playback = "sdk"
+	PlaybackPreview Playback = "preview"
+)
+
+// ParsePlayback validates a playback method. Empty is returned as is, for
+// the caller to pick the user's default.
+func ParsePlayback(playback string) (Playback, error) {
+	switch Playback(playback) {
+	case "", PlaybackSDK, PlaybackPreview:
+		return Playback(playback), nil
+	}
+	return "", fmt.Errorf("unknown playback: %s", playback)
+}
+
 // GameSession represents an active game session.
 type GameSession struct {
 	ID          string
 	UserID      string
+	Mode        GameMode
 	PlaylistIDs []string
 	CorrectSong Track
+	// Guesses holds every guess of the game, across all the songs of a
+	// blitz game, while GuessesUsed counts those on the current song.
 	Guesses     []Guess
 	GuessesUsed int
 	IsComplete  bool
 	Won         bool
+	StartedAt   time.Time
+	CompletedAt time.Time
+	ShareID     string
+	Choices     []Choice
+	// PoolSearch restricts guess search to the tracks of the game's playlists.
+	PoolSearch bool
+	// Playback is how the client plays the songs. Every song of a preview
+	// game has a preview URL.
+	Playback Playback
+
+	// Deadline ends the current round in timed mode and the whole game in
+	// blitz mode. It is zero for untimed games.
+	Deadline time.Time
+	// Queue holds the songs still to come in blitz mode, and Solved counts
+	// the songs guessed correctly so far.
+	Queue  []Track
+	Solved int
+}
+
+// Choice is one option offered in multiple-choice mode. Its ID is random so
+// the answer cannot be inferred from the options sent to the client.
+type Choice struct {
+	ID    string
+	Track Track
+}
+
+// Choice returns the option with the given ID.
+func (s *GameSession) Choice(id string) (Choice, bool) {
+	for _, choice := range s.Choices {
+		if choice.ID == id {
+			return choice, true
+		}
+	}
+	return Choice{}, false
 }
 
 // Track represents a Spotify track.
 type Track struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Artists    []string `json:"artists"`
-	PreviewURL string   `json:"previewUrl"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Artists     []string `json:"artists"`
+	ArtistIDs   []string `json:"artistIds,omitempty"`
+	AlbumID     string   `json:"albumId,omitempty"`
+	AlbumName   string   `json:"albumName,omitempty"`
+	ReleaseDate string   `json:"releaseDate,omitempty"`
+	ISRC        string   `json:"isrc,omitempty"`
+	PreviewURL  string   `json:"previewUrl"`
+	DurationMs  int      `json:"durationMs,omitempty"`
+	Explicit    bool     `json:"explicit,omitempty"`
+	// IsLocal marks a local file added to a playlist, whose ID is its
+	// spotify:local URI, and IsEpisode a podcast episode.
+	IsLocal   bool `json:"isLocal,omitempty"`
+	IsEpisode bool `json:"isEpisode,omitempty"`
+	// Unplayable marks a track that is not available in the user's market,
+	// and LinkedID is the ID of the release to play instead of ID there, if
+	// Spotify relinked the track.
+	Unplayable bool   `json:"unplayable,omitempty"`
+	LinkedID   string `json:"linkedId,omitempty"`
+}
+
+// ReleaseYear returns the year of the track's album release, or 0 if unknown.
+// Spotify release dates are "YYYY", "YYYY-MM" or "YYYY-MM-DD".
+func (t Track) ReleaseYear() int {
+	if len(t.ReleaseDate) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(t.ReleaseDate[:4])
+	if err != nil {
+		return 0
+	}
+	return year
 }
 
 // Guess represents a user's guess.
 type Guess struct {
-	TrackID   string
-	TrackName string
-	IsCorrect bool
+	ChoiceID   string
+	TrackID    string
+	TrackName  string
+	Artists    []string
+	ArtistID   string
+	ArtistName string
+	AlbumID    string
+	AlbumName  string
+	Year       int
+	Proximity  YearProximity
+	Direction  YearDirection
+	TimedOut   bool
+	IsCorrect  bool
+}
+
+// Label returns the name of whatever was guessed, for display.
+func (g Guess) Label() string {
+	switch {
+	case g.TimedOut:
+		return "Time's up"
+	case g.ArtistName != "":
+		return g.ArtistName
+	case g.AlbumName != "":
+		return g.AlbumName
+	case g.Year != 0:
+		return strconv.Itoa(g.Year)
+	}
+	return g.TrackName
+}
+
+// YearProximity classifies how close a year guess was to the release year.
+type YearProximity string
+
+// Year guess proximity tiers, from best to worst.
+const (
+	ProximityExact   YearProximity = "exact"
+	ProximityWithin2 YearProximity = "within2"
+	ProximityWithin5 YearProximity = "within5"
+	ProximityFar     YearProximity = "far"
+)
+
+// yearPoints is the score of each proximity tier in year mode.
+var yearPoints = map[YearProximity]int{
+	ProximityExact:   3,
+	ProximityWithin2: 2,
+	ProximityWithin5: 1,
+}
+
+// YearDirection tells the player which way the release year lies from a guess.
+type YearDirection string
+
+// Year guess directions.
+const (
+	DirectionHigher YearDirection = "higher"
+	DirectionLower  YearDirection = "lower"
+)
+
+// CompareYears scores a year guess against the actual release year.
+func CompareYears(guess, actual int) (YearProximity, YearDirection) {
+	distance := actual - guess
+	var direction YearDirection
+	switch {
+	case distance > 0:
+		direction = DirectionHigher
+	case distance < 0:
+		direction = DirectionLower
+		distance = -distance
+	}
+
+	switch {
+	case distance == 0:
+		return ProximityExact, direction
+	case distance <= 2:
+		return ProximityWithin2, direction
+	case distance <= 5:
+		return ProximityWithin5, direction
+	}
+	return ProximityFar, direction
+}
+
+// SharesArtist reports whether the guessed track has an artist in common with track.
+func (g Guess) SharesArtist(track Track) bool {
+	for _, guessed := range g.Artists {
+		for _, artist := range track.Artists {
+			if strings.EqualFold(guessed, artist) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // NewGameSession creates a new game session.
@@ -35,26 +249,132 @@ func NewGameSession(sessionID, userID string, playlistIDs []string, correctSong
 	return &GameSession{
 		ID:          sessionID,
 		UserID:      userID,
+		Mode:        ModeTrack,
 		PlaylistIDs: playlistIDs,
 		CorrectSong: correctSong,
 		Guesses:     []Guess{},
 		GuessesUsed: 0,
 		IsComplete:  false,
 		Won:         false,
+		StartedAt:   time.Now(),
 	}
 }
 
-// AddGuess adds a guess to the session and updates state.
-func (s *GameSession) AddGuess(guess Guess) {
+// Clone returns a copy of the session that can be changed without affecting
+// the original. Tracks and guesses are copied by value; the artist lists they
+// hold are never changed and stay shared.
+func (s *GameSession) Clone() *GameSession {
+	clone := *s
+	clone.PlaylistIDs = slices.Clone(s.PlaylistIDs)
+	clone.Guesses = slices.Clone(s.Guesses)
+	clone.Choices = slices.Clone(s.Choices)
+	clone.Queue = slices.Clone(s.Queue)
+	return &clone
+}
+
+// AddGuess adds a guess made at now to the session and updates state. In
+// blitz mode a song that was solved or ran out of guesses is replaced by the
+// next one in the queue rather than ending the game, and the guess stays in
+// Guesses.
+func (s *GameSession) AddGuess(guess Guess, now time.Time) {
 	s.Guesses = append(s.Guesses, guess)
 	s.GuessesUsed++
 
+	if s.Mode == ModeBlitz {
+		if guess.IsCorrect {
+			s.Solved++
+		}
+		if guess.IsCorrect || s.GuessesUsed >= MaxGuesses {
+			s.nextSong(now)
+		}
+		return
+	}
+
 	if guess.IsCorrect {
-		s.IsComplete = true
-		s.Won = true
+		s.MarkComplete(true, now)
 	} else if s.GuessesUsed >= MaxGuesses {
-		s.IsComplete = true
-		s.Won = false
+		s.MarkComplete(false, now)
+	}
+}
+
+// nextSong moves a blitz game on to the next queued song, completing the
+// game at now when the queue is empty.
+func (s *GameSession) nextSong(now time.Time) {
+	if len(s.Queue) == 0 {
+		s.MarkComplete(s.Solved > 0, now)
+		return
+	}
+	s.CorrectSong = s.Queue[0]
+	s.Queue = s.Queue[1:]
+	s.GuessesUsed = 0
+}
+
+// Skip gives up on the current song at now. A blitz game moves on to the
+// next song, and any other game ends as a loss.
+func (s *GameSession) Skip(now time.Time) {
+	if s.Mode == ModeBlitz {
+		s.nextSong(now)
+		return
+	}
+	s.MarkComplete(false, now)
+}
+
+// IsTimed reports whether the session is played against a deadline.
+func (s *GameSession) IsTimed() bool {
+	return s.Mode == ModeTimed || s.Mode == ModeBlitz
+}
+
+// StartClock starts the game at now, setting the first deadline in timed modes.
+func (s *GameSession) StartClock(now time.Time) {
+	s.StartedAt = now
+	switch s.Mode {
+	case ModeTimed:
+		s.Deadline = now.Add(TimedRoundDuration)
+	case ModeBlitz:
+		s.Deadline = now.Add(BlitzDuration)
+	}
+}
+
+// StartRound gives the player a fresh round deadline in timed mode. It does
+// nothing in other modes, since blitz has a single deadline for the game.
+func (s *GameSession) StartRound(now time.Time) {
+	if s.Mode == ModeTimed && !s.IsComplete {
+		s.Deadline = now.Add(TimedRoundDuration)
+	}
+}
+
+// Expired reports whether the deadline has passed at now.
+func (s *GameSession) Expired(now time.Time) bool {
+	return !s.IsComplete && !s.Deadline.IsZero() && !now.Before(s.Deadline)
+}
+
+// TimeRemaining returns the time left before the deadline, or zero once it
+// has passed.
+func (s *GameSession) TimeRemaining(now time.Time) time.Duration {
+	if s.IsComplete || s.Deadline.IsZero() || !now.Before(s.Deadline) {
+		return 0
+	}
+	return s.Deadline.Sub(now)
+}
+
+// Expire applies every deadline missed by now: in timed mode each missed
+// round counts as a wrong guess and the next round starts when the missed one
+// ended, however late the expiry is noticed, and in blitz mode the game ends.
+// A game that ends this way is completed at the deadline it missed rather
+// than at now.
+func (s *GameSession) Expire(now time.Time) {
+	switch s.Mode {
+	case ModeTimed:
+		for s.Expired(now) {
+			s.AddGuess(Guess{TimedOut: true}, s.Deadline)
+			if !s.IsComplete {
+				s.Deadline = s.Deadline.Add(TimedRoundDuration)
+			}
+		}
+	case ModeBlitz:
+		if s.Expired(now) {
+			s.MarkComplete(s.Solved > 0, s.Deadline)
+		}
 	}
 }
 
@@ -67,8 +387,47 @@ func (s *GameSession) GetAudioDuration() int {
 	return durations[s.GuessesUsed]
 }
 
-// MarkComplete marks the session as complete.
-func (s *GameSession) MarkComplete(won bool) {
+// PoolKey identifies the set of playlists a game was drawn from, independent
+// of the order they were selected in.
+func (s *GameSession) PoolKey() string {
+	return NewPoolKey(s.PlaylistIDs)
+}
+
+// NewPoolKey builds the key identifying a set of playlist IDs.
+func NewPoolKey(playlistIDs []string) string {
+	ids := append([]string(nil), playlistIDs...)
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+// Points returns the leaderboard score for a completed game: the fewer guesses
+// a win took, the more it is worth, and losses score nothing. In year mode a
+// game scores its closest guess instead, so near misses still count, and a
+// blitz game scores the number of songs solved.
+func (s *GameSession) Points() int {
+	if s.Mode == ModeBlitz {
+		return s.Solved
+	}
+
+	if s.Mode == ModeYear {
+		best := 0
+		for _, guess := range s.Guesses {
+			if points := yearPoints[guess.Proximity]; points > best {
+				best = points
+			}
+		}
+		return best
+	}
+
+	if !s.Won {
+		return 0
+	}
+	return MaxGuesses - s.GuessesUsed + 1
+}
+
+// MarkComplete marks the session as completed at now.
+func (s *GameSession) MarkComplete(won bool, now time.Time) {
 	s.IsComplete = true
 	s.Won = won
+	s.CompletedAt = now
 }
@@ -3,6 +3,7 @@ package models
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNewGameSession(t *testing.T) {
@@ -49,6 +50,14 @@ func TestNewGameSession(t *testing.T) {
 	if len(session.Guesses) != 0 {
 		t.Errorf("len(Guesses) = %d, want 0", len(session.Guesses))
 	}
+
+	if session.Mode != ModeTrack {
+		t.Errorf("Mode = %q, want %q", session.Mode, ModeTrack)
+	}
+
+	if session.StartedAt.IsZero() {
+		t.Error("StartedAt is zero, want start time")
+	}
 }
 
 func TestGameSessionAddGuess(t *testing.T) {
@@ -67,7 +76,7 @@ func TestGameSessionAddGuess(t *testing.T) {
 		IsCorrect: false,
 	}
 
-	session.AddGuess(guess)
+	session.AddGuess(guess, time.Now())
 
 	if session.GuessesUsed != 1 {
 		t.Errorf("GuessesUsed = %d, want 1", session.GuessesUsed)
@@ -99,7 +108,7 @@ func TestGameSessionAddCorrectGuess(t *testing.T) {
 		IsCorrect: true,
 	}
 
-	session.AddGuess(correctGuess)
+	session.AddGuess(correctGuess, time.Now())
 
 	if !session.IsComplete {
 		t.Error("IsComplete = false, want true after correct guess")
@@ -131,7 +140,7 @@ func TestGameSessionAddGuessReachesMax(t *testing.T) {
 		IsCorrect: false,
 	}
 
-	session.AddGuess(wrongGuess)
+	session.AddGuess(wrongGuess, time.Now())
 
 	if session.GuessesUsed != 3 {
 		t.Errorf("GuessesUsed = %d, want 3", session.GuessesUsed)
@@ -175,7 +184,8 @@ func TestGameSessionMarkComplete(t *testing.T) {
 		IsComplete: false,
 	}
 
-	session.MarkComplete(true)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	session.MarkComplete(true, now)
 
 	if !session.IsComplete {
 		t.Error("IsComplete = false, want true")
@@ -184,4 +194,290 @@ func TestGameSessionMarkComplete(t *testing.T) {
 	if !session.Won {
 		t.Error("Won = false, want true")
 	}
+
+	if !session.CompletedAt.Equal(now) {
+		t.Errorf("CompletedAt = %v, want %v", session.CompletedAt, now)
+	}
+}
+
+func TestGuessSharesArtist(t *testing.T) {
+	track := Track{ID: "track1", Artists: []string{"Artist A", "Artist B"}}
+
+	tests := []struct {
+		name    string
+		artists []string
+		want    bool
+	}{
+		{"same artist", []string{"Artist B"}, true},
+		{"different case", []string{"artist a"}, true},
+		{"different artist", []string{"Artist C"}, false},
+		{"no artists", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guess := Guess{TrackID: "other", Artists: tt.artists}
+			if got := guess.SharesArtist(track); got != tt.want {
+				t.Errorf("SharesArtist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGameSessionPoolKey(t *testing.T) {
+	a := &GameSession{PlaylistIDs: []string{"p2", "p1"}}
+	b := &GameSession{PlaylistIDs: []string{"p1", "p2"}}
+
+	if a.PoolKey() != b.PoolKey() {
+		t.Errorf("PoolKey() = %q and %q, want equal keys", a.PoolKey(), b.PoolKey())
+	}
+
+	if a.PlaylistIDs[0] != "p2" {
+		t.Error("PoolKey() reordered PlaylistIDs")
+	}
+}
+
+func TestGameSessionPoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		won         bool
+		guessesUsed int
+		want        int
+	}{
+		{"first guess", true, 1, 3},
+		{"last guess", true, 3, 1},
+		{"lost", false, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &GameSession{Won: tt.won, GuessesUsed: tt.guessesUsed}
+			if got := session.Points(); got != tt.want {
+				t.Errorf("Points() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGameMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    GameMode
+		wantErr bool
+	}{
+		{"", ModeTrack, false},
+		{"track", ModeTrack, false},
+		{"artist", ModeArtist, false},
+		{"album", ModeAlbum, false},
+		{"year", ModeYear, false},
+		{"choice", ModeChoice, false},
+		{"lyrics", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseGameMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGameMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseGameMode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuessLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		guess Guess
+		want  string
+	}{
+		{"track", Guess{TrackName: "Song"}, "Song"},
+		{"artist", Guess{ArtistID: "a1", ArtistName: "Artist"}, "Artist"},
+		{"album", Guess{AlbumID: "al1", AlbumName: "Album"}, "Album"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.guess.Label(); got != tt.want {
+				t.Errorf("Label() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackReleaseYear(t *testing.T) {
+	tests := []struct {
+		releaseDate string
+		want        int
+	}{
+		{"1999-04-01", 1999},
+		{"2003-07", 2003},
+		{"1967", 1967},
+		{"", 0},
+		{"n/a", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.releaseDate, func(t *testing.T) {
+			track := Track{ReleaseDate: tt.releaseDate}
+			if got := track.ReleaseYear(); got != tt.want {
+				t.Errorf("ReleaseYear() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareYears(t *testing.T) {
+	tests := []struct {
+		name          string
+		guess         int
+		actual        int
+		wantProximity YearProximity
+		wantDirection YearDirection
+	}{
+		{"exact", 1999, 1999, ProximityExact, ""},
+		{"close below", 1997, 1999, ProximityWithin2, DirectionHigher},
+		{"close above", 2001, 1999, ProximityWithin2, DirectionLower},
+		{"near", 1994, 1999, ProximityWithin5, DirectionHigher},
+		{"far", 1980, 1999, ProximityFar, DirectionHigher},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proximity, direction := CompareYears(tt.guess, tt.actual)
+			if proximity != tt.wantProximity || direction != tt.wantDirection {
+				t.Errorf("CompareYears(%d, %d) = %q, %q, want %q, %q", tt.guess, tt.actual, proximity, direction, tt.wantProximity, tt.wantDirection)
+			}
+		})
+	}
+}
+
+func TestGameSessionPointsYearMode(t *testing.T) {
+	session := &GameSession{
+		Mode: ModeYear,
+		Guesses: []Guess{
+			{Year: 1980, Proximity: ProximityFar},
+			{Year: 1996, Proximity: ProximityWithin5},
+			{Year: 1998, Proximity: ProximityWithin2},
+		},
+		GuessesUsed: 3,
+		IsComplete:  true,
+	}
+
+	if got := session.Points(); got != 2 {
+		t.Errorf("Points() = %d, want 2 for a guess within 2 years", got)
+	}
+}
+
+func TestGameSessionTimedRounds(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	session := NewGameSession("s1", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.Mode = ModeTimed
+	session.StartClock(start)
+
+	if got := session.TimeRemaining(start.Add(5 * time.Second)); got != TimedRoundDuration-5*time.Second {
+		t.Errorf("TimeRemaining() = %v, want %v", got, TimedRoundDuration-5*time.Second)
+	}
+
+	late := start.Add(TimedRoundDuration)
+	if !session.Expired(late) {
+		t.Fatal("Expired() = false at the deadline, want true")
+	}
+
+	session.Expire(late)
+
+	if session.GuessesUsed != 1 || !session.Guesses[0].TimedOut {
+		t.Errorf("guesses = %+v, want one timed-out guess", session.Guesses)
+	}
+	if session.Expired(late) || session.TimeRemaining(late) != TimedRoundDuration {
+		t.Errorf("deadline = %v, want a fresh round from %v", session.Deadline, late)
+	}
+}
+
+func TestGameSessionUntimed(t *testing.T) {
+	session := NewGameSession("s1", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.StartClock(time.Now())
+
+	if session.IsTimed() || session.Expired(time.Now().Add(time.Hour)) {
+		t.Error("untimed session reported as timed or expired")
+	}
+}
+
+func TestGameSessionBlitz(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	session := NewGameSession("s1", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.Mode = ModeBlitz
+	session.Queue = []Track{{ID: "t2"}, {ID: "t3"}}
+	session.StartClock(start)
+
+	session.AddGuess(Guess{IsCorrect: true}, start)
+	if session.Solved != 1 || session.CorrectSong.ID != "t2" || session.GuessesUsed != 0 {
+		t.Fatalf("after a correct guess: solved %d, song %s, guesses %d, want 1, t2, 0", session.Solved, session.CorrectSong.ID, session.GuessesUsed)
+	}
+
+	for i := 0; i < MaxGuesses; i++ {
+		session.AddGuess(Guess{}, start)
+	}
+	if session.CorrectSong.ID != "t3" || session.IsComplete {
+		t.Fatalf("after running out of guesses: song %s, complete %v, want t3 and in progress", session.CorrectSong.ID, session.IsComplete)
+	}
+	if len(session.Guesses) != 1+MaxGuesses || !session.Guesses[0].IsCorrect {
+		t.Errorf("guesses = %+v, want those of every song kept", session.Guesses)
+	}
+
+	session.Expire(start.Add(BlitzDuration + time.Minute))
+	if !session.IsComplete || !session.Won || session.Points() != 1 {
+		t.Errorf("after expiry: complete %v, won %v, points %d, want true, true, 1", session.IsComplete, session.Won, session.Points())
+	}
+	if want := start.Add(BlitzDuration); !session.CompletedAt.Equal(want) {
+		t.Errorf("CompletedAt = %v, want the deadline %v", session.CompletedAt, want)
+	}
+}
+
+func TestGameSessionTimedExpiresLate(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	session := NewGameSession("s1", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.Mode = ModeTimed
+	session.StartClock(start)
+
+	// Noticed 5 seconds late, the next round has already been running for 5.
+	late := start.Add(TimedRoundDuration + 5*time.Second)
+	session.Expire(late)
+	if session.GuessesUsed != 1 || session.TimeRemaining(late) != TimedRoundDuration-5*time.Second {
+		t.Errorf("guesses %d, remaining %v, want 1 and %v", session.GuessesUsed, session.TimeRemaining(late), TimedRoundDuration-5*time.Second)
+	}
+
+	// Noticed after every round has ended, each counts as a miss.
+	session.Expire(start.Add(time.Hour))
+	if session.GuessesUsed != MaxGuesses || !session.IsComplete || session.Won {
+		t.Errorf("guesses %d, complete %v, won %v, want %d, true, false", session.GuessesUsed, session.IsComplete, session.Won, MaxGuesses)
+	}
+	if want := start.Add(MaxGuesses * TimedRoundDuration); !session.CompletedAt.Equal(want) {
+		t.Errorf("CompletedAt = %v, want the last deadline %v", session.CompletedAt, want)
+	}
+}
+
+func TestGameSessionSkip(t *testing.T) {
+	session := NewGameSession("s1", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.Mode = ModeBlitz
+	session.Queue = []Track{{ID: "t2"}}
+	session.AddGuess(Guess{}, time.Now())
+
+	session.Skip(time.Now())
+	if session.IsComplete || session.CorrectSong.ID != "t2" || session.GuessesUsed != 0 || len(session.Guesses) != 1 {
+		t.Fatalf("after skipping in blitz: complete %v, song %s, guesses %d of %d, want the next song", session.IsComplete, session.CorrectSong.ID, session.GuessesUsed, len(session.Guesses))
+	}
+
+	session.Skip(time.Now())
+	if !session.IsComplete || session.Won {
+		t.Errorf("after skipping the last song: complete %v, won %v, want a lost game", session.IsComplete, session.Won)
+	}
+
+	session = NewGameSession("s2", "u1", []string{"p1"}, Track{ID: "t1"})
+	session.Skip(time.Now())
+	if !session.IsComplete || session.Won {
+		t.Errorf("after skipping a track game: complete %v, won %v, want a lost game", session.IsComplete, session.Won)
+	}
 }
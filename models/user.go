@@ -5,9 +5,15 @@ import "time"
 
 // User represents a Spotify user.
 type User struct {
-	ID          string
-	DisplayName string
-	Token       *Token
+	ID                string
+	DisplayName       string
+	Token             *Token
+	LeaderboardOptOut bool
+	// Country is the user's Spotify market, an ISO 3166-1 alpha-2 code.
+	Country string
+	// Product is the user's Spotify subscription, such as "premium" or
+	// "free".
+	Product string
 }
 
 // Token represents Spotify OAuth tokens.
@@ -22,6 +28,22 @@ func (t *Token) IsExpired() bool {
 	return time.Now().Add(1 * time.Minute).After(t.ExpiresAt)
 }
 
+// DefaultPlayback returns how the user's games are played unless they ask
+// otherwise: with the Web Playback SDK for Premium users, and with preview
+// clips for everyone else. Users whose subscription is not known keep the SDK.
+func (u *User) DefaultPlayback() Playback {
+	if u.CanStream() {
+		return PlaybackSDK
+	}
+	return PlaybackPreview
+}
+
+// CanStream reports whether the user may play full tracks with the Web
+// Playback SDK, which needs Spotify Premium.
+func (u *User) CanStream() bool {
+	return u.Product == "" || u.Product == "premium"
+}
+
 // NewUser creates a new User instance.
 func NewUser(id, displayName string, token *Token) *User {
 	return &User{
@@ -83,3 +83,22 @@ func TestNewUser(t *testing.T) {
 		t.Errorf("Token mismatch")
 	}
 }
+
+func TestUserDefaultPlayback(t *testing.T) {
+	tests := []struct {
+		product string
+		want    Playback
+	}{
+		{"premium", PlaybackSDK},
+		{"free", PlaybackPreview},
+		{"open", PlaybackPreview},
+		{"", PlaybackSDK},
+	}
+
+	for _, tt := range tests {
+		user := &User{Product: tt.product}
+		if got := user.DefaultPlayback(); got != tt.want {
+			t.Errorf("DefaultPlayback() for %q = %q, want %q", tt.product, got, tt.want)
+		}
+	}
+}
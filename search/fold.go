@@ -0,0 +1,48 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+// foldTable maps accented Latin letters to their unaccented spelling, so that
+// "beyonce" finds "Beyoncé" and "motley crue" finds "Mötley Crüe".
+var foldTable = buildFoldTable(map[string]string{
+	"a":  "àáâãäåāăąǎ",
+	"c":  "çćĉċč",
+	"d":  "ďđð",
+	"e":  "èéêëēĕėęě",
+	"g":  "ĝğġģ",
+	"h":  "ĥħ",
+	"i":  "ìíîïĩīĭįıǐ",
+	"j":  "ĵ",
+	"k":  "ķ",
+	"l":  "ĺļľŀł",
+	"n":  "ñńņňŉ",
+	"o":  "òóôõöøōŏőǒ",
+	"r":  "ŕŗř",
+	"s":  "śŝşšș",
+	"t":  "ţťŧț",
+	"u":  "ùúûüũūŭůűųǔ",
+	"w":  "ŵ",
+	"y":  "ýÿŷ",
+	"z":  "źżž",
+	"ae": "æ",
+	"oe": "œ",
+	"ss": "ß",
+	"th": "þ",
+})
+
+func buildFoldTable(letters map[string]string) map[rune]string {
+	table := make(map[rune]string)
+	for plain, accented := range letters {
+		for _, r := range accented {
+			table[r] = plain
+		}
+	}
+	return table
+}
+
+// fold appends the unaccented form of a lowercase rune to dst.
+func fold(dst []rune, r rune) []rune {
+	if plain, ok := foldTable[r]; ok {
+		return append(dst, []rune(plain)...)
+	}
+	return append(dst, r)
+}
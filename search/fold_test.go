@@ -0,0 +1,28 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import "testing"
+
+func TestNormalizeFoldsDiacritics(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Beyoncé", "beyonce"},
+		{"Mötley Crüe", "motley crue"},
+		{"Sigur Rós", "sigur ros"},
+		{"Straße", "strasse"},
+		{"Røyksopp", "royksopp"},
+		{"Łódź", "lodz"},
+		{"Café", "cafe"},
+		{"東京", "東京"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalize(tt.input); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
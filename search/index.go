@@ -0,0 +1,385 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"sort"
+	"spotify-heardle/models"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultLimit is the number of results returned when no limit is given.
+const DefaultLimit = 20
+
+// Index ranks a fixed set of tracks against free-text queries, matching words
+// of the title and artist names by exact word, prefix or close misspelling.
+//
+// Lookups only score tracks found through two posting maps: one from every
+// prefix of every word, and one from every trigram, which finds misspelt
+// words as long as they share three letters in a row with the query.
+type Index struct {
+	entries  []entry
+	prefixes map[string][]int
+	trigrams map[string][]int
+}
+
+type entry struct {
+	track       models.Track
+	title       string
+	titleWords  []string
+	artistWords []string
+}
+
+// NewIndex builds an index over tracks. Tracks appearing more than once are
+// indexed once.
+func NewIndex(tracks []models.Track) *Index {
+	idx := &Index{
+		entries:  make([]entry, 0, len(tracks)),
+		prefixes: make(map[string][]int),
+		trigrams: make(map[string][]int),
+	}
+
+	seen := make(map[string]bool, len(tracks))
+	for _, track := range tracks {
+		if seen[track.ID] {
+			continue
+		}
+		seen[track.ID] = true
+
+		title := normalize(track.Name)
+		e := entry{
+			track:       track,
+			title:       title,
+			titleWords:  strings.Fields(title),
+			artistWords: strings.Fields(normalize(strings.Join(track.Artists, " "))),
+		}
+
+		pos := len(idx.entries)
+		idx.entries = append(idx.entries, e)
+		for _, words := range [][]string{e.titleWords, e.artistWords} {
+			for _, word := range words {
+				runes := []rune(word)
+				for n := 1; n <= len(runes); n++ {
+					idx.prefixes[string(runes[:n])] = addPosting(idx.prefixes[string(runes[:n])], pos)
+				}
+				for _, gram := range trigrams(runes) {
+					idx.trigrams[gram] = addPosting(idx.trigrams[gram], pos)
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// addPosting appends pos unless it is already the last posting, which is
+// where a repeat from the same track would be since tracks are added in order.
+func addPosting(postings []int, pos int) []int {
+	if n := len(postings); n > 0 && postings[n-1] == pos {
+		return postings
+	}
+	return append(postings, pos)
+}
+
+func trigrams(word []rune) []string {
+	if len(word) < 3 {
+		return nil
+	}
+	grams := make([]string, 0, len(word)-2)
+	for i := 0; i+3 <= len(word); i++ {
+		grams = append(grams, string(word[i:i+3]))
+	}
+	return grams
+}
+
+// candidates returns the positions of tracks that could match every query
+// word, found through the posting maps.
+func (idx *Index) candidates(words []string) []int {
+	var result map[int]bool
+	for _, word := range words {
+		found := make(map[int]bool)
+		for _, pos := range idx.prefixes[word] {
+			found[pos] = true
+		}
+		runes := []rune(word)
+		if allowedEdits(len(runes)) > 0 {
+			for _, gram := range trigrams(runes) {
+				for _, pos := range idx.trigrams[gram] {
+					found[pos] = true
+				}
+			}
+		}
+
+		if result != nil {
+			for pos := range result {
+				if !found[pos] {
+					delete(result, pos)
+				}
+			}
+		} else {
+			result = found
+		}
+	}
+
+	positions := make([]int, 0, len(result))
+	for pos := range result {
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// Len returns the number of indexed tracks.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Track returns the indexed track with the given ID.
+func (idx *Index) Track(id string) (models.Track, bool) {
+	for _, e := range idx.entries {
+		if e.track.ID == id {
+			return e.track, true
+		}
+	}
+	return models.Track{}, false
+}
+
+// Search returns up to limit tracks matching every word of the query, best
+// match first. Title matches rank above artist matches, and whole-title
+// matches rank above everything else.
+func (idx *Index) Search(query string, limit int) []models.Track {
+	tracks, _ := idx.Lookup(query, limit)
+	return tracks
+}
+
+// Lookup is like Search, and also reports whether any result matched every
+// query word without a typo, which callers can take as a sign that the
+// player's track is among the results.
+func (idx *Index) Lookup(query string, limit int) ([]models.Track, bool) {
+	q := normalize(query)
+	words := strings.Fields(q)
+	if len(words) == 0 {
+		return []models.Track{}, false
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	type match struct {
+		entry *entry
+		pos   int
+		score int
+	}
+	matches := make([]match, 0)
+	confident := false
+	for _, pos := range idx.candidates(words) {
+		e := &idx.entries[pos]
+		score, exact := e.score(q, words)
+		if score > 0 {
+			matches = append(matches, match{entry: e, pos: pos, score: score})
+			confident = confident || exact
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.entry.title) != len(b.entry.title) {
+			return len(a.entry.title) < len(b.entry.title)
+		}
+		if a.entry.title != b.entry.title {
+			return a.entry.title < b.entry.title
+		}
+		return a.pos < b.pos
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]models.Track, len(matches))
+	for i, m := range matches {
+		results[i] = m.entry.track
+	}
+	return results, confident
+}
+
+// score rates how well the entry matches a normalized query, or returns 0 if
+// some query word matches neither the title nor the artists. exact reports
+// whether every word matched without a typo.
+func (e *entry) score(query string, words []string) (total int, exact bool) {
+	exact = true
+	for _, word := range words {
+		best, bestExact := 0, false
+		for _, w := range e.titleWords {
+			if s := wordScore(word, w); 2*s > best {
+				best, bestExact = 2*s, s > 1
+			}
+		}
+		for _, w := range e.artistWords {
+			if s := wordScore(word, w); s > best {
+				best, bestExact = s, s > 1
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		total += best
+		exact = exact && bestExact
+	}
+
+	switch {
+	case e.title == query:
+		total += 20
+	case strings.HasPrefix(e.title, query):
+		total += 10
+	}
+	return total, exact
+}
+
+// wordScore rates a query word against an indexed word: 3 for an exact
+// match, 2 for a prefix, 1 for a close misspelling of the word or of its
+// prefix, and 0 otherwise.
+func wordScore(query, word string) int {
+	switch {
+	case query == word:
+		return 3
+	case strings.HasPrefix(word, query):
+		return 2
+	}
+
+	q, w := []rune(query), []rune(word)
+	edits := allowedEdits(len(q))
+	if edits == 0 {
+		return 0
+	}
+	if levenshtein(q, w) <= edits {
+		return 1
+	}
+	if len(w) > len(q) && levenshtein(q, w[:len(q)]) <= edits {
+		return 1
+	}
+	return 0
+}
+
+// allowedEdits is the number of typos tolerated in a query word. Short words
+// must match exactly, since one edit turns them into too many other words.
+func allowedEdits(length int) int {
+	switch {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	}
+	return 0
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// normalize lowercases s, folds accented letters and replaces punctuation
+// with spaces, so "Don't Stop (Remastered)" and "dont stop remastered"
+// compare equal word by word. Combining marks are dropped, which folds text
+// that arrives decomposed as well.
+func normalize(s string) string {
+	out := make([]rune, 0, len(s))
+	space := true
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '\'' || r == '’' || unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			out = fold(out, r)
+			space = false
+		case !space:
+			out = append(out, ' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// DefaultIndexTTL is how long a game's pool index is kept after the game
+// last searched it. Games that end drop their index straight away; this
+// bounds how long an abandoned game's index is kept.
+const DefaultIndexTTL = time.Hour
+
+// Indexes holds the pool index of each game that restricts guess search to
+// its own tracks. bySession is keyed by session ID. An entry is removed by
+// Delete when its game ends, or, for games that are abandoned, swept out by
+// Put once it has gone unused for the TTL.
+type Indexes struct {
+	ttl       time.Duration
+	now       func() time.Time
+	bySession map[string]indexEntry
+	mu        sync.Mutex
+}
+
+type indexEntry struct {
+	index     *Index
+	expiresAt time.Time
+}
+
+// NewIndexes creates an empty set of pool indexes whose entries expire once
+// unused for ttl.
+func NewIndexes(ttl time.Duration) *Indexes {
+	return &Indexes{
+		ttl:       ttl,
+		now:       time.Now,
+		bySession: make(map[string]indexEntry),
+	}
+}
+
+// Put stores the index for a session, first sweeping out expired indexes.
+func (x *Indexes) Put(sessionID string, index *Index) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := x.now()
+	for id, entry := range x.bySession {
+		if !now.Before(entry.expiresAt) {
+			delete(x.bySession, id)
+		}
+	}
+	x.bySession[sessionID] = indexEntry{index: index, expiresAt: now.Add(x.ttl)}
+}
+
+// Get returns the index for a session, keeping it for another TTL.
+func (x *Indexes) Get(sessionID string) (*Index, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := x.now()
+	entry, ok := x.bySession[sessionID]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	entry.expiresAt = now.Add(x.ttl)
+	x.bySession[sessionID] = entry
+	return entry.index, true
+}
+
+// Delete drops the index for a session.
+func (x *Indexes) Delete(sessionID string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.bySession, sessionID)
+}
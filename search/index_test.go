@@ -0,0 +1,144 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"spotify-heardle/models"
+	"testing"
+	"time"
+)
+
+var testTracks = []models.Track{
+	{ID: "t1", Name: "Bohemian Rhapsody", Artists: []string{"Queen"}},
+	{ID: "t2", Name: "Under Pressure", Artists: []string{"Queen", "David Bowie"}},
+	{ID: "t3", Name: "Heroes", Artists: []string{"David Bowie"}},
+	{ID: "t4", Name: "Don't Stop Me Now", Artists: []string{"Queen"}},
+	{ID: "t5", Name: "Heroes (Live)", Artists: []string{"David Bowie"}},
+	{ID: "t6", Name: "Queen Bitch", Artists: []string{"David Bowie"}},
+	{ID: "t1", Name: "Bohemian Rhapsody", Artists: []string{"Queen"}},
+}
+
+func trackIDs(tracks []models.Track) []string {
+	ids := make([]string, len(tracks))
+	for i, track := range tracks {
+		ids[i] = track.ID
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"prefix", "bohem", []string{"t1"}},
+		{"exact title first", "heroes", []string{"t3", "t5"}},
+		{"title before artist", "queen", []string{"t6", "t2", "t4", "t1"}},
+		{"title and artist", "pressure bowie", []string{"t2"}},
+		{"punctuation", "dont stop", []string{"t4"}},
+		{"typo", "rhapsdoy", []string{"t1"}},
+		{"typo in prefix", "presure", []string{"t2"}},
+		{"short words exact", "hx", []string{}},
+		{"no match", "zeppelin", []string{}},
+		{"empty", "  ", []string{}},
+	}
+
+	index := NewIndex(testTracks)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trackIDs(index.Search(tt.query, 0))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestIndexDeduplicatesTracks(t *testing.T) {
+	if got := NewIndex(testTracks).Len(); got != 6 {
+		t.Errorf("Len() = %d, want 6", got)
+	}
+}
+
+func TestIndexSearchLimit(t *testing.T) {
+	if got := NewIndex(testTracks).Search("queen", 2); len(got) != 2 {
+		t.Errorf("got %d results, want 2", len(got))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := normalize("  Don't Stop (Remastered 2011) - Live!"); got != "dont stop remastered 2011 live" {
+		t.Errorf("normalize() = %q", got)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	indexes := NewIndexes(DefaultIndexTTL)
+	indexes.Put("s1", NewIndex(testTracks))
+
+	if _, ok := indexes.Get("s1"); !ok {
+		t.Fatal("Get() found no index after Put()")
+	}
+
+	indexes.Delete("s1")
+	if _, ok := indexes.Get("s1"); ok {
+		t.Error("Get() found an index after Delete()")
+	}
+}
+
+func TestIndexesExpire(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	indexes := NewIndexes(time.Hour)
+	indexes.now = func() time.Time { return now }
+	indexes.Put("abandoned", NewIndex(testTracks))
+	indexes.Put("active", NewIndex(testTracks))
+
+	// Searching a game keeps its index for another hour.
+	now = now.Add(45 * time.Minute)
+	if _, ok := indexes.Get("active"); !ok {
+		t.Fatal("Get() found no index before the TTL")
+	}
+
+	now = now.Add(30 * time.Minute)
+	if _, ok := indexes.Get("abandoned"); ok {
+		t.Error("Get() found an index unused for longer than the TTL")
+	}
+
+	indexes.Put("new", NewIndex(testTracks))
+	if _, ok := indexes.bySession["abandoned"]; ok {
+		t.Error("Put() did not sweep out the expired index")
+	}
+	if _, ok := indexes.Get("active"); !ok {
+		t.Error("Put() swept out an index that was still in use")
+	}
+}
+
+func TestIndexLookupConfidence(t *testing.T) {
+	index := NewIndex(testTracks)
+
+	if _, confident := index.Lookup("bohemian rhap", 0); !confident {
+		t.Error("Lookup() of an exact prefix is not confident")
+	}
+
+	tracks, confident := index.Lookup("rhapsdoy", 0)
+	if len(tracks) != 1 || confident {
+		t.Errorf("Lookup() of a typo = %d results, confident %v, want 1 result and not confident", len(tracks), confident)
+	}
+}
+
+func TestIndexSearchDiacritics(t *testing.T) {
+	index := NewIndex([]models.Track{{ID: "t1", Name: "Hoppípolla", Artists: []string{"Sigur Rós"}}})
+
+	for _, query := range []string{"hoppipolla", "sigur ros", "Sigur Rós"} {
+		if got := index.Search(query, 0); len(got) != 1 {
+			t.Errorf("Search(%q) = %v, want t1", query, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"log/slog"
+	"spotify-heardle/models"
+	"sync"
+	"time"
+)
+
+// DefaultLibraryMaxAge is how long a user's library index is used before it
+// is rebuilt to pick up changes to their playlists.
+const DefaultLibraryMaxAge = time.Hour
+
+// libraryRetryDelay is how long to wait before trying again after a library
+// failed to load.
+const libraryRetryDelay = time.Minute
+
+// Loader fetches the tracks to index for a user.
+type Loader func() ([]models.Track, error)
+
+// Libraries holds an index per user over the tracks of their playlists and
+// liked songs. Indexes are built in the background, since loading a whole
+// library takes many Spotify requests.
+type Libraries struct {
+	maxAge    time.Duration
+	libraries map[string]*library
+	mu        sync.Mutex
+}
+
+type library struct {
+	index       *Index
+	attemptedAt time.Time
+	building    bool
+}
+
+// stale reports whether the library should be rebuilt.
+func (lib *library) stale(maxAge time.Duration) bool {
+	if lib.building {
+		return false
+	}
+	if lib.index == nil {
+		return lib.attemptedAt.IsZero() || time.Since(lib.attemptedAt) > libraryRetryDelay
+	}
+	return time.Since(lib.attemptedAt) > maxAge
+}
+
+// NewLibraries creates an empty set of user libraries rebuilt after maxAge.
+func NewLibraries(maxAge time.Duration) *Libraries {
+	return &Libraries{
+		maxAge:    maxAge,
+		libraries: make(map[string]*library),
+	}
+}
+
+// Get returns the user's library index, or false if it has not been built
+// yet. A missing or stale index is rebuilt in the background with load; a
+// stale index is still returned until the new one is ready.
+func (l *Libraries) Get(userID string, load Loader) (*Index, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lib := l.libraries[userID]
+	if lib == nil {
+		lib = &library{}
+		l.libraries[userID] = lib
+	}
+
+	if lib.stale(l.maxAge) {
+		lib.building = true
+		go func() {
+			if err := l.Build(userID, load); err != nil {
+				slog.Warn("Failed to build search library", "user_id", userID, "error", err)
+			}
+		}()
+	}
+
+	return lib.index, lib.index != nil
+}
+
+// Build loads and indexes the user's library, replacing any previous index.
+// If loading fails, the previous index is kept.
+func (l *Libraries) Build(userID string, load Loader) error {
+	tracks, err := load()
+	var index *Index
+	if err == nil {
+		index = NewIndex(tracks)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lib := l.libraries[userID]
+	if lib == nil {
+		lib = &library{}
+		l.libraries[userID] = lib
+	}
+	lib.building = false
+	lib.attemptedAt = time.Now()
+
+	if err != nil {
+		return err
+	}
+	lib.index = index
+	return nil
+}
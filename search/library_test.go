@@ -0,0 +1,64 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"errors"
+	"spotify-heardle/models"
+	"testing"
+	"time"
+)
+
+func TestLibrariesBuildInBackground(t *testing.T) {
+	libraries := NewLibraries(DefaultLibraryMaxAge)
+	loaded := make(chan struct{})
+	load := func() ([]models.Track, error) {
+		defer close(loaded)
+		return []models.Track{{ID: "t1", Name: "Song"}}, nil
+	}
+
+	if _, ok := libraries.Get("user1", load); ok {
+		t.Fatal("Get() returned an index before it was built")
+	}
+
+	<-loaded
+	deadline := time.Now().Add(time.Second)
+	for {
+		index, ok := libraries.Get("user1", load)
+		if ok {
+			if index.Len() != 1 {
+				t.Errorf("Len() = %d, want 1", index.Len())
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("library was not built")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLibrariesBuildKeepsIndexOnError(t *testing.T) {
+	libraries := NewLibraries(DefaultLibraryMaxAge)
+
+	err := libraries.Build("user1", func() ([]models.Track, error) {
+		return []models.Track{{ID: "t1", Name: "Song"}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	err = libraries.Build("user1", func() ([]models.Track, error) {
+		return nil, errors.New("spotify unavailable")
+	})
+	if err == nil {
+		t.Fatal("Build() succeeded, want error")
+	}
+
+	index, ok := libraries.Get("user1", func() ([]models.Track, error) {
+		t.Error("Get() rebuilt a fresh library")
+		return nil, nil
+	})
+	if !ok || index.Len() != 1 {
+		t.Error("previous index was not kept after a failed build")
+	}
+}
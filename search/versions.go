@@ -0,0 +1,133 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"regexp"
+	"spotify-heardle/models"
+	"strings"
+)
+
+// Group is one song in a list of search results: the best-ranked version of
+// it, with any other versions attached as alternates.
+type Group struct {
+	models.Track
+	Alternates []models.Track `json:"alternates,omitempty"`
+}
+
+// GroupVersions collapses versions of the same song, such as the album,
+// single, deluxe, remastered and live releases, keeping the order in which
+// each song first appears. Tracks are the same song if they share an ISRC or
+// have the same base title and primary artist.
+func GroupVersions(tracks []models.Track) []Group {
+	groups := make([]Group, 0, len(tracks))
+	byKey := make(map[string]int)
+
+	for _, track := range tracks {
+		keys := versionKeys(track)
+
+		pos, found := -1, false
+		for _, key := range keys {
+			if pos, found = byKey[key]; found {
+				break
+			}
+		}
+
+		if !found {
+			pos = len(groups)
+			groups = append(groups, Group{Track: track})
+		} else if track.ID != groups[pos].ID {
+			groups[pos].Alternates = append(groups[pos].Alternates, track)
+		}
+
+		for _, key := range keys {
+			if _, taken := byKey[key]; !taken {
+				byKey[key] = pos
+			}
+		}
+	}
+	return groups
+}
+
+// SameRecording reports whether two tracks are releases of the same
+// recording: they have the same ID, one is relinked to the other, or they
+// share an ISRC. Unlike GroupVersions it does not compare titles, which
+// cannot tell every pair of different songs apart, so it is the one to judge
+// guesses by.
+func SameRecording(a, b models.Track) bool {
+	if a.ID != "" && (a.ID == b.ID || a.ID == b.LinkedID) {
+		return true
+	}
+	if b.ID != "" && b.ID == a.LinkedID {
+		return true
+	}
+	return a.ISRC != "" && a.ISRC == b.ISRC
+}
+
+// SongKey identifies a song independent of its release: the normalized title
+// without version details, and the primary artist.
+func SongKey(track models.Track) string {
+	title := normalize(baseTitle(track.Name))
+	if title == "" {
+		return ""
+	}
+
+	artist := ""
+	if len(track.Artists) > 0 {
+		artist = normalize(track.Artists[0])
+	}
+	return title + "\x00" + artist
+}
+
+func versionKeys(track models.Track) []string {
+	keys := make([]string, 0, 2)
+	if track.ISRC != "" {
+		keys = append(keys, "isrc:"+track.ISRC)
+	}
+	if key := SongKey(track); key != "" {
+		keys = append(keys, "song:"+key)
+	}
+	return keys
+}
+
+// songQualifier matches the details in a track name that tell songs apart
+// rather than releases of a song, as in "(Part 2)", "- Reprise" or "No. 5".
+var songQualifier = regexp.MustCompile(`(?i)\b(?:part|pt|reprise|movement|mvt)\b|\bno\.\s*\d`)
+
+// baseTitle strips the version details Spotify appends to track names, as in
+// "Heroes (Live)", "Song [Deluxe Edition]" or "Bohemian Rhapsody - Remastered
+// 2011". Details matching songQualifier are kept.
+func baseTitle(name string) string {
+	if i := strings.Index(name, " - "); i > 0 && !songQualifier.MatchString(name[i:]) {
+		name = name[:i]
+	}
+
+	var b, bracket strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+			bracket.WriteRune(r)
+		case (r == ')' || r == ']') && depth > 0:
+			depth--
+			bracket.WriteRune(r)
+			if depth == 0 {
+				if songQualifier.MatchString(bracket.String()) {
+					b.WriteString(bracket.String())
+				}
+				bracket.Reset()
+			}
+		case r == ')' || r == ']':
+		case depth > 0:
+			bracket.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	// Keep titles that are nothing but brackets, such as "(Untitled)".
+	if strings.TrimSpace(b.String()) == "" {
+		return name
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+// Package search provides in-memory track search over a known set of tracks.
+package search
+
+import (
+	"spotify-heardle/models"
+	"testing"
+)
+
+func TestGroupVersions(t *testing.T) {
+	tracks := []models.Track{
+		{ID: "t1", Name: "Bohemian Rhapsody - Remastered 2011", Artists: []string{"Queen"}, ISRC: "GBUM71029604"},
+		{ID: "t2", Name: "Killer Queen", Artists: []string{"Queen"}},
+		{ID: "t3", Name: "Bohemian Rhapsody", Artists: []string{"Queen"}, ISRC: "GBUM71029604"},
+		{ID: "t4", Name: "Bohemian Rhapsody (Live Aid)", Artists: []string{"Queen"}},
+		{ID: "t5", Name: "Bohemian Rhapsody", Artists: []string{"Panic! At The Disco"}},
+		{ID: "t6", Name: "Mama (Single Version)", Artists: []string{"Genesis"}, ISRC: "GBAAA8300001"},
+		{ID: "t7", Name: "Mama", Artists: []string{"Other Artist"}, ISRC: "GBAAA8300001"},
+		{ID: "t1", Name: "Bohemian Rhapsody - Remastered 2011", Artists: []string{"Queen"}, ISRC: "GBUM71029604"},
+		{ID: "t8", Name: "Another Brick in the Wall, Pt. 1", Artists: []string{"Pink Floyd"}},
+		{ID: "t9", Name: "Another Brick in the Wall, Pt. 2", Artists: []string{"Pink Floyd"}},
+		{ID: "t10", Name: "Another Brick in the Wall, Pt. 2 - 2011 Remaster", Artists: []string{"Pink Floyd"}},
+	}
+
+	groups := GroupVersions(tracks)
+
+	want := []struct {
+		id         string
+		alternates []string
+	}{
+		{"t1", []string{"t3", "t4"}},
+		{"t2", nil},
+		{"t5", nil},
+		{"t6", []string{"t7"}},
+		{"t8", nil},
+		{"t9", []string{"t10"}},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, w := range want {
+		if groups[i].ID != w.id {
+			t.Errorf("group %d = %s, want %s", i, groups[i].ID, w.id)
+		}
+		got := trackIDs(groups[i].Alternates)
+		if len(got) != len(w.alternates) {
+			t.Errorf("group %s alternates = %v, want %v", w.id, got, w.alternates)
+			continue
+		}
+		for j := range got {
+			if got[j] != w.alternates[j] {
+				t.Errorf("group %s alternates = %v, want %v", w.id, got, w.alternates)
+				break
+			}
+		}
+	}
+}
+
+func TestSameRecording(t *testing.T) {
+	heroes := models.Track{ID: "t1", LinkedID: "t9", Name: "Heroes", Artists: []string{"David Bowie"}, ISRC: "GBAYE7700001"}
+
+	tests := []struct {
+		name  string
+		other models.Track
+		want  bool
+	}{
+		{"same id", models.Track{ID: "t1"}, true},
+		{"relinked release", models.Track{ID: "t9"}, true},
+		{"relinked to the answer", models.Track{ID: "t8", LinkedID: "t1"}, true},
+		{"same isrc", models.Track{ID: "t2", Name: "\"Heroes\" - 2017 Remaster", Artists: []string{"David Bowie"}, ISRC: "GBAYE7700001"}, true},
+		{"same title", models.Track{ID: "t3", Name: "Heroes [Live]", Artists: []string{"David Bowie", "Someone"}}, false},
+		{"cover", models.Track{ID: "t4", Name: "Heroes", Artists: []string{"Peter Gabriel"}}, false},
+		{"other song", models.Track{ID: "t5", Name: "Ashes to Ashes", Artists: []string{"David Bowie"}}, false},
+		{"empty", models.Track{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SameRecording(tt.other, heroes); got != tt.want {
+				t.Errorf("SameRecording() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseTitle(t *testing.T) {
+	tests := map[string]string{
+		"Heroes (Live)":                                   "Heroes ",
+		"Song [Deluxe Edition] (Remastered)":              "Song  ",
+		"Bohemian Rhapsody - Remastered 2011":             "Bohemian Rhapsody",
+		"(Untitled)":                                      "(Untitled)",
+		"Another Brick in the Wall (Part 2)":              "Another Brick in the Wall (Part 2)",
+		"Sgt. Pepper's Lonely Hearts Club Band - Reprise": "Sgt. Pepper's Lonely Hearts Club Band - Reprise",
+		"Symphony No. 5 (Live)":                           "Symphony No. 5 ",
+		"Plain":                                           "Plain",
+	}
+
+	for input, want := range tests {
+		if got := baseTitle(input); got != want {
+			t.Errorf("baseTitle(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -3,9 +3,9 @@ package spotify
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"spotify-heardle/models"
@@ -50,6 +50,8 @@ func (a *AuthManager) GetAuthURL(state string) string {
 		"user-read-email",
 		"user-modify-playback-state",
 		"user-read-playback-state",
+		"user-top-read",
+		"user-read-recently-played",
 	}
 
 	params := url.Values{}
@@ -63,7 +65,7 @@ func (a *AuthManager) GetAuthURL(state string) string {
 }
 
 // ExchangeCodeForToken exchanges authorization code for access token.
-func (a *AuthManager) ExchangeCodeForToken(code string) (*models.Token, error) {
+func (a *AuthManager) ExchangeCodeForToken(ctx context.Context, code string) (*models.Token, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
@@ -71,21 +73,20 @@ func (a *AuthManager) ExchangeCodeForToken(code string) (*models.Token, error) {
 	data.Set("client_id", a.clientID)
 	data.Set("client_secret", a.clientSecret)
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doLogged(req)
 	if err != nil {
 		return nil, fmt.Errorf("token request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("token request failed: %w", newAPIError(resp))
 	}
 
 	var tokenResp tokenResponse
@@ -97,28 +98,27 @@ func (a *AuthManager) ExchangeCodeForToken(code string) (*models.Token, error) {
 }
 
 // RefreshToken refreshes an expired access token.
-func (a *AuthManager) RefreshToken(refreshToken string, existingToken *models.Token) (*models.Token, error) {
+func (a *AuthManager) RefreshToken(ctx context.Context, refreshToken string, existingToken *models.Token) (*models.Token, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", refreshToken)
 	data.Set("client_id", a.clientID)
 	data.Set("client_secret", a.clientSecret)
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doLogged(req)
 	if err != nil {
 		return nil, fmt.Errorf("refresh request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("refresh request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("refresh request failed: %w", newAPIError(resp))
 	}
 
 	var tokenResp tokenResponse
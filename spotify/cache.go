@@ -0,0 +1,165 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"spotify-heardle/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSearchCacheTTL is how long track search results are shared.
+const DefaultSearchCacheTTL = 2 * time.Minute
+
+// maxSearchCacheEntries is the cache size above which expired entries are
+// swept out when a new entry is added.
+const maxSearchCacheEntries = 1000
+
+// SearchCache shares track search results between clients for a short time,
+// keyed by normalized query and market, and turns concurrent identical
+// searches into a single Spotify request. It is safe for concurrent use.
+type SearchCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]searchCacheEntry
+	calls   map[string]*searchCall
+	mu      sync.Mutex
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	coalesced atomic.Int64
+}
+
+type searchCacheEntry struct {
+	tracks    []models.Track
+	expiresAt time.Time
+}
+
+// searchCall is a Spotify search in flight that other callers can wait for.
+// ok is set, before done is closed, if the search succeeded.
+type searchCall struct {
+	done   chan struct{}
+	tracks []models.Track
+	ok     bool
+}
+
+// SearchCacheStats counts how searches were answered. Coalesced searches
+// waited for an identical search in flight and are also counted as misses.
+type SearchCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Coalesced int64 `json:"coalesced"`
+	Entries   int   `json:"entries"`
+}
+
+// NewSearchCache creates a search cache whose results expire after ttl.
+func NewSearchCache(ttl time.Duration) *SearchCache {
+	return &SearchCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]searchCacheEntry),
+		calls:   make(map[string]*searchCall),
+	}
+}
+
+// Stats returns the cache counters.
+func (c *SearchCache) Stats() SearchCacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return SearchCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+		Entries:   entries,
+	}
+}
+
+// get returns the cached results for key, or calls fetch to load them. While
+// a fetch is in flight, other callers for the same key wait for its result.
+// Only results are shared: errors are not cached, and if the fetch fails or
+// panics, each waiting caller fetches with its own fetch instead, since the
+// error may be down to the first caller's token.
+func (c *SearchCache) get(key string, fetch func() ([]models.Track, error)) ([]models.Track, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && c.now().Before(entry.expiresAt) {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return copyTracks(entry.tracks), nil
+	}
+
+	c.misses.Add(1)
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		c.coalesced.Add(1)
+		<-call.done
+		if call.ok {
+			return copyTracks(call.tracks), nil
+		}
+		return c.fetch(key, fetch)
+	}
+
+	call := &searchCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.ok {
+			c.store(key, call.tracks)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	tracks, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	call.tracks, call.ok = tracks, true
+	return copyTracks(tracks), nil
+}
+
+// fetch loads the results for key without waiting on other callers, and
+// caches them if the fetch succeeds.
+func (c *SearchCache) fetch(key string, fetch func() ([]models.Track, error)) ([]models.Track, error) {
+	tracks, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.store(key, tracks)
+	c.mu.Unlock()
+	return copyTracks(tracks), nil
+}
+
+// store adds an entry, first sweeping out expired entries if the cache has
+// grown large. The caller must hold c.mu.
+func (c *SearchCache) store(key string, tracks []models.Track) {
+	now := c.now()
+	if len(c.entries) >= maxSearchCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = searchCacheEntry{tracks: tracks, expiresAt: now.Add(c.ttl)}
+}
+
+// searchCacheKey identifies a search independent of letter case and spacing.
+func searchCacheKey(query, market string) string {
+	return market + "\x00" + strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// copyTracks returns a copy of tracks so callers cannot modify cached results.
+func copyTracks(tracks []models.Track) []models.Track {
+	if tracks == nil {
+		return nil
+	}
+	return append([]models.Track(nil), tracks...)
+}
@@ -0,0 +1,215 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"errors"
+	"spotify-heardle/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearchCacheHitAndExpiry(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() ([]models.Track, error) {
+		fetches++
+		return []models.Track{{ID: "track1"}}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		tracks, err := cache.get(searchCacheKey("Queen", "SE"), fetch)
+		if err != nil || len(tracks) != 1 {
+			t.Fatalf("get() = %v, %v, want one track", tracks, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetches = %d, want 1 before expiry", fetches)
+	}
+
+	now = now.Add(time.Minute)
+	cache.get(searchCacheKey("Queen", "SE"), fetch)
+	if fetches != 2 {
+		t.Errorf("fetches = %d, want 2 after expiry", fetches)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("stats = %+v, want 1 hit, 2 misses, 1 entry", stats)
+	}
+}
+
+func TestSearchCacheDoesNotCacheErrors(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+
+	fetches := 0
+	fetch := func() ([]models.Track, error) {
+		fetches++
+		return nil, errors.New("rate limited")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get("key", fetch); err == nil {
+			t.Fatal("get() succeeded, want error")
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("fetches = %d, want 2", fetches)
+	}
+}
+
+func TestSearchCacheCoalescesConcurrentSearches(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+	release := make(chan struct{})
+
+	fetches := 0
+	fetch := func() ([]models.Track, error) {
+		fetches++
+		<-release
+		return []models.Track{{ID: "track1"}}, nil
+	}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make(chan int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracks, _ := cache.get("key", fetch)
+			results <- len(tracks)
+		}()
+	}
+
+	// Wait for every caller to be either fetching or waiting on the fetch.
+	for cache.misses.Load() < callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+	for n := range results {
+		if n != 1 {
+			t.Errorf("caller got %d tracks, want 1", n)
+		}
+	}
+	if got := cache.Stats().Coalesced; got != callers-1 {
+		t.Errorf("coalesced = %d, want %d", got, callers-1)
+	}
+}
+
+func TestSearchCacheWaitersRetryFailedSearch(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+	release := make(chan struct{})
+
+	// The first search fails, say with its caller's expired token.
+	failing := func() ([]models.Track, error) {
+		<-release
+		return nil, errors.New("token expired")
+	}
+	var own sync.WaitGroup
+	own.Add(1)
+	go func() {
+		defer own.Done()
+		if _, err := cache.get("key", failing); err == nil {
+			t.Error("failed search returned no error to its caller")
+		}
+	}()
+	for cache.misses.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var waited sync.WaitGroup
+	waited.Add(1)
+	go func() {
+		defer waited.Done()
+		tracks, err := cache.get("key", func() ([]models.Track, error) {
+			return []models.Track{{ID: "track1"}}, nil
+		})
+		if err != nil || len(tracks) != 1 {
+			t.Errorf("waiting caller got %v, %v, want its own results", tracks, err)
+		}
+	}()
+	for cache.coalesced.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	own.Wait()
+	waited.Wait()
+
+	if stats := cache.Stats(); stats.Entries != 1 {
+		t.Errorf("entries = %d, want the retried results cached", stats.Entries)
+	}
+}
+
+func TestSearchCacheFetchPanics(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		cache.get("key", func() ([]models.Track, error) {
+			<-release
+			panic("fetch failed")
+		})
+	}()
+	for cache.misses.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.get("key", func() ([]models.Track, error) {
+			return []models.Track{{ID: "track1"}}, nil
+		})
+	}()
+	for cache.coalesced.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waiting caller hung after the fetch panicked")
+	}
+
+	cache.mu.Lock()
+	calls := len(cache.calls)
+	cache.mu.Unlock()
+	if calls != 0 {
+		t.Errorf("calls in flight = %d, want 0", calls)
+	}
+}
+
+func TestSearchCacheKey(t *testing.T) {
+	if searchCacheKey("  Bohemian   RHAPSODY ", "GB") != searchCacheKey("bohemian rhapsody", "GB") {
+		t.Error("keys differ for queries differing only in case and spacing")
+	}
+	if searchCacheKey("queen", "GB") == searchCacheKey("queen", "US") {
+		t.Error("keys equal for different markets")
+	}
+}
+
+func TestSearchCacheReturnsCopies(t *testing.T) {
+	cache := NewSearchCache(time.Minute)
+	fetch := func() ([]models.Track, error) {
+		return []models.Track{{ID: "track1"}}, nil
+	}
+
+	tracks, _ := cache.get("key", fetch)
+	tracks[0].ID = "changed"
+
+	if tracks, _ := cache.get("key", fetch); tracks[0].ID != "track1" {
+		t.Errorf("cached track ID = %q, want track1", tracks[0].ID)
+	}
+}
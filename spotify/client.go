@@ -2,27 +2,46 @@
 package spotify
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"spotify-heardle/models"
+	"strings"
 )
 
 const apiBaseURL = "https://api.spotify.com/v1"
 
+// SearchLimit is the number of results requested per search, Spotify's
+// maximum. Callers page through them, since versions of the same song are
+// grouped before paging.
+const SearchLimit = 50
+
 // Client is a Spotify API client.
 type Client struct {
-	token *models.Token
+	token       *models.Token
+	market      string
+	searchCache *SearchCache
+	ctx         context.Context
 }
 
 // Playlist represents a Spotify playlist.
 type Playlist struct {
-	ID     string          `json:"id"`
-	Name   string          `json:"name"`
-	Images []PlaylistImage `json:"images"`
-	Tracks TracksInfo      `json:"tracks"`
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description,omitempty"`
+	Owner         PlaylistOwner   `json:"owner"`
+	Collaborative bool            `json:"collaborative"`
+	SnapshotID    string          `json:"snapshot_id,omitempty"`
+	Images        []PlaylistImage `json:"images"`
+	Tracks        TracksInfo      `json:"tracks"`
+}
+
+// PlaylistOwner is the user who created a playlist.
+type PlaylistOwner struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
 }
 
 // PlaylistImage represents a playlist cover image.
@@ -39,6 +58,8 @@ type TracksInfo struct {
 type UserProfile struct {
 	ID          string `json:"id"`
 	DisplayName string `json:"display_name"`
+	Country     string `json:"country"`
+	Product     string `json:"product"`
 }
 
 type playlistsResponse struct {
@@ -52,20 +73,92 @@ type playlistTracksResponse struct {
 }
 
 type trackInfo struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Artists    []artist `json:"artists"`
-	PreviewURL string   `json:"preview_url"`
+	ID         string       `json:"id"`
+	URI        string       `json:"uri"`
+	Type       string       `json:"type"`
+	Name       string       `json:"name"`
+	Artists    []artistInfo `json:"artists"`
+	Album      albumInfo    `json:"album"`
+	PreviewURL string       `json:"preview_url"`
+	DurationMs int          `json:"duration_ms"`
+	Explicit   bool         `json:"explicit"`
+	IsLocal    bool         `json:"is_local"`
+	IsPlayable *bool        `json:"is_playable"`
+	LinkedFrom *struct {
+		ID string `json:"id"`
+	} `json:"linked_from"`
+	ExternalIDs struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
 }
 
-type artist struct {
+type artistInfo struct {
+	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
+type albumInfo struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Artists     []artistInfo `json:"artists"`
+	ReleaseDate string       `json:"release_date"`
+}
+
+// Artist represents a Spotify artist search result.
+type Artist struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Images []PlaylistImage `json:"images,omitempty"`
+}
+
+// Album represents a Spotify album search result.
+type Album struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Artists []string        `json:"artists"`
+	Images  []PlaylistImage `json:"images,omitempty"`
+}
+
+type albumResponse struct {
+	albumInfo
+	Images []PlaylistImage `json:"images"`
+	Tracks struct {
+		Items []trackInfo `json:"items"`
+	} `json:"tracks"`
+}
+
+type topTracksResponse struct {
+	Tracks []trackInfo `json:"tracks"`
+}
+
+type userTopTracksResponse struct {
+	Items []trackInfo `json:"items"`
+}
+
+type artistAlbumsResponse struct {
+	Items []struct {
+		ID string `json:"id"`
+	} `json:"items"`
+}
+
+type albumsResponse struct {
+	Albums []albumResponse `json:"albums"`
+}
+
+// maxAlbumsPerRequest is the number of albums Spotify returns at most from
+// one request for several albums.
+const maxAlbumsPerRequest = 20
+
 type searchResponse struct {
 	Tracks struct {
 		Items []trackInfo `json:"items"`
 	} `json:"tracks"`
+	Artists struct {
+		Items []artistInfo `json:"items"`
+	} `json:"artists"`
+	Albums struct {
+		Items []albumInfo `json:"items"`
+	} `json:"albums"`
 }
 
 type savedTracksResponse struct {
@@ -79,6 +172,35 @@ func NewClient(token *models.Token) *Client {
 	return &Client{token: token}
 }
 
+// SetMarket fetches tracks as available in a market, given as an ISO 3166-1
+// alpha-2 country code. Tracks that cannot be played there are marked
+// Unplayable, and tracks Spotify relinks to another release there keep
+// their original ID with the playable one in LinkedID.
+func (c *Client) SetMarket(market string) {
+	c.market = market
+}
+
+// withMarket adds the client's market, if it has one, to an endpoint that
+// already has query parameters.
+func (c *Client) withMarket(endpoint string) string {
+	if c.market == "" {
+		return endpoint
+	}
+	return endpoint + "&market=" + url.QueryEscape(c.market)
+}
+
+// SetContext makes the client's requests to Spotify carry ctx, so they are
+// canceled along with it and logged with the ID of the request they are made
+// for. Requests use context.Background otherwise.
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// SetSearchCache makes SearchTracks share results through cache.
+func (c *Client) SetSearchCache(cache *SearchCache) {
+	c.searchCache = cache
+}
+
 // GetUserProfile retrieves the current user's profile.
 func (c *Client) GetUserProfile() (*UserProfile, error) {
 	endpoint := apiBaseURL + "/me"
@@ -103,9 +225,166 @@ func (c *Client) GetUserPlaylists() ([]Playlist, error) {
 	return response.Items, nil
 }
 
-// GetPlaylistTracks retrieves tracks from a playlist.
+// GetPlaylist retrieves a playlist's details, which works for any public
+// playlist as well as the user's own.
+func (c *Client) GetPlaylist(playlistID string) (*Playlist, error) {
+	endpoint := fmt.Sprintf("%s/playlists/%s?fields=id,name,description,owner(id,display_name),collaborative,snapshot_id,images,tracks.total", apiBaseURL, url.PathEscape(playlistID))
+
+	var playlist Playlist
+	if err := c.makeRequest("GET", endpoint, &playlist); err != nil {
+		return nil, fmt.Errorf("getting playlist: %w", err)
+	}
+
+	return &playlist, nil
+}
+
+// GetAlbum retrieves an album's details and tracks.
+func (c *Client) GetAlbum(albumID string) (*Album, []models.Track, error) {
+	endpoint := fmt.Sprintf("%s/albums/%s", apiBaseURL, url.PathEscape(albumID))
+	if c.market != "" {
+		endpoint += "?market=" + url.QueryEscape(c.market)
+	}
+
+	var response albumResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, nil, fmt.Errorf("getting album: %w", err)
+	}
+
+	album := &Album{
+		ID:      response.ID,
+		Name:    response.Name,
+		Artists: artistNames(response.Artists),
+		Images:  response.Images,
+	}
+
+	// Album track listings leave out the album, so fill it in.
+	tracks := make([]models.Track, 0, len(response.Tracks.Items))
+	for _, item := range response.Tracks.Items {
+		if item.ID == "" {
+			continue
+		}
+		item.Album = response.albumInfo
+		tracks = append(tracks, newTrack(item))
+	}
+
+	return album, tracks, nil
+}
+
+// GetArtist retrieves an artist's details.
+func (c *Client) GetArtist(artistID string) (*Artist, error) {
+	endpoint := fmt.Sprintf("%s/artists/%s", apiBaseURL, url.PathEscape(artistID))
+
+	var artist Artist
+	if err := c.makeRequest("GET", endpoint, &artist); err != nil {
+		return nil, fmt.Errorf("getting artist: %w", err)
+	}
+
+	return &artist, nil
+}
+
+// GetArtistTopTracks retrieves an artist's most popular tracks.
+func (c *Client) GetArtistTopTracks(artistID string) ([]models.Track, error) {
+	market := c.market
+	if market == "" {
+		market = "from_token"
+	}
+	endpoint := fmt.Sprintf("%s/artists/%s/top-tracks?market=%s", apiBaseURL, url.PathEscape(artistID), url.QueryEscape(market))
+
+	var response topTracksResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("getting artist top tracks: %w", err)
+	}
+
+	tracks := make([]models.Track, 0, len(response.Tracks))
+	for _, item := range response.Tracks {
+		tracks = append(tracks, newTrack(item))
+	}
+
+	return tracks, nil
+}
+
+// GetArtistDiscography retrieves the tracks of an artist's albums and
+// singles, from the artist's latest 50 releases.
+func (c *Client) GetArtistDiscography(artistID string) ([]models.Track, error) {
+	endpoint := fmt.Sprintf("%s/artists/%s/albums?include_groups=album,single&limit=50", apiBaseURL, url.PathEscape(artistID))
+	endpoint = c.withMarket(endpoint)
+
+	var response artistAlbumsResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("getting artist albums: %w", err)
+	}
+
+	ids := make([]string, 0, len(response.Items))
+	for _, item := range response.Items {
+		ids = append(ids, item.ID)
+	}
+
+	tracks := make([]models.Track, 0)
+	for start := 0; start < len(ids); start += maxAlbumsPerRequest {
+		end := min(start+maxAlbumsPerRequest, len(ids))
+		endpoint := fmt.Sprintf("%s/albums?ids=%s", apiBaseURL, url.QueryEscape(strings.Join(ids[start:end], ",")))
+
+		var albums albumsResponse
+		if err := c.makeRequest("GET", endpoint, &albums); err != nil {
+			return nil, fmt.Errorf("getting artist albums: %w", err)
+		}
+
+		for _, album := range albums.Albums {
+			for _, item := range album.Tracks.Items {
+				if item.ID == "" {
+					continue
+				}
+				item.Album = album.albumInfo
+				tracks = append(tracks, newTrack(item))
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// GetTopTracks retrieves the current user's most played tracks over a time
+// range of short_term (about four weeks), medium_term or long_term.
+func (c *Client) GetTopTracks(timeRange string) ([]models.Track, error) {
+	endpoint := fmt.Sprintf("%s/me/top/tracks?time_range=%s&limit=50", apiBaseURL, url.QueryEscape(timeRange))
+
+	var response userTopTracksResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("getting top tracks: %w", err)
+	}
+
+	tracks := make([]models.Track, 0, len(response.Items))
+	for _, item := range response.Items {
+		tracks = append(tracks, newTrack(item))
+	}
+
+	return tracks, nil
+}
+
+// GetRecentlyPlayed retrieves the current user's recently played tracks.
+func (c *Client) GetRecentlyPlayed() ([]models.Track, error) {
+	endpoint := apiBaseURL + "/me/player/recently-played?limit=50"
+
+	var response savedTracksResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("getting recently played tracks: %w", err)
+	}
+
+	tracks := make([]models.Track, 0, len(response.Items))
+	for _, item := range response.Items {
+		if item.Track.ID == "" {
+			continue
+		}
+		tracks = append(tracks, newTrack(item.Track))
+	}
+
+	return tracks, nil
+}
+
+// GetPlaylistTracks retrieves tracks from a playlist, including any local
+// files and podcast episodes in it.
 func (c *Client) GetPlaylistTracks(playlistID string) ([]models.Track, error) {
-	endpoint := fmt.Sprintf("%s/playlists/%s/tracks?limit=100", apiBaseURL, playlistID)
+	endpoint := c.withMarket(fmt.Sprintf("%s/playlists/%s/tracks?limit=100&additional_types=track,episode", apiBaseURL, playlistID))
 
 	var response playlistTracksResponse
 	if err := c.makeRequest("GET", endpoint, &response); err != nil {
@@ -114,32 +393,45 @@ func (c *Client) GetPlaylistTracks(playlistID string) ([]models.Track, error) {
 
 	tracks := make([]models.Track, 0, len(response.Items))
 	for _, item := range response.Items {
-		if item.Track.ID == "" {
+		track := newTrack(item.Track)
+		if track.ID == "" {
 			continue
 		}
+		tracks = append(tracks, track)
+	}
 
-		artists := make([]string, 0)
-		if len(item.Track.Artists) > 0 {
-			artists = make([]string, len(item.Track.Artists))
-			for i, artist := range item.Track.Artists {
-				artists[i] = artist.Name
-			}
-		}
+	return tracks, nil
+}
 
-		tracks = append(tracks, models.Track{
-			ID:         item.Track.ID,
-			Name:       item.Track.Name,
-			Artists:    artists,
-			PreviewURL: item.Track.PreviewURL,
-		})
+// GetTrack retrieves a track by ID.
+func (c *Client) GetTrack(trackID string) (models.Track, error) {
+	endpoint := fmt.Sprintf("%s/tracks/%s", apiBaseURL, url.PathEscape(trackID))
+	if c.market != "" {
+		endpoint += "?market=" + url.QueryEscape(c.market)
 	}
 
-	return tracks, nil
+	var info trackInfo
+	if err := c.makeRequest("GET", endpoint, &info); err != nil {
+		return models.Track{}, fmt.Errorf("getting track: %w", err)
+	}
+
+	return newTrack(info), nil
 }
 
-// SearchTracks searches for tracks by query.
+// SearchTracks searches for tracks by query, through the search cache if the
+// client has one.
 func (c *Client) SearchTracks(query string) ([]models.Track, error) {
-	endpoint := fmt.Sprintf("%s/search?q=%s&type=track&limit=20", apiBaseURL, url.QueryEscape(query))
+	if c.searchCache == nil {
+		return c.searchTracks(query)
+	}
+	return c.searchCache.get(searchCacheKey(query, c.market), func() ([]models.Track, error) {
+		return c.searchTracks(query)
+	})
+}
+
+func (c *Client) searchTracks(query string) ([]models.Track, error) {
+	endpoint := fmt.Sprintf("%s/search?q=%s&type=track&limit=%d", apiBaseURL, url.QueryEscape(query), SearchLimit)
+	endpoint = c.withMarket(endpoint)
 
 	var response searchResponse
 	if err := c.makeRequest("GET", endpoint, &response); err != nil {
@@ -148,28 +440,68 @@ func (c *Client) SearchTracks(query string) ([]models.Track, error) {
 
 	tracks := make([]models.Track, 0, len(response.Tracks.Items))
 	for _, item := range response.Tracks.Items {
-		artists := make([]string, 0)
-		if len(item.Artists) > 0 {
-			artists = make([]string, len(item.Artists))
-			for i, artist := range item.Artists {
-				artists[i] = artist.Name
-			}
-		}
+		tracks = append(tracks, newTrack(item))
+	}
+
+	return tracks, nil
+}
+
+// SearchArtists searches for artists by query.
+func (c *Client) SearchArtists(query string) ([]Artist, error) {
+	endpoint := fmt.Sprintf("%s/search?q=%s&type=artist&limit=%d", apiBaseURL, url.QueryEscape(query), SearchLimit)
 
-		tracks = append(tracks, models.Track{
-			ID:         item.ID,
-			Name:       item.Name,
-			Artists:    artists,
-			PreviewURL: item.PreviewURL,
+	var response searchResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("searching artists: %w", err)
+	}
+
+	artists := make([]Artist, 0, len(response.Artists.Items))
+	for _, item := range response.Artists.Items {
+		artists = append(artists, Artist{ID: item.ID, Name: item.Name})
+	}
+
+	return artists, nil
+}
+
+// SearchAlbums searches for albums by query.
+func (c *Client) SearchAlbums(query string) ([]Album, error) {
+	endpoint := fmt.Sprintf("%s/search?q=%s&type=album&limit=%d", apiBaseURL, url.QueryEscape(query), SearchLimit)
+
+	var response searchResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return nil, fmt.Errorf("searching albums: %w", err)
+	}
+
+	albums := make([]Album, 0, len(response.Albums.Items))
+	for _, item := range response.Albums.Items {
+		albums = append(albums, Album{
+			ID:      item.ID,
+			Name:    item.Name,
+			Artists: artistNames(item.Artists),
 		})
 	}
 
-	return tracks, nil
+	return albums, nil
+}
+
+// GetLikedSongsTotal retrieves the number of tracks the current user has
+// saved, without fetching them.
+func (c *Client) GetLikedSongsTotal() (int, error) {
+	endpoint := apiBaseURL + "/me/tracks?limit=1"
+
+	var response struct {
+		Total int `json:"total"`
+	}
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return 0, fmt.Errorf("getting liked songs total: %w", err)
+	}
+
+	return response.Total, nil
 }
 
 // GetLikedSongs retrieves the current user's liked/saved tracks.
 func (c *Client) GetLikedSongs() ([]models.Track, error) {
-	endpoint := apiBaseURL + "/me/tracks?limit=50"
+	endpoint := c.withMarket(apiBaseURL + "/me/tracks?limit=50")
 
 	var response savedTracksResponse
 	if err := c.makeRequest("GET", endpoint, &response); err != nil {
@@ -181,75 +513,129 @@ func (c *Client) GetLikedSongs() ([]models.Track, error) {
 		if item.Track.ID == "" {
 			continue
 		}
-
-		var artists []string
-		if len(item.Track.Artists) > 0 {
-			artists = make([]string, len(item.Track.Artists))
-			for i, artist := range item.Track.Artists {
-				artists[i] = artist.Name
-			}
-		}
-
-		tracks = append(tracks, models.Track{
-			ID:         item.Track.ID,
-			Name:       item.Track.Name,
-			Artists:    artists,
-			PreviewURL: item.Track.PreviewURL,
-		})
+		tracks = append(tracks, newTrack(item.Track))
 	}
 
 	return tracks, nil
 }
 
-// GetMultiplePlaylistsTracks retrieves tracks from multiple playlists and combines them.
+// GetMultiplePlaylistsTracks retrieves tracks from multiple sources and
+// combines them. Each ID is a source reference as parsed by ParseSourceRef,
+// such as a playlist ID, "album:ID" or "liked".
 func (c *Client) GetMultiplePlaylistsTracks(playlistIDs []string) ([]models.Track, error) {
-	allTracks := make([]models.Track, 0)
-	trackSeen := make(map[string]bool)
+	combined, err := c.CombineSources(playlistIDs)
+	if err != nil {
+		return nil, err
+	}
+	return combined.Tracks, nil
+}
+
+// CombinedTracks are the tracks of several sources, each included once.
+type CombinedTracks struct {
+	Tracks []models.Track
+	// Duplicates counts the tracks left out because an earlier source, or
+	// the same one, already had them.
+	Duplicates int
+}
 
-	for _, playlistID := range playlistIDs {
-		var tracks []models.Track
-		var err error
+// CombineSources retrieves tracks from multiple sources like
+// GetMultiplePlaylistsTracks, and also reports how many duplicates were
+// removed.
+func (c *Client) CombineSources(sourceIDs []string) (CombinedTracks, error) {
+	combined := CombinedTracks{Tracks: make([]models.Track, 0)}
+	trackSeen := make(map[string]bool)
 
-		// Special case for liked songs
-		if playlistID == "liked_songs" {
-			tracks, err = c.GetLikedSongs()
-		} else {
-			tracks, err = c.GetPlaylistTracks(playlistID)
+	for _, sourceID := range sourceIDs {
+		ref, err := ParseSourceRef(sourceID)
+		if err != nil {
+			return CombinedTracks{}, err
 		}
 
+		tracks, err := c.GetSourceTracks(ref)
 		if err != nil {
-			return nil, fmt.Errorf("getting tracks from playlist %s: %w", playlistID, err)
+			return CombinedTracks{}, fmt.Errorf("getting tracks from %s: %w", ref, err)
 		}
 
 		// Deduplicate tracks by ID
 		for _, track := range tracks {
-			if !trackSeen[track.ID] {
-				trackSeen[track.ID] = true
-				allTracks = append(allTracks, track)
+			if trackSeen[track.ID] {
+				combined.Duplicates++
+				continue
 			}
+			trackSeen[track.ID] = true
+			combined.Tracks = append(combined.Tracks, track)
 		}
 	}
 
-	return allTracks, nil
+	return combined, nil
+}
+
+// newTrack converts a Spotify API track object into a models.Track.
+func newTrack(info trackInfo) models.Track {
+	artistIDs := make([]string, len(info.Artists))
+	for i, artist := range info.Artists {
+		artistIDs[i] = artist.ID
+	}
+
+	// Local files have no ID, so they are identified by their URI. Tracks
+	// relinked for the market keep the ID they were requested by.
+	id, linkedID := info.ID, ""
+	if info.IsLocal {
+		id = info.URI
+	}
+	if info.LinkedFrom != nil && info.LinkedFrom.ID != "" {
+		id, linkedID = info.LinkedFrom.ID, info.ID
+	}
+
+	return models.Track{
+		ID:          id,
+		LinkedID:    linkedID,
+		Name:        info.Name,
+		Artists:     artistNames(info.Artists),
+		ArtistIDs:   artistIDs,
+		AlbumID:     info.Album.ID,
+		AlbumName:   info.Album.Name,
+		ReleaseDate: info.Album.ReleaseDate,
+		ISRC:        info.ExternalIDs.ISRC,
+		PreviewURL:  info.PreviewURL,
+		DurationMs:  info.DurationMs,
+		Explicit:    info.Explicit,
+		IsLocal:     info.IsLocal,
+		IsEpisode:   info.Type == "episode",
+		// Spotify only says whether a track is playable when a market is
+		// given.
+		Unplayable: info.IsPlayable != nil && !*info.IsPlayable,
+	}
+}
+
+func artistNames(artists []artistInfo) []string {
+	names := make([]string, len(artists))
+	for i, artist := range artists {
+		names[i] = artist.Name
+	}
+	return names
 }
 
 func (c *Client) makeRequest(method, endpoint string, result interface{}) error {
-	req, err := http.NewRequest(method, endpoint, nil)
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doLogged(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
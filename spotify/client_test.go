@@ -42,3 +42,59 @@ func TestSearchTracks(t *testing.T) {
 func TestMakeRequest(t *testing.T) {
 	t.Skip("Integration test - requires mock HTTP server")
 }
+
+func TestNewTrack(t *testing.T) {
+	var info trackInfo
+	info.ID = "abc"
+	info.Type = "track"
+	info.Name = "Heroes"
+	info.DurationMs = 371000
+	info.Explicit = true
+
+	track := newTrack(info)
+	if track.ID != "abc" || track.DurationMs != 371000 || !track.Explicit || track.IsLocal || track.IsEpisode {
+		t.Errorf("newTrack() = %+v", track)
+	}
+
+	info = trackInfo{URI: "spotify:local:Artist:Album:Song:180", IsLocal: true, Type: "track"}
+	if track := newTrack(info); track.ID != info.URI || !track.IsLocal {
+		t.Errorf("newTrack() for a local file = %+v, want its URI as ID", track)
+	}
+
+	info = trackInfo{ID: "ep1", Type: "episode"}
+	if track := newTrack(info); !track.IsEpisode {
+		t.Errorf("newTrack() for an episode = %+v, want IsEpisode", track)
+	}
+}
+
+func TestNewTrackMarket(t *testing.T) {
+	playable, unplayable := true, false
+
+	info := trackInfo{ID: "relinked", IsPlayable: &playable}
+	info.LinkedFrom = &struct {
+		ID string `json:"id"`
+	}{ID: "original"}
+	if track := newTrack(info); track.ID != "original" || track.LinkedID != "relinked" || track.Unplayable {
+		t.Errorf("newTrack() for a relinked track = %+v, want the original ID", track)
+	}
+
+	if track := newTrack(trackInfo{ID: "abc", IsPlayable: &unplayable}); !track.Unplayable {
+		t.Errorf("newTrack() for an unplayable track = %+v, want Unplayable", track)
+	}
+
+	if track := newTrack(trackInfo{ID: "abc"}); track.Unplayable || track.LinkedID != "" {
+		t.Errorf("newTrack() without a market = %+v", track)
+	}
+}
+
+func TestWithMarket(t *testing.T) {
+	client := NewClient(nil)
+	if got := client.withMarket("https://example.com/tracks?limit=50"); got != "https://example.com/tracks?limit=50" {
+		t.Errorf("withMarket() without a market = %q", got)
+	}
+
+	client.SetMarket("DE")
+	if got := client.withMarket("https://example.com/tracks?limit=50"); got != "https://example.com/tracks?limit=50&market=DE" {
+		t.Errorf("withMarket() = %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBodyBytes caps how much of an error response is read for its
+// message.
+const maxErrorBodyBytes = 4096
+
+// APIError is an error response from Spotify's Web API or accounts service.
+type APIError struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Message is Spotify's explanation of the error, or the raw response
+	// body if it could not be parsed.
+	Message string
+	// RetryAfter is how long Spotify asked to wait before trying again,
+	// set when the request was rate limited.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.Status, e.Message)
+}
+
+// newAPIError reads the error from a failed response. The Web API nests it
+// as {"error": {"status", "message"}}, while the accounts service sends
+// {"error", "error_description"}.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	apiErr := &APIError{
+		Status:  resp.StatusCode,
+		Message: strings.TrimSpace(string(body)),
+	}
+
+	var webAPI struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	var accounts struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	switch {
+	case json.Unmarshal(body, &webAPI) == nil && webAPI.Error.Message != "":
+		apiErr.Message = webAPI.Error.Message
+	case json.Unmarshal(body, &accounts) == nil && accounts.Error != "":
+		apiErr.Message = accounts.Error
+		if accounts.Description != "" {
+			apiErr.Message += ": " + accounts.Description
+		}
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
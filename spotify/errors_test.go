@@ -0,0 +1,42 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		retryAfter  string
+		wantMessage string
+		wantRetry   time.Duration
+	}{
+		{"web API", 404, `{"error": {"status": 404, "message": "Non existing id"}}`, "", "Non existing id", 0},
+		{"accounts", 400, `{"error": "invalid_grant", "error_description": "Invalid authorization code"}`, "", "invalid_grant: Invalid authorization code", 0},
+		{"rate limited", 429, `{"error": {"status": 429, "message": "API rate limit exceeded"}}`, "7", "API rate limit exceeded", 7 * time.Second},
+		{"plain text", 502, "Bad Gateway\n", "", "Bad Gateway", 0},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(tt.body)),
+		}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+
+		err := newAPIError(resp)
+		if err.Status != tt.status || err.Message != tt.wantMessage || err.RetryAfter != tt.wantRetry {
+			t.Errorf("%s: newAPIError() = %+v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LinkType is the kind of Spotify object a link points to.
+type LinkType string
+
+// Link types that can be used as a game pool.
+const (
+	LinkPlaylist LinkType = "playlist"
+	LinkAlbum    LinkType = "album"
+	LinkArtist   LinkType = "artist"
+)
+
+// Link is a reference to a Spotify playlist, album or artist.
+type Link struct {
+	Type LinkType
+	ID   string
+}
+
+// ParseLink parses a Spotify URI such as "spotify:playlist:ID" or a share URL
+// such as "https://open.spotify.com/album/ID?si=...". Localized URLs like
+// "https://open.spotify.com/intl-de/artist/ID" are accepted too.
+func ParseLink(s string) (Link, error) {
+	s = strings.TrimSpace(s)
+
+	if rest, ok := strings.CutPrefix(s, "spotify:"); ok {
+		kind, id, _ := strings.Cut(rest, ":")
+		return newLink(kind, id, s)
+	}
+
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Host != "open.spotify.com" && u.Host != "play.spotify.com") {
+		return Link{}, fmt.Errorf("not a Spotify link: %s", s)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "intl-") {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return Link{}, fmt.Errorf("not a playlist, album or artist link: %s", s)
+	}
+	return newLink(parts[0], parts[1], s)
+}
+
+func newLink(kind, id, raw string) (Link, error) {
+	switch LinkType(kind) {
+	case LinkPlaylist, LinkAlbum, LinkArtist:
+	default:
+		return Link{}, fmt.Errorf("not a playlist, album or artist link: %s", raw)
+	}
+
+	if !validID(id) {
+		return Link{}, fmt.Errorf("invalid Spotify ID in link: %s", raw)
+	}
+	return Link{Type: LinkType(kind), ID: id}, nil
+}
+
+// validID reports whether id looks like a Spotify base-62 ID.
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// SourceRef returns the source reference for the linked object's tracks.
+// Artist links select the artist's top tracks.
+func (l Link) SourceRef() SourceRef {
+	return SourceRef{Kind: SourceKind(l.Type), Arg: l.ID}
+}
+
+// PoolID returns the ID that selects the linked object's tracks in a game's
+// playlist IDs. Playlists use their plain ID, like the user's own playlists;
+// albums and artists use their source reference.
+func (l Link) PoolID() string {
+	if l.Type == LinkPlaylist {
+		return l.ID
+	}
+	return l.SourceRef().String()
+}
@@ -0,0 +1,79 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import "testing"
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Link
+	}{
+		{"spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", Link{LinkPlaylist, "37i9dQZF1DXcBWIGoYBM5M"}},
+		{"spotify:album:4aawyAB9vmqN3uQ7FjRGTy", Link{LinkAlbum, "4aawyAB9vmqN3uQ7FjRGTy"}},
+		{"https://open.spotify.com/artist/0oSGxfWSnnOXhD2fKuz2Gy", Link{LinkArtist, "0oSGxfWSnnOXhD2fKuz2Gy"}},
+		{"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc123", Link{LinkPlaylist, "37i9dQZF1DXcBWIGoYBM5M"}},
+		{"https://open.spotify.com/intl-de/album/4aawyAB9vmqN3uQ7FjRGTy", Link{LinkAlbum, "4aawyAB9vmqN3uQ7FjRGTy"}},
+		{"  open.spotify.com/artist/0oSGxfWSnnOXhD2fKuz2Gy/  ", Link{LinkArtist, "0oSGxfWSnnOXhD2fKuz2Gy"}},
+		{"https://play.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M", Link{LinkPlaylist, "37i9dQZF1DXcBWIGoYBM5M"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLink(tt.in)
+		if err != nil {
+			t.Errorf("ParseLink(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLink(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinkInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"spotify:track:6rqhFgbbKwnb9MLmUQDhG6",
+		"spotify:playlist:",
+		"spotify:album:not-an-id",
+		"https://example.com/playlist/37i9dQZF1DXcBWIGoYBM5M",
+		"https://open.spotify.com/show/37i9dQZF1DXcBWIGoYBM5M",
+		"https://open.spotify.com/playlist",
+		"https://open.spotify.com/user/someone/playlist/37i9dQZF1DXcBWIGoYBM5M",
+	}
+
+	for _, in := range tests {
+		if link, err := ParseLink(in); err == nil {
+			t.Errorf("ParseLink(%q) = %+v, want error", in, link)
+		}
+	}
+}
+
+func TestLinkPoolID(t *testing.T) {
+	tests := []struct {
+		link Link
+		want string
+	}{
+		{Link{LinkPlaylist, "abc"}, "abc"},
+		{Link{LinkAlbum, "abc"}, "album:abc"},
+		{Link{LinkArtist, "abc"}, "artist:abc"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.link.PoolID(); got != tt.want {
+			t.Errorf("%+v.PoolID() = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestLinkSourceRef(t *testing.T) {
+	for _, link := range []Link{{LinkPlaylist, "abc"}, {LinkAlbum, "abc"}, {LinkArtist, "abc"}} {
+		ref, err := ParseSourceRef(link.PoolID())
+		if err != nil {
+			t.Errorf("ParseSourceRef(%q) error = %v", link.PoolID(), err)
+			continue
+		}
+		if ref != link.SourceRef() {
+			t.Errorf("ParseSourceRef(%q) = %+v, want %+v", link.PoolID(), ref, link.SourceRef())
+		}
+	}
+}
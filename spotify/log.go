@@ -0,0 +1,57 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request that
+// Spotify requests made with it are made for, which is added to their log
+// lines.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// doLogged sends a request to Spotify and logs it with the default logger:
+// successful requests at debug level, and failed ones, which usually explain
+// an error response of our own, at warn level. The query is left out of the
+// log, since it can hold search terms.
+func doLogged(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+
+	attrs := make([]slog.Attr, 0, 7)
+	if id, ok := req.Context().Value(requestIDKey{}).(string); ok {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	attrs = append(attrs,
+		slog.String("method", req.Method),
+		slog.String("host", req.URL.Host),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", time.Since(start)),
+	)
+	switch {
+	case err != nil:
+		cause := err
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			cause = urlErr.Err
+		}
+		attrs = append(attrs, slog.String("error", cause.Error()))
+		slog.LogAttrs(req.Context(), slog.LevelWarn, "spotify request", attrs...)
+	case resp.StatusCode >= http.StatusBadRequest:
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		slog.LogAttrs(req.Context(), slog.LevelWarn, "spotify request", attrs...)
+	default:
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		slog.LogAttrs(req.Context(), slog.LevelDebug, "spotify request", attrs...)
+	}
+	return resp, err
+}
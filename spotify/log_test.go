@@ -0,0 +1,74 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDoLogged(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v1/search?q=secret", "level=DEBUG msg=\"spotify request\" method=GET"},
+		{"/v1/missing", "level=WARN msg=\"spotify request\" method=GET"},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		req, _ := http.NewRequest("GET", server.URL+tt.path, nil)
+		resp, err := doLogged(req)
+		if err != nil {
+			t.Fatalf("doLogged(%s) failed: %v", tt.path, err)
+		}
+		resp.Body.Close()
+
+		line := buf.String()
+		if !strings.Contains(line, tt.want) || !strings.Contains(line, "path="+req.URL.Path) {
+			t.Errorf("doLogged(%s) logged %q", tt.path, line)
+		}
+		if strings.Contains(line, "secret") {
+			t.Errorf("doLogged(%s) logged the query: %q", tt.path, line)
+		}
+	}
+}
+
+func TestDoLoggedRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/me", nil)
+	resp, err := doLogged(req)
+	if err != nil {
+		t.Fatalf("doLogged() failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if line := buf.String(); !strings.Contains(line, "msg=\"spotify request\" request_id=req-123") {
+		t.Errorf("doLogged() logged %q, want the request ID", line)
+	}
+}
@@ -0,0 +1,147 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import (
+	"fmt"
+	"spotify-heardle/models"
+	"strings"
+)
+
+// SourceKind is the kind of Spotify entity a game's tracks are drawn from.
+type SourceKind string
+
+// Source kinds. Playlist, album and artist sources take an ID argument; top
+// tracks take an optional time range.
+const (
+	SourcePlaylist SourceKind = "playlist"
+	SourceAlbum    SourceKind = "album"
+	SourceArtist   SourceKind = "artist"
+	SourceLiked    SourceKind = "liked"
+	SourceTop      SourceKind = "top"
+	SourceRecent   SourceKind = "recent"
+)
+
+// discography is the artist source option that selects every album and
+// single instead of the artist's top tracks, as in "artist:ID:discography".
+const discography = "discography"
+
+// defaultTimeRange is the time range of a "top" source without one.
+const defaultTimeRange = "medium_term"
+
+// SourceRef is a typed reference to a source of tracks, written as
+// "kind:arg" or just "kind", such as "playlist:ID", "album:ID", "artist:ID",
+// "artist:ID:discography", "liked", "top:short_term" or "recent".
+type SourceRef struct {
+	Kind SourceKind
+	Arg  string
+}
+
+// SourceResolver fetches the tracks of a source from its argument.
+type SourceResolver func(c *Client, arg string) ([]models.Track, error)
+
+// source is a registered source kind.
+type source struct {
+	validate func(arg string) error
+	resolve  SourceResolver
+}
+
+// sources is the registry of source kinds that can be resolved into tracks.
+var sources = map[SourceKind]source{
+	SourcePlaylist: {validate: validateID, resolve: (*Client).GetPlaylistTracks},
+	SourceAlbum: {validate: validateID, resolve: func(c *Client, id string) ([]models.Track, error) {
+		_, tracks, err := c.GetAlbum(id)
+		return tracks, err
+	}},
+	SourceArtist: {validate: validateArtistArg, resolve: resolveArtist},
+	SourceLiked: {validate: validateNoArg, resolve: func(c *Client, _ string) ([]models.Track, error) {
+		return c.GetLikedSongs()
+	}},
+	SourceTop: {validate: validateTimeRange, resolve: func(c *Client, timeRange string) ([]models.Track, error) {
+		if timeRange == "" {
+			timeRange = defaultTimeRange
+		}
+		return c.GetTopTracks(timeRange)
+	}},
+	SourceRecent: {validate: validateNoArg, resolve: func(c *Client, _ string) ([]models.Track, error) {
+		return c.GetRecentlyPlayed()
+	}},
+}
+
+// ParseSourceRef parses a source reference. A bare ID is taken as a playlist
+// and "liked_songs" as the user's liked songs, as sent by older clients.
+func ParseSourceRef(s string) (SourceRef, error) {
+	if s == "liked_songs" {
+		return SourceRef{Kind: SourceLiked}, nil
+	}
+
+	kind, arg, found := strings.Cut(s, ":")
+	if !found {
+		if _, ok := sources[SourceKind(s)]; !ok {
+			kind, arg = string(SourcePlaylist), s
+		}
+	}
+
+	src, ok := sources[SourceKind(kind)]
+	if !ok {
+		return SourceRef{}, fmt.Errorf("unknown source %q", s)
+	}
+	if err := src.validate(arg); err != nil {
+		return SourceRef{}, fmt.Errorf("invalid source %q: %w", s, err)
+	}
+	return SourceRef{Kind: SourceKind(kind), Arg: arg}, nil
+}
+
+// String returns the reference in the form ParseSourceRef accepts.
+func (r SourceRef) String() string {
+	if r.Arg == "" {
+		return string(r.Kind)
+	}
+	return string(r.Kind) + ":" + r.Arg
+}
+
+// GetSourceTracks retrieves the tracks of a source.
+func (c *Client) GetSourceTracks(ref SourceRef) ([]models.Track, error) {
+	src, ok := sources[ref.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", ref)
+	}
+	return src.resolve(c, ref.Arg)
+}
+
+func resolveArtist(c *Client, arg string) ([]models.Track, error) {
+	id, option, _ := strings.Cut(arg, ":")
+	if option == discography {
+		return c.GetArtistDiscography(id)
+	}
+	return c.GetArtistTopTracks(id)
+}
+
+func validateID(arg string) error {
+	if !validID(arg) {
+		return fmt.Errorf("missing or malformed ID")
+	}
+	return nil
+}
+
+func validateArtistArg(arg string) error {
+	id, option, found := strings.Cut(arg, ":")
+	if found && option != discography {
+		return fmt.Errorf("artist option must be %s", discography)
+	}
+	return validateID(id)
+}
+
+func validateTimeRange(arg string) error {
+	switch arg {
+	case "", "short_term", "medium_term", "long_term":
+		return nil
+	}
+	return fmt.Errorf("time range must be short_term, medium_term or long_term")
+}
+
+func validateNoArg(arg string) error {
+	if arg != "" {
+		return fmt.Errorf("takes no argument")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package spotify provides Spotify API client functionality.
+package spotify
+
+import "testing"
+
+func TestParseSourceRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SourceRef
+		str  string
+	}{
+		{"playlist:37i9dQZF1DXcBWIGoYBM5M", SourceRef{SourcePlaylist, "37i9dQZF1DXcBWIGoYBM5M"}, "playlist:37i9dQZF1DXcBWIGoYBM5M"},
+		{"37i9dQZF1DXcBWIGoYBM5M", SourceRef{SourcePlaylist, "37i9dQZF1DXcBWIGoYBM5M"}, "playlist:37i9dQZF1DXcBWIGoYBM5M"},
+		{"album:4aawyAB9vmqN3uQ7FjRGTy", SourceRef{SourceAlbum, "4aawyAB9vmqN3uQ7FjRGTy"}, "album:4aawyAB9vmqN3uQ7FjRGTy"},
+		{"artist:0oSGxfWSnnOXhD2fKuz2Gy", SourceRef{SourceArtist, "0oSGxfWSnnOXhD2fKuz2Gy"}, "artist:0oSGxfWSnnOXhD2fKuz2Gy"},
+		{"artist:0oSGxfWSnnOXhD2fKuz2Gy:discography", SourceRef{SourceArtist, "0oSGxfWSnnOXhD2fKuz2Gy:discography"}, "artist:0oSGxfWSnnOXhD2fKuz2Gy:discography"},
+		{"liked", SourceRef{SourceLiked, ""}, "liked"},
+		{"liked_songs", SourceRef{SourceLiked, ""}, "liked"},
+		{"top", SourceRef{SourceTop, ""}, "top"},
+		{"top:short_term", SourceRef{SourceTop, "short_term"}, "top:short_term"},
+		{"recent", SourceRef{SourceRecent, ""}, "recent"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSourceRef(tt.in)
+		if err != nil {
+			t.Errorf("ParseSourceRef(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSourceRef(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("ParseSourceRef(%q).String() = %q, want %q", tt.in, got.String(), tt.str)
+		}
+	}
+}
+
+func TestParseSourceRefInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"playlist:",
+		"playlist:not/an/id",
+		"album:",
+		"artist:0oSGxfWSnnOXhD2fKuz2Gy:singles",
+		"liked:extra",
+		"top:forever",
+		"recent:10",
+		"show:37i9dQZF1DXcBWIGoYBM5M",
+		"../me",
+	}
+
+	for _, in := range tests {
+		if ref, err := ParseSourceRef(in); err == nil {
+			t.Errorf("ParseSourceRef(%q) = %+v, want error", in, ref)
+		}
+	}
+}
+
+func TestGetSourceTracksUnknownKind(t *testing.T) {
+	client := NewClient(nil)
+
+	if _, err := client.GetSourceTracks(SourceRef{Kind: "show", Arg: "abc"}); err == nil {
+		t.Error("GetSourceTracks() with an unknown kind returned no error")
+	}
+}
+
+func TestGetMultiplePlaylistsTracksInvalidRef(t *testing.T) {
+	client := NewClient(nil)
+
+	if _, err := client.GetMultiplePlaylistsTracks([]string{"top:forever"}); err == nil {
+		t.Error("GetMultiplePlaylistsTracks() with an invalid reference returned no error")
+	}
+}
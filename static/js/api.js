@@ -1,6 +1,37 @@
 // API client for backend communication
 const API_BASE = '';
 
+// Thrown for failed API requests. code is stable for switching on, while
+// message is meant for people; requestId matches the error in server logs.
+class APIError extends Error {
+    constructor(status, { code, message, details, requestId } = {}) {
+        super(message || `API error: ${status}`);
+        this.name = 'APIError';
+        this.status = status;
+        this.code = code || 'internal_error';
+        this.details = details || null;
+        this.requestId = requestId || null;
+    }
+}
+
+// Returns the server's message for errors the user can do something about,
+// and fallback for the rest
+function errorMessage(err, fallback) {
+    if (!(err instanceof APIError)) {
+        return fallback;
+    }
+    switch (err.code) {
+        case 'unauthorized':
+        case 'spotify_unauthorized':
+            return 'Your session has expired. Please log in again.';
+        case 'invalid_request':
+        case 'empty_pool':
+        case 'spotify_rate_limited':
+            return err.message;
+    }
+    return fallback;
+}
+
 async function fetchAPI(endpoint, options = {}) {
     const response = await fetch(API_BASE + endpoint, {
         ...options,
@@ -12,31 +43,113 @@ async function fetchAPI(endpoint, options = {}) {
     });
 
     if (!response.ok) {
-        throw new Error(`API error: ${response.status}`);
+        let error = {};
+        try {
+            error = (await response.json()).error || {};
+        } catch (e) {
+            // Not an API error body, e.g. from a proxy
+        }
+        throw new APIError(response.status, error);
     }
 
+    if (response.status === 204) {
+        return null;
+    }
     return response.json();
 }
 
-async function getPlaylists() {
-    return fetchAPI('/api/playlists');
+// options may set owner ('me' or 'others'), minTracks and sort ('name' or 'tracks')
+async function getPlaylists(options = {}) {
+    const params = new URLSearchParams();
+    Object.entries(options).forEach(([key, value]) => {
+        if (value) {
+            params.set(key, value);
+        }
+    });
+    const query = params.toString();
+    return fetchAPI('/api/playlists' + (query ? `?${query}` : ''));
 }
 
-async function searchTracks(query) {
-    return fetchAPI(`/api/search?q=${encodeURIComponent(query)}`);
+// Resolves a pasted playlist, album or artist link into a pool to play from
+async function resolvePlaylist(url) {
+    return fetchAPI('/api/playlists/resolve', {
+        method: 'POST',
+        body: JSON.stringify({ url }),
+    });
 }
 
-async function startGame(playlistIds) {
+// With a sessionId, tracks are searched only within that game's playlists
+async function searchTracks(query, type = 'track', sessionId = null) {
+    const pool = sessionId ? `&sessionId=${encodeURIComponent(sessionId)}` : '';
+    return fetchAPI(`/api/search?q=${encodeURIComponent(query)}&type=${encodeURIComponent(type)}${pool}`);
+}
+
+// filters is null or e.g. { minYear, maxYear, excludeArtists, excludeExplicit,
+// minDurationMs, maxDurationMs, excludeLocalFiles, excludeEpisodes }
+async function startGame(playlistIds, mode = 'track', poolSearch = false, filters = null) {
     return fetchAPI('/api/game/start', {
         method: 'POST',
-        body: JSON.stringify({ playlistIds }),
+        body: JSON.stringify({ playlistIds, mode, poolSearch, filters }),
     });
 }
 
-async function submitGuess(sessionId, trackId, trackName) {
+// Counts the tracks a game with these settings would be drawn from, with a sample
+async function previewPool(playlistIds, mode = 'track', filters = null) {
+    return fetchAPI('/api/pools/preview', {
+        method: 'POST',
+        body: JSON.stringify({ playlistIds, mode, filters }),
+    });
+}
+
+// Starts a game from a saved pool, with the pool's sources and rules
+async function startPoolGame(poolId) {
+    return fetchAPI('/api/game/start', {
+        method: 'POST',
+        body: JSON.stringify({ poolId }),
+    });
+}
+
+async function getPools() {
+    return fetchAPI('/api/pools');
+}
+
+// pool is { name, playlistIds, mode, choices, poolSearch, filters }
+async function createPool(pool) {
+    return fetchAPI('/api/pools', {
+        method: 'POST',
+        body: JSON.stringify(pool),
+    });
+}
+
+async function updatePool(poolId, pool) {
+    return fetchAPI(`/api/pools/${encodeURIComponent(poolId)}`, {
+        method: 'PUT',
+        body: JSON.stringify(pool),
+    });
+}
+
+async function deletePool(poolId) {
+    return fetchAPI(`/api/pools/${encodeURIComponent(poolId)}`, { method: 'DELETE' });
+}
+
+async function sharePool(poolId) {
+    return fetchAPI(`/api/pools/${encodeURIComponent(poolId)}/share`, { method: 'POST' });
+}
+
+async function getSharedPool(shareId) {
+    return fetchAPI(`/api/pools/shared/${encodeURIComponent(shareId)}`);
+}
+
+async function copySharedPool(shareId) {
+    return fetchAPI(`/api/pools/shared/${encodeURIComponent(shareId)}/copy`, { method: 'POST' });
+}
+
+// guess holds the mode-specific fields, e.g. { trackId, trackName, artists }
+// or { artistId, artistName } or { albumId, albumName } or { year } or { choiceId }
+async function submitGuess(sessionId, guess) {
     return fetchAPI('/api/game/guess', {
         method: 'POST',
-        body: JSON.stringify({ sessionId, trackId, trackName }),
+        body: JSON.stringify({ sessionId, ...guess }),
     });
 }
 
@@ -47,6 +160,18 @@ async function skipCurrentGame(sessionId) {
     });
 }
 
+async function getGame(sessionId) {
+    return fetchAPI(`/api/game/${encodeURIComponent(sessionId)}`);
+}
+
+async function getCurrentGame() {
+    return fetchAPI('/api/game/current');
+}
+
+async function shareGame(sessionId) {
+    return fetchAPI(`/api/game/${encodeURIComponent(sessionId)}/share`, { method: 'POST' });
+}
+
 async function logout() {
     return fetchAPI('/api/logout', { method: 'POST' });
 }
@@ -54,3 +179,20 @@ async function logout() {
 async function getAccessToken() {
     return fetchAPI('/api/token');
 }
+
+async function getHistory(params = {}) {
+    const query = new URLSearchParams(params).toString();
+    return fetchAPI(`/api/history${query ? '?' + query : ''}`);
+}
+
+async function getLeaderboard(params = {}) {
+    const query = new URLSearchParams(params).toString();
+    return fetchAPI(`/api/leaderboard${query ? '?' + query : ''}`);
+}
+
+async function setLeaderboardOptOut(optOut) {
+    return fetchAPI('/api/leaderboard/privacy', {
+        method: 'POST',
+        body: JSON.stringify({ optOut }),
+    });
+}
@@ -1,16 +1,61 @@
 // Game logic and state management
 let gameState = {
     sessionId: null,
+    mode: 'track',
     guessesUsed: 0,
     audioDuration: 1,
     trackUri: null,
+    playback: 'sdk',
+    clipUrl: null,
     isComplete: false,
+    choices: [],
+    solved: 0,
+    deadline: null,
+    poolSearch: false,
+    filters: null,
 };
 
+// Games drawn from fewer songs than this get a warning that the filters
+// leave little variety.
+const SMALL_POOL_SIZE = 10;
+
+let timerInterval = null;
+
 document.addEventListener('DOMContentLoaded', async () => {
     const urlParams = new URLSearchParams(window.location.search);
+    const sessionParam = urlParams.get('session');
     const playlistsParam = urlParams.get('playlists');
     const legacyPlaylistId = urlParams.get('playlist');
+    const poolParam = urlParams.get('poolId');
+    gameState.mode = urlParams.get('mode') || 'track';
+    gameState.poolSearch = urlParams.get('poolSearch') === '1';
+    try {
+        gameState.filters = JSON.parse(urlParams.get('filters'));
+    } catch (e) {
+        showError('Invalid filters parameter');
+        return;
+    }
+
+    // Resume a game that was in progress before the page was reloaded
+    if (sessionParam || (!playlistsParam && !legacyPlaylistId && !poolParam)) {
+        showLoadingMessage('Resuming game...');
+        if (await resumeGame(sessionParam)) {
+            initSearch();
+            return;
+        }
+        if (!playlistsParam && !legacyPlaylistId && !poolParam) {
+            showError('No playlist selected');
+            return;
+        }
+    }
+
+    // Saved pools bring their own sources and rules
+    if (poolParam) {
+        showLoadingMessage('Starting game...');
+        await initializeGame(null, poolParam);
+        initSearch();
+        return;
+    }
 
     let playlistIds = [];
     
@@ -26,10 +71,6 @@ document.addEventListener('DOMContentLoaded', async () => {
     // Backward compatibility with old single playlist format
     else if (legacyPlaylistId) {
         playlistIds = [legacyPlaylistId];
-    } 
-    else {
-        showError('No playlist selected');
-        return;
     }
 
     if (!Array.isArray(playlistIds) || playlistIds.length === 0) {
@@ -37,14 +78,36 @@ document.addEventListener('DOMContentLoaded', async () => {
         return;
     }
 
-    showLoadingMessage('Initializing Spotify player...');
-    await initializeSpotifyPlayer();
-    
     showLoadingMessage('Starting game...');
     await initializeGame(playlistIds);
     initSearch();
 });
 
+function showPoolWarning(poolSize, noPreview = 0) {
+    const messages = [];
+    if (noPreview) {
+        messages.push(`${noPreview} song${noPreview === 1 ? ' was' : 's were'} left out for having no preview clip.`);
+    }
+    if (poolSize < SMALL_POOL_SIZE) {
+        messages.push(`Only ${poolSize} song${poolSize === 1 ? '' : 's'} match your playlists and filters.`);
+    }
+    if (messages.length === 0) {
+        return;
+    }
+    const warning = document.getElementById('pool-warning');
+    warning.textContent = messages.join(' ');
+    warning.style.display = 'block';
+}
+
+// Premium games stream through the Web Playback SDK, which needs setting up;
+// other accounts play preview clips in an audio element.
+async function preparePlayer() {
+    if (gameState.playback === 'sdk' && !spotifyPlayer) {
+        showLoadingMessage('Initializing Spotify player...');
+        await initializeSpotifyPlayer();
+    }
+}
+
 function showLoadingMessage(message) {
     const loading = document.getElementById('loading');
     if (loading) {
@@ -53,32 +116,140 @@ function showLoadingMessage(message) {
     }
 }
 
-async function initializeGame(playlistIds) {
+async function initializeGame(playlistIds, poolId = null) {
     const loading = document.getElementById('loading');
     const error = document.getElementById('error');
     const gameContainer = document.getElementById('game-container');
 
     try {
-        const response = await startGame(playlistIds);
+        const response = poolId
+            ? await startPoolGame(poolId)
+            : await startGame(playlistIds, gameState.mode, gameState.poolSearch, gameState.filters);
         
         gameState.sessionId = response.sessionId;
+        gameState.mode = response.mode;
         gameState.audioDuration = response.audioDuration;
         gameState.trackUri = response.trackUri;
+        gameState.playback = response.playback;
+        gameState.clipUrl = response.clipUrl;
+        gameState.choices = response.choices || [];
+        gameState.poolSearch = response.poolSearch;
+        rememberSession(response.sessionId);
+        await preparePlayer();
+        setRemainingTime(response.remainingMs);
+        showPoolWarning(response.poolSize, response.noPreview);
 
+        renderChoices([]);
         updateGameUI();
+        updateSearchPlaceholder();
 
         loading.style.display = 'none';
         gameContainer.style.display = 'block';
     } catch (err) {
         loading.style.display = 'none';
-        error.textContent = 'Failed to start game. Please try a different playlist or check your Spotify Premium subscription.';
+        error.textContent = errorMessage(err, 'Failed to start game. Please try a different playlist.');
         error.style.display = 'block';
         console.error('Error starting game:', err);
     }
 }
 
+// Restore game state from the server. Without a session ID, the user's
+// current game in progress is resumed. Returns false if there is nothing to resume.
+async function resumeGame(sessionId) {
+    const loading = document.getElementById('loading');
+    const gameContainer = document.getElementById('game-container');
+
+    let state;
+    try {
+        state = sessionId ? await getGame(sessionId) : await getCurrentGame();
+    } catch (err) {
+        return false;
+    }
+
+    gameState.sessionId = state.sessionId;
+    gameState.mode = state.mode;
+    gameState.choices = state.choices || [];
+    gameState.poolSearch = state.poolSearch;
+    gameState.playback = state.playback;
+    rememberSession(state.sessionId);
+    await preparePlayer();
+
+    applyGameState(state);
+    updateSearchPlaceholder();
+
+    loading.style.display = 'none';
+    gameContainer.style.display = 'block';
+    return true;
+}
+
+// Fetch the session again, e.g. after a deadline passed on the server
+async function refreshGame() {
+    try {
+        applyGameState(await getGame(gameState.sessionId));
+    } catch (err) {
+        console.error('Failed to refresh game:', err);
+    }
+}
+
+function applyGameState(state) {
+    gameState.guessesUsed = state.guessesUsed;
+    gameState.audioDuration = state.audioDuration;
+    gameState.trackUri = state.trackUri;
+    gameState.clipUrl = state.clipUrl;
+    gameState.isComplete = state.isComplete;
+    gameState.solved = state.solved || 0;
+
+    document.getElementById('guesses-list').innerHTML = '';
+    state.guesses.forEach(guess => addGuessToList(guess.name, guess.isCorrect, guess.yearFeedback));
+    renderChoices(state.guesses.map(guess => guess.choiceId));
+    setRemainingTime(state.remainingMs);
+    updateGameUI();
+
+    const searchInput = document.getElementById('search-input');
+    searchInput.disabled = state.isComplete;
+
+    if (state.isComplete) {
+        showResult(state.won, state.correctSong);
+    }
+}
+
+// Start the countdown for timed modes. The server reports the time left
+// rather than the deadline so the client clock doesn't need to agree with it.
+function setRemainingTime(remainingMs) {
+    clearInterval(timerInterval);
+    if (remainingMs === undefined || remainingMs === null) {
+        gameState.deadline = null;
+        return;
+    }
+
+    gameState.deadline = Date.now() + remainingMs;
+    document.getElementById('timer-info').style.display = 'block';
+    updateTimer();
+    if (!gameState.isComplete) {
+        timerInterval = setInterval(updateTimer, 250);
+    }
+}
+
+function updateTimer() {
+    const remaining = Math.max(0, gameState.deadline - Date.now());
+    document.getElementById('time-remaining').textContent = Math.ceil(remaining / 1000);
+
+    if (remaining === 0 && !gameState.isComplete) {
+        clearInterval(timerInterval);
+        refreshGame();
+    }
+}
+
+// Keep the session ID in the URL so a reload resumes this game
+function rememberSession(sessionId) {
+    const url = new URL(window.location.href);
+    url.searchParams.set('session', sessionId);
+    window.history.replaceState(null, '', url);
+}
+
 async function playAudio() {
-    if (!playerReady) {
+    const preview = gameState.playback === 'preview';
+    if (!preview && !playerReady) {
         showError('Player not ready. Please wait...');
         return;
     }
@@ -88,7 +259,11 @@ async function playAudio() {
     playBtn.textContent = '▶ Playing...';
 
     try {
-        await playTrackWithLimit(gameState.trackUri, gameState.audioDuration);
+        if (preview) {
+            await playPreviewWithLimit(gameState.clipUrl, gameState.audioDuration);
+        } else {
+            await playTrackWithLimit(gameState.trackUri, gameState.audioDuration);
+        }
         
         setTimeout(() => {
             playBtn.disabled = false;
@@ -102,7 +277,7 @@ async function playAudio() {
     }
 }
 
-async function handleGuess(trackId, trackName) {
+async function handleGuess(label, guess) {
     if (gameState.isComplete) {
         return;
     }
@@ -112,13 +287,22 @@ async function handleGuess(trackId, trackName) {
     searchInput.disabled = true;
 
     try {
-        const response = await submitGuess(gameState.sessionId, trackId, trackName);
+        const response = await submitGuess(gameState.sessionId, guess);
         
         gameState.guessesUsed = response.guessesUsed;
         gameState.audioDuration = response.audioDuration;
         gameState.isComplete = response.isComplete;
+        gameState.solved = response.solved || 0;
+        gameState.clipUrl = response.clipUrl;
+
+        addGuessToList(label, response.isCorrect, response.yearFeedback);
+
+        // In blitz mode the game moves straight on to the next song
+        if (response.correctSong && !response.isComplete) {
+            showNextSong(response.isCorrect ? 'Solved' : 'Missed', response);
+        }
 
-        addGuessToList(trackName, response.isCorrect);
+        setRemainingTime(response.remainingMs);
         updateGameUI();
 
         if (response.isComplete) {
@@ -130,19 +314,47 @@ async function handleGuess(trackId, trackName) {
     } catch (error) {
         console.error('Guess submission failed:', error);
         searchInput.disabled = false;
-        showError('Failed to submit guess');
+        if (error instanceof APIError && error.code === 'game_expired') {
+            // The guess arrived after the deadline, which counted as a miss
+            await refreshGame();
+        } else {
+            showError(errorMessage(error, 'Failed to submit guess'));
+        }
     }
 }
 
+// Move a blitz game on to the next song, announcing how the last one went.
+// The guesses made on earlier songs stay in the list.
+function showNextSong(outcome, response) {
+    gameState.trackUri = response.trackUri;
+    const song = response.correctSong;
+    document.getElementById('player-status').textContent =
+        `${outcome}: ${song.name} by ${song.artists.join(', ')}`;
+}
+
 async function skipGame() {
-    if (!confirm('Are you sure you want to skip and see the answer?')) {
+    const prompt = gameState.mode === 'blitz'
+        ? 'Skip this song and see the answer?'
+        : 'Are you sure you want to skip and see the answer?';
+    if (!confirm(prompt)) {
         return;
     }
 
     try {
         const response = await skipCurrentGame(gameState.sessionId);
-        gameState.isComplete = true;
-        showResult(false, response.correctSong);
+        gameState.isComplete = response.isComplete;
+        if (response.isComplete) {
+            showResult(response.won, response.correctSong);
+            return;
+        }
+
+        gameState.guessesUsed = response.guessesUsed;
+        gameState.audioDuration = response.audioDuration;
+        gameState.clipUrl = response.clipUrl;
+        gameState.solved = response.solved || 0;
+        showNextSong('Skipped', response);
+        setRemainingTime(response.remainingMs);
+        updateGameUI();
     } catch (error) {
         console.error('Skip failed:', error);
         showError('Failed to skip');
@@ -152,33 +364,93 @@ async function skipGame() {
 function updateGameUI() {
     document.getElementById('guesses-used').textContent = gameState.guessesUsed;
     document.getElementById('audio-duration').textContent = gameState.audioDuration;
+    document.getElementById('solved-count').textContent = gameState.solved;
+    document.getElementById('solved-info').style.display = gameState.mode === 'blitz' ? 'block' : 'none';
 
     const skipBtn = document.getElementById('skip-btn');
     const searchSection = document.getElementById('search-section');
+    const choicesSection = document.getElementById('choices-section');
     
     if (gameState.isComplete) {
         skipBtn.style.display = 'none';
         searchSection.style.display = 'none';
+        choicesSection.style.display = 'none';
     }
 }
 
-function addGuessToList(trackName, isCorrect) {
+// In multiple-choice mode, show the options as buttons instead of the search
+// box. Options that were already guessed are disabled.
+function renderChoices(guessedIds) {
+    if (gameState.choices.length === 0) {
+        return;
+    }
+
+    const choicesSection = document.getElementById('choices-section');
+    choicesSection.innerHTML = '';
+    document.getElementById('search-section').style.display = 'none';
+    choicesSection.style.display = 'block';
+
+    gameState.choices.forEach(choice => {
+        const button = document.createElement('button');
+        button.className = 'choice-btn';
+        button.disabled = guessedIds.includes(choice.id);
+        button.innerHTML = `
+            <div class="result-name">${choice.name}</div>
+            <div class="result-artist">${choice.artists.join(', ')}</div>
+        `;
+        button.onclick = () => {
+            button.disabled = true;
+            handleGuess(choice.name, { choiceId: choice.id });
+        };
+        choicesSection.appendChild(button);
+    });
+}
+
+function updateSearchPlaceholder() {
+    const placeholders = {
+        artist: 'Search for an artist...',
+        album: 'Search for an album...',
+        year: 'Enter a release year...',
+    };
+    document.getElementById('search-input').placeholder = placeholders[gameState.mode] || 'Search for a song...';
+}
+
+function addGuessToList(label, isCorrect, yearFeedback) {
     const guessesList = document.getElementById('guesses-list');
     const guessItem = document.createElement('div');
     guessItem.className = `guess-item ${isCorrect ? 'guess-correct' : 'guess-incorrect'}`;
     guessItem.innerHTML = `
-        <span>${trackName}</span>
-        <span>${isCorrect ? '✓ Correct!' : '✗ Incorrect'}</span>
+        <span>${label}</span>
+        <span>${isCorrect ? '✓ Correct!' : describeYearFeedback(yearFeedback)}</span>
     `;
     guessesList.appendChild(guessItem);
 }
 
+// Describe how close a wrong year guess was, e.g. "✗ Within 2 years ↑"
+function describeYearFeedback(feedback) {
+    if (!feedback) {
+        return '✗ Incorrect';
+    }
+
+    const proximity = {
+        within2: 'Within 2 years',
+        within5: 'Within 5 years',
+        far: 'Way off',
+    };
+    const arrow = feedback.direction === 'higher' ? '↑' : '↓';
+    return `✗ ${proximity[feedback.proximity] || 'Incorrect'} ${arrow}`;
+}
+
 function showResult(won, correctSong) {
     const modal = document.getElementById('result-modal');
     const title = document.getElementById('result-title');
     const songDiv = document.getElementById('result-song');
 
+    clearInterval(timerInterval);
     title.textContent = won ? '🎉 You Win!' : '😔 Game Over';
+    if (gameState.mode === 'blitz') {
+        title.textContent = `⏱ Time's up! You solved ${gameState.solved}`;
+    }
     
     const artists = correctSong.artists.join(', ');
     songDiv.innerHTML = `
@@ -189,6 +461,23 @@ function showResult(won, correctSong) {
     modal.style.display = 'flex';
 }
 
+async function shareResult() {
+    const shareBtn = document.getElementById('share-btn');
+
+    try {
+        const response = await shareGame(gameState.sessionId);
+        await navigator.clipboard.writeText(response.text);
+        shareBtn.textContent = 'Copied!';
+    } catch (error) {
+        console.error('Share failed:', error);
+        shareBtn.textContent = 'Share failed';
+    }
+
+    setTimeout(() => {
+        shareBtn.textContent = 'Share';
+    }, 2000);
+}
+
 function showError(message) {
     const error = document.getElementById('error');
     error.textContent = message;
@@ -104,11 +104,33 @@ async function playTrackWithLimit(trackUri, durationSeconds) {
     }, durationSeconds * 1000);
 }
 
+// Play a clip the server cut from the track's preview, for accounts without
+// Premium. No SDK player is needed.
+let previewAudio = null;
+let previewTimeout = null;
+
+async function playPreviewWithLimit(clipUrl, durationSeconds) {
+    if (!previewAudio) {
+        previewAudio = new Audio();
+    }
+    clearTimeout(previewTimeout);
+    previewAudio.src = clipUrl;
+    previewAudio.currentTime = 0;
+    await previewAudio.play();
+
+    previewTimeout = setTimeout(() => {
+        previewAudio.pause();
+    }, durationSeconds * 1000);
+}
+
 // Pause playback
 async function pausePlayback() {
     if (spotifyPlayer) {
         await spotifyPlayer.pause();
     }
+    if (previewAudio) {
+        previewAudio.pause();
+    }
 }
 
 // Update player status message
@@ -11,6 +11,10 @@ document.addEventListener('DOMContentLoaded', async () => {
         const playlists = await getPlaylists();
         
         loading.style.display = 'none';
+        document.getElementById('link-form').style.display = 'flex';
+        document.getElementById('playlist-controls').style.display = 'flex';
+        loadSavedPools();
+        showSharedPool();
 
         if (playlists.length === 0) {
             error.textContent = 'No playlists found. Please create some playlists in Spotify.';
@@ -19,54 +23,133 @@ document.addEventListener('DOMContentLoaded', async () => {
         }
 
         playlists.forEach(playlist => {
-            const card = document.createElement('div');
-            card.className = 'playlist-card';
-            card.setAttribute('data-playlist-id', playlist.id);
-
-            const image = playlist.images && playlist.images.length > 0
-                ? playlist.images[0].url
-                : 'data:image/svg+xml,<svg xmlns="http://www.w3.org/2000/svg" width="150" height="150"><rect fill="%23ddd" width="150" height="150"/></svg>';
-
-            card.innerHTML = `
-                <div class="playlist-checkbox-container">
-                    <input type="checkbox" class="playlist-checkbox" id="playlist-${playlist.id}" data-playlist-id="${playlist.id}">
-                </div>
-                <img src="${image}" alt="${playlist.name}" class="playlist-image" onerror="this.src='data:image/svg+xml,<svg xmlns=\\'http://www.w3.org/2000/svg\\' width=\\'150\\' height=\\'150\\'><rect fill=\\'%23ddd\\' width=\\'150\\' height=\\'150\\'/></svg>'">
-                <div class="playlist-name">${playlist.name}</div>
-                <div class="playlist-tracks">${playlist.tracks.total} tracks</div>
-            `;
-
-            // Toggle selection when clicking the card
-            card.addEventListener('click', (e) => {
-                // Don't toggle if clicking directly on the checkbox
-                if (e.target.classList.contains('playlist-checkbox')) {
-                    return;
-                }
-                const checkbox = card.querySelector('.playlist-checkbox');
-                checkbox.checked = !checkbox.checked;
-                togglePlaylistSelection(playlist.id, checkbox.checked);
-            });
-
-            // Handle checkbox change
-            const checkbox = card.querySelector('.playlist-checkbox');
-            checkbox.addEventListener('change', (e) => {
-                e.stopPropagation();
-                togglePlaylistSelection(playlist.id, e.target.checked);
-            });
-
-            playlistsContainer.appendChild(card);
+            playlistsContainer.appendChild(createPlaylistCard(playlist));
         });
 
         // Show start button container
         startButtonContainer.style.display = 'block';
     } catch (err) {
         loading.style.display = 'none';
-        error.textContent = 'Failed to load playlists. Please try logging in again.';
+        error.textContent = errorMessage(err, 'Failed to load playlists. Please try logging in again.');
         error.style.display = 'block';
         console.error('Error loading playlists:', err);
     }
 });
 
+function createPlaylistCard(playlist) {
+    const card = document.createElement('div');
+    card.className = 'playlist-card';
+    card.setAttribute('data-playlist-id', playlist.id);
+
+    const image = playlist.images && playlist.images.length > 0
+        ? playlist.images[0].url
+        : 'data:image/svg+xml,<svg xmlns="http://www.w3.org/2000/svg" width="150" height="150"><rect fill="%23ddd" width="150" height="150"/></svg>';
+
+    card.innerHTML = `
+        <div class="playlist-checkbox-container">
+            <input type="checkbox" class="playlist-checkbox" id="playlist-${playlist.id}" data-playlist-id="${playlist.id}">
+        </div>
+        <img src="${image}" alt="${playlist.name}" class="playlist-image" onerror="this.src='data:image/svg+xml,<svg xmlns=\\'http://www.w3.org/2000/svg\\' width=\\'150\\' height=\\'150\\'><rect fill=\\'%23ddd\\' width=\\'150\\' height=\\'150\\'/></svg>'">
+        <div class="playlist-name">${playlist.name}</div>
+        <div class="playlist-tracks">${describePlaylist(playlist)}</div>
+    `;
+
+    // Toggle selection when clicking the card
+    card.addEventListener('click', (e) => {
+        // Don't toggle if clicking directly on the checkbox
+        if (e.target.classList.contains('playlist-checkbox')) {
+            return;
+        }
+        const checkbox = card.querySelector('.playlist-checkbox');
+        checkbox.checked = !checkbox.checked;
+        togglePlaylistSelection(playlist.id, checkbox.checked);
+    });
+
+    // Handle checkbox change
+    const checkbox = card.querySelector('.playlist-checkbox');
+    checkbox.addEventListener('change', (e) => {
+        e.stopPropagation();
+        togglePlaylistSelection(playlist.id, e.target.checked);
+    });
+
+    return card;
+}
+
+// Reloads the playlists with the chosen owner filter and sort order,
+// keeping the current selection
+async function reloadPlaylists() {
+    const container = document.getElementById('playlists');
+
+    try {
+        const playlists = await getPlaylists({
+            owner: document.getElementById('playlist-owner').value,
+            sort: document.getElementById('playlist-sort').value,
+        });
+
+        container.replaceChildren(...playlists.map(createPlaylistCard));
+        selectedPlaylists.forEach(id => {
+            const card = container.querySelector(`[data-playlist-id="${id}"]`);
+            if (card) {
+                card.classList.add('selected');
+                card.querySelector('.playlist-checkbox').checked = true;
+            }
+        });
+    } catch (err) {
+        console.error('Error reloading playlists:', err);
+    }
+}
+
+function describePlaylist(playlist) {
+    const parts = [];
+    if (playlist.tracks.total > 0) {
+        parts.push(`${playlist.tracks.total} tracks`);
+    }
+    if (playlist.collaborative) {
+        parts.push('collaborative');
+    } else if (playlist.owner && playlist.owner.display_name) {
+        parts.push(`by ${playlist.owner.display_name}`);
+    }
+    return parts.join(' · ');
+}
+
+// Adds the playlist, album or artist behind a pasted link and selects it
+async function addPlaylistFromLink(event) {
+    event.preventDefault();
+
+    const input = document.getElementById('link-input');
+    const button = document.getElementById('link-button');
+    const linkError = document.getElementById('link-error');
+    const url = input.value.trim();
+    if (!url) {
+        return;
+    }
+
+    button.disabled = true;
+    linkError.style.display = 'none';
+
+    try {
+        const playlist = await resolvePlaylist(url);
+
+        let card = document.querySelector(`[data-playlist-id="${playlist.id}"]`);
+        if (!card) {
+            card = createPlaylistCard(playlist);
+            document.getElementById('playlists').prepend(card);
+        }
+        card.querySelector('.playlist-checkbox').checked = true;
+        togglePlaylistSelection(playlist.id, true);
+
+        document.getElementById('error').style.display = 'none';
+        document.getElementById('start-button-container').style.display = 'block';
+        input.value = '';
+    } catch (err) {
+        linkError.textContent = errorMessage(err, 'Could not add that link. Check that it is a public playlist, album or artist.');
+        linkError.style.display = 'block';
+        console.error('Error resolving link:', err);
+    } finally {
+        button.disabled = false;
+    }
+}
+
 function togglePlaylistSelection(playlistId, isSelected) {
     const card = document.querySelector(`[data-playlist-id="${playlistId}"]`);
     
@@ -92,6 +175,8 @@ function updateStartButton() {
         startBtn.textContent = `Start Game with ${count} Playlist${count > 1 ? 's' : ''}`;
         startBtn.disabled = false;
     }
+    document.getElementById('save-pool-btn').disabled = count === 0;
+    document.getElementById('preview-pool-btn').disabled = count === 0;
 }
 
 function startGameWithSelected() {
@@ -101,5 +186,63 @@ function startGameWithSelected() {
     }
     
     const playlistIds = Array.from(selectedPlaylists);
-    window.location.href = `/game.html?playlists=${encodeURIComponent(JSON.stringify(playlistIds))}`;
+    const mode = document.getElementById('game-mode').value;
+    const poolSearch = document.getElementById('pool-search').checked ? '&poolSearch=1' : '';
+    const filters = readFilters();
+    const filtersParam = filters ? `&filters=${encodeURIComponent(JSON.stringify(filters))}` : '';
+    window.location.href = `/game.html?playlists=${encodeURIComponent(JSON.stringify(playlistIds))}&mode=${encodeURIComponent(mode)}${poolSearch}${filtersParam}`;
+}
+
+// Shows how many songs the selection leaves after filtering, and a few of them
+async function previewSelectedPool() {
+    const preview = document.getElementById('pool-preview');
+    preview.textContent = 'Loading preview...';
+    preview.style.display = 'block';
+
+    try {
+        const stats = await previewPool(Array.from(selectedPlaylists), document.getElementById('game-mode').value, readFilters());
+
+        const summary = document.createElement('p');
+        const details = [
+            stats.duplicatesRemoved && `${stats.duplicatesRemoved} duplicates`,
+            stats.unavailableInMarket && `${stats.unavailableInMarket} unavailable in your country`,
+            stats.localFiles && `${stats.localFiles} local files`,
+            stats.filteredOut && `${stats.filteredOut} filtered out`,
+            stats.noPreview && `${stats.noPreview} without a preview clip`,
+        ].filter(Boolean);
+        summary.textContent = `${stats.playable} of ${stats.total} songs playable` +
+            (details.length ? ` (${details.join(', ')})` : '');
+
+        const sample = document.createElement('ul');
+        stats.sample.forEach(track => {
+            const item = document.createElement('li');
+            item.textContent = `${track.name} – ${track.artists.join(', ')}`;
+            sample.appendChild(item);
+        });
+
+        preview.replaceChildren(summary, sample);
+    } catch (err) {
+        preview.textContent = 'Failed to preview these playlists.';
+        console.error('Error previewing pool:', err);
+    }
+}
+
+// Reads the pool filters form, returning null when no filter is set
+function readFilters() {
+    const number = id => parseInt(document.getElementById(id).value, 10) || 0;
+    const list = id => document.getElementById(id).value.split(',').map(s => s.trim()).filter(Boolean);
+    const checked = id => document.getElementById(id).checked;
+
+    const filters = {};
+    if (number('filter-min-year')) filters.minYear = number('filter-min-year');
+    if (number('filter-max-year')) filters.maxYear = number('filter-max-year');
+    if (list('filter-include-artists').length) filters.includeArtists = list('filter-include-artists');
+    if (list('filter-exclude-artists').length) filters.excludeArtists = list('filter-exclude-artists');
+    if (number('filter-min-duration')) filters.minDurationMs = number('filter-min-duration') * 1000;
+    if (number('filter-max-duration')) filters.maxDurationMs = number('filter-max-duration') * 1000;
+    if (checked('filter-exclude-explicit')) filters.excludeExplicit = true;
+    if (checked('filter-include-local')) filters.excludeLocalFiles = false;
+    if (checked('filter-include-episodes')) filters.excludeEpisodes = false;
+
+    return Object.keys(filters).length > 0 ? filters : null;
 }
@@ -0,0 +1,130 @@
+// Saved pool logic for the playlist selection page
+async function loadSavedPools() {
+    try {
+        renderSavedPools(await getPools());
+    } catch (err) {
+        console.error('Error loading saved pools:', err);
+    }
+}
+
+function renderSavedPools(pools) {
+    const section = document.getElementById('saved-pools-section');
+    const container = document.getElementById('saved-pools');
+    container.innerHTML = '';
+    section.style.display = pools.length > 0 ? 'block' : 'none';
+
+    pools.forEach(pool => {
+        const row = document.createElement('div');
+        row.className = 'saved-pool';
+
+        const name = document.createElement('span');
+        name.className = 'saved-pool-name';
+        name.textContent = pool.name;
+
+        const details = document.createElement('span');
+        details.className = 'saved-pool-details';
+        const count = pool.playlistIds.length;
+        details.textContent = `${count} source${count > 1 ? 's' : ''} · ${pool.mode}`;
+
+        row.append(name, details,
+            poolButton('Play', 'btn-primary', () => {
+                window.location.href = `/game.html?poolId=${encodeURIComponent(pool.id)}`;
+            }),
+            poolButton('Share', 'btn-secondary', () => shareSavedPool(pool.id)),
+            poolButton('Delete', 'btn-secondary', () => deleteSavedPool(pool)));
+        container.appendChild(row);
+    });
+}
+
+function poolButton(label, className, onClick) {
+    const button = document.createElement('button');
+    button.className = className;
+    button.textContent = label;
+    button.addEventListener('click', onClick);
+    return button;
+}
+
+async function saveSelectionAsPool() {
+    if (selectedPlaylists.size === 0) {
+        alert('Please select at least one playlist');
+        return;
+    }
+
+    const name = prompt('Name this pool');
+    if (!name || !name.trim()) {
+        return;
+    }
+
+    try {
+        await createPool({
+            name,
+            playlistIds: Array.from(selectedPlaylists),
+            mode: document.getElementById('game-mode').value,
+            poolSearch: document.getElementById('pool-search').checked,
+            filters: readFilters() || {},
+        });
+        await loadSavedPools();
+    } catch (err) {
+        alert('Failed to save pool.');
+        console.error('Error saving pool:', err);
+    }
+}
+
+async function shareSavedPool(poolId) {
+    try {
+        const pool = await sharePool(poolId);
+        if (navigator.clipboard) {
+            await navigator.clipboard.writeText(pool.shareUrl);
+            alert('Share link copied to clipboard');
+        } else {
+            prompt('Share this link with your teammates', pool.shareUrl);
+        }
+    } catch (err) {
+        alert('Failed to share pool.');
+        console.error('Error sharing pool:', err);
+    }
+}
+
+async function deleteSavedPool(pool) {
+    if (!confirm(`Delete "${pool.name}"?`)) {
+        return;
+    }
+
+    try {
+        await deletePool(pool.id);
+        await loadSavedPools();
+    } catch (err) {
+        alert('Failed to delete pool.');
+        console.error('Error deleting pool:', err);
+    }
+}
+
+// Shows the pool behind a share link, as in /playlists.html?pool=SHARE_ID
+async function showSharedPool() {
+    const shareId = new URLSearchParams(window.location.search).get('pool');
+    if (!shareId) {
+        return;
+    }
+
+    try {
+        const pool = await getSharedPool(shareId);
+        const owner = pool.owner ? `${pool.owner} shared` : 'Shared pool:';
+        document.getElementById('shared-pool-text').textContent = `${owner} "${pool.name}"`;
+        document.getElementById('shared-pool').style.display = 'flex';
+    } catch (err) {
+        console.error('Error loading shared pool:', err);
+    }
+}
+
+async function saveSharedPool() {
+    const shareId = new URLSearchParams(window.location.search).get('pool');
+
+    try {
+        await copySharedPool(shareId);
+        document.getElementById('shared-pool').style.display = 'none';
+        await loadSavedPools();
+    } catch (err) {
+        alert('Failed to save pool.');
+        console.error('Error copying shared pool:', err);
+    }
+}
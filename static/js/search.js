@@ -9,6 +9,11 @@ function initSearch() {
     searchInput.addEventListener('input', (e) => {
         const query = e.target.value.trim();
 
+        // Year guesses are typed in directly rather than searched for
+        if (gameState.mode === 'year') {
+            return;
+        }
+
         if (query.length < 2) {
             searchResults.innerHTML = '';
             searchResults.style.display = 'none';
@@ -19,6 +24,19 @@ function initSearch() {
         searchTimeout = setTimeout(() => performSearch(query), 300);
     });
 
+    searchInput.addEventListener('keydown', (e) => {
+        if (e.key !== 'Enter' || gameState.mode !== 'year') {
+            return;
+        }
+
+        const year = parseInt(searchInput.value.trim(), 10);
+        if (!Number.isInteger(year) || year <= 0) {
+            showError('Enter a valid year');
+            return;
+        }
+        handleGuess(String(year), { year });
+    });
+
     searchInput.addEventListener('focus', () => {
         if (currentSearchResults.length > 0) {
             searchResults.style.display = 'block';
@@ -36,7 +54,7 @@ async function performSearch(query) {
     const searchResults = document.getElementById('search-results');
     
     try {
-        const tracks = await searchTracks(query);
+        const tracks = await searchTracks(query, gameState.mode, gameState.poolSearch ? gameState.sessionId : null);
         console.log('Search results:', tracks);
         currentSearchResults = tracks;
         
@@ -56,11 +74,15 @@ async function performSearch(query) {
 
             const artists = track.artists && track.artists.length > 0 
                 ? track.artists.join(', ') 
-                : 'Unknown Artist';
+                : (gameState.mode === 'artist' ? '' : 'Unknown Artist');
             
+            const versions = track.alternates && track.alternates.length > 0
+                ? ` · ${track.alternates.length + 1} versions`
+                : '';
+
             item.innerHTML = `
-                <div class="result-name">${track.name || 'Unknown Track'}</div>
-                <div class="result-artist">${artists}</div>
+                <div class="result-name">${track.name || 'Unknown'}</div>
+                <div class="result-artist">${artists}${versions}</div>
             `;
 
             searchResults.appendChild(item);
@@ -74,9 +96,18 @@ async function performSearch(query) {
     }
 }
 
-function selectTrack(track) {
+function selectTrack(result) {
     const searchResults = document.getElementById('search-results');
     searchResults.style.display = 'none';
-    
-    handleGuess(track.id, track.name);
+
+    switch (gameState.mode) {
+        case 'artist':
+            handleGuess(result.name, { artistId: result.id, artistName: result.name });
+            break;
+        case 'album':
+            handleGuess(result.name, { albumId: result.id, albumName: result.name, artists: result.artists });
+            break;
+        default:
+            handleGuess(result.name, { trackId: result.id, trackName: result.name, artists: result.artists });
+    }
 }
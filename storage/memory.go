@@ -2,23 +2,40 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"spotify-heardle/models"
 	"sync"
 )
 
-// MemoryStore implements in-memory storage.
+// ErrNotFound is returned, wrapped, when the user, session or pool looked up
+// does not exist.
+var ErrNotFound = errors.New("not found")
+
+// MemoryStore implements in-memory storage. Game sessions are copied in and
+// out, so a session returned by the store is the caller's own; changes are
+// only seen by others once saved with SaveSession or made with UpdateSession.
 type MemoryStore struct {
-	users    map[string]*models.User
-	sessions map[string]*models.GameSession
-	mu       sync.RWMutex
+	users        map[string]*models.User
+	sessions     map[string]*models.GameSession
+	userSessions map[string][]string
+	shares       map[string]string
+	pools        map[string]*models.Pool
+	userPools    map[string][]string
+	poolShares   map[string]string
+	mu           sync.RWMutex
 }
 
 // NewMemoryStore creates a new in-memory store.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		users:    make(map[string]*models.User),
-		sessions: make(map[string]*models.GameSession),
+		users:        make(map[string]*models.User),
+		sessions:     make(map[string]*models.GameSession),
+		userSessions: make(map[string][]string),
+		shares:       make(map[string]string),
+		pools:        make(map[string]*models.Pool),
+		userPools:    make(map[string][]string),
+		poolShares:   make(map[string]string),
 	}
 }
 
@@ -36,7 +53,7 @@ func (s *MemoryStore) GetUser(userID string) (*models.User, error) {
 	defer s.mu.RUnlock()
 	user, ok := s.users[userID]
 	if !ok {
-		return nil, fmt.Errorf("user not found: %s", userID)
+		return nil, fmt.Errorf("user %s: %w", userID, ErrNotFound)
 	}
 	return user, nil
 }
@@ -45,28 +62,110 @@ func (s *MemoryStore) GetUser(userID string) (*models.User, error) {
 func (s *MemoryStore) SaveSession(session *models.GameSession) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.sessions[session.ID] = session
+	if _, ok := s.sessions[session.ID]; !ok {
+		s.userSessions[session.UserID] = append(s.userSessions[session.UserID], session.ID)
+	}
+	s.sessions[session.ID] = session.Clone()
+	if session.ShareID != "" {
+		s.shares[session.ShareID] = session.ID
+	}
 	return nil
 }
 
+// UpdateSession applies update to a stored game session while holding the
+// store's lock, and returns a copy of the session as updated. Requests racing
+// to change the same session, such as a guess and a state request noticing
+// its deadline has passed, take turns, so update should re-check whatever led
+// to the change, since another request may already have made it.
+func (s *MemoryStore) UpdateSession(sessionID string, update func(*models.GameSession)) (*models.GameSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session %s: %w", sessionID, ErrNotFound)
+	}
+	update(session)
+	if session.ShareID != "" {
+		s.shares[session.ShareID] = session.ID
+	}
+	return session.Clone(), nil
+}
+
 // GetSession retrieves a game session by ID.
 func (s *MemoryStore) GetSession(sessionID string) (*models.GameSession, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	session, ok := s.sessions[sessionID]
 	if !ok {
-		return nil, fmt.Errorf("session not found: %s", sessionID)
+		return nil, fmt.Errorf("session %s: %w", sessionID, ErrNotFound)
 	}
-	return session, nil
+	return session.Clone(), nil
 }
 
 // DeleteSession removes a game session.
 func (s *MemoryStore) DeleteSession(sessionID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.sessions[sessionID]; !ok {
-		return fmt.Errorf("session not found: %s", sessionID)
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("session %s: %w", sessionID, ErrNotFound)
 	}
 	delete(s.sessions, sessionID)
+	delete(s.shares, session.ShareID)
+
+	ids := s.userSessions[session.UserID]
+	for i, id := range ids {
+		if id == sessionID {
+			s.userSessions[session.UserID] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
+
+// GetSessionByShareID retrieves a game session by its public share ID.
+func (s *MemoryStore) GetSessionByShareID(shareID string) (*models.GameSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessionID, ok := s.shares[shareID]
+	if !ok {
+		return nil, fmt.Errorf("shared session %s: %w", shareID, ErrNotFound)
+	}
+	return s.sessions[sessionID].Clone(), nil
+}
+
+// HasCompletedTrack reports whether the user has finished a game whose answer was trackID.
+func (s *MemoryStore) HasCompletedTrack(userID, trackID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range s.userSessions[userID] {
+		session := s.sessions[id]
+		if session.IsComplete && session.CorrectSong.ID == trackID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCurrentSession retrieves the user's most recently started game that is
+// still in progress.
+func (s *MemoryStore) GetCurrentSession(userID string) (*models.GameSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var current *models.GameSession
+	for _, id := range s.userSessions[userID] {
+		session := s.sessions[id]
+		if session.IsComplete {
+			continue
+		}
+		if current == nil || !session.StartedAt.Before(current.StartedAt) {
+			current = session
+		}
+	}
+
+	if current == nil {
+		return nil, fmt.Errorf("game in progress for user %s: %w", userID, ErrNotFound)
+	}
+	return current.Clone(), nil
+}
@@ -2,8 +2,10 @@
 package storage
 
 import (
+	"errors"
 	"spotify-heardle/models"
 	"testing"
+	"time"
 )
 
 func TestNewMemoryStore(t *testing.T) {
@@ -43,8 +45,8 @@ func TestGetUserNotFound(t *testing.T) {
 	store := NewMemoryStore()
 
 	_, err := store.GetUser("nonexistent")
-	if err == nil {
-		t.Error("GetUser() succeeded for nonexistent user, want error")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUser() for nonexistent user error = %v, want %v", err, ErrNotFound)
 	}
 }
 
@@ -77,12 +79,58 @@ func TestSaveAndGetSession(t *testing.T) {
 	}
 }
 
+func TestUpdateSession(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveSession(models.NewGameSession("session123", "user123", []string{"playlist1"}, models.Track{ID: "track1"}))
+
+	updated, err := store.UpdateSession("session123", func(session *models.GameSession) {
+		session.MarkComplete(true, time.Now())
+		session.ShareID = "share1"
+	})
+	if err != nil {
+		t.Fatalf("UpdateSession() failed: %v", err)
+	}
+	if !updated.IsComplete {
+		t.Errorf("UpdateSession() returned %+v, want the updated session", updated)
+	}
+
+	session, _ := store.GetSession("session123")
+	if !session.IsComplete || !session.Won {
+		t.Errorf("session = %+v, want the update applied", session)
+	}
+	if shared, err := store.GetSessionByShareID("share1"); err != nil || shared.ID != "session123" {
+		t.Errorf("GetSessionByShareID() = %v, %v, want the updated session", shared, err)
+	}
+
+	if _, err := store.UpdateSession("missing", func(*models.GameSession) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateSession() of a missing session = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSessionsAreCopied(t *testing.T) {
+	store := NewMemoryStore()
+	session := models.NewGameSession("session123", "user123", []string{"playlist1"}, models.Track{ID: "track1"})
+	store.SaveSession(session)
+
+	session.AddGuess(models.Guess{TrackID: "wrong"}, time.Now())
+	retrieved, _ := store.GetSession("session123")
+	if retrieved.GuessesUsed != 0 {
+		t.Error("change to a saved session reached the store without saving it")
+	}
+
+	retrieved.AddGuess(models.Guess{TrackID: "wrong"}, time.Now())
+	again, _ := store.GetSession("session123")
+	if again.GuessesUsed != 0 || len(again.Guesses) != 0 {
+		t.Error("change to a retrieved session reached the store without saving it")
+	}
+}
+
 func TestGetSessionNotFound(t *testing.T) {
 	store := NewMemoryStore()
 
 	_, err := store.GetSession("nonexistent")
-	if err == nil {
-		t.Error("GetSession() succeeded for nonexistent session, want error")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetSession() for nonexistent session error = %v, want %v", err, ErrNotFound)
 	}
 }
 
@@ -138,3 +186,81 @@ func TestSaveUserOverwrites(t *testing.T) {
 		t.Errorf("DisplayName = %q, want %q", retrieved.DisplayName, "Second Name")
 	}
 }
+
+func TestGetCurrentSession(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+
+	store.SaveSession(&models.GameSession{ID: "old", UserID: "user1", StartedAt: now.Add(-2 * time.Minute)})
+	store.SaveSession(&models.GameSession{ID: "latest", UserID: "user1", StartedAt: now.Add(-1 * time.Minute)})
+	store.SaveSession(&models.GameSession{ID: "finished", UserID: "user1", StartedAt: now, IsComplete: true})
+	store.SaveSession(&models.GameSession{ID: "other", UserID: "user2", StartedAt: now})
+
+	current, err := store.GetCurrentSession("user1")
+	if err != nil {
+		t.Fatalf("GetCurrentSession() failed: %v", err)
+	}
+
+	if current.ID != "latest" {
+		t.Errorf("current.ID = %q, want %q", current.ID, "latest")
+	}
+}
+
+func TestGetCurrentSessionNone(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveSession(&models.GameSession{ID: "finished", UserID: "user1", IsComplete: true})
+
+	_, err := store.GetCurrentSession("user1")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCurrentSession() with no game in progress error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestGetCurrentSessionAfterDelete(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveSession(&models.GameSession{ID: "session1", UserID: "user1"})
+	store.DeleteSession("session1")
+
+	_, err := store.GetCurrentSession("user1")
+	if err == nil {
+		t.Error("GetCurrentSession() returned deleted session, want error")
+	}
+}
+
+func TestGetSessionByShareID(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveSession(&models.GameSession{ID: "session1", UserID: "user1", ShareID: "share1"})
+
+	session, err := store.GetSessionByShareID("share1")
+	if err != nil {
+		t.Fatalf("GetSessionByShareID() failed: %v", err)
+	}
+
+	if session.ID != "session1" {
+		t.Errorf("session.ID = %q, want %q", session.ID, "session1")
+	}
+
+	store.DeleteSession("session1")
+
+	if _, err := store.GetSessionByShareID("share1"); err == nil {
+		t.Error("GetSessionByShareID() succeeded after deletion, want error")
+	}
+}
+
+func TestHasCompletedTrack(t *testing.T) {
+	store := NewMemoryStore()
+	store.SaveSession(&models.GameSession{ID: "done", UserID: "user1", CorrectSong: models.Track{ID: "track1"}, IsComplete: true})
+	store.SaveSession(&models.GameSession{ID: "playing", UserID: "user1", CorrectSong: models.Track{ID: "track2"}})
+
+	if !store.HasCompletedTrack("user1", "track1") {
+		t.Error("HasCompletedTrack(track1) = false, want true")
+	}
+
+	if store.HasCompletedTrack("user1", "track2") {
+		t.Error("HasCompletedTrack(track2) = true, want false for game in progress")
+	}
+
+	if store.HasCompletedTrack("user2", "track1") {
+		t.Error("HasCompletedTrack() = true for another user, want false")
+	}
+}
@@ -0,0 +1,75 @@
+// Package storage provides in-memory storage for sessions and users.
+package storage
+
+import (
+	"fmt"
+	"spotify-heardle/models"
+)
+
+// SavePool stores a saved pool.
+func (s *MemoryStore) SavePool(pool *models.Pool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pools[pool.ID]; !ok {
+		s.userPools[pool.UserID] = append(s.userPools[pool.UserID], pool.ID)
+	}
+	s.pools[pool.ID] = pool
+	if pool.ShareID != "" {
+		s.poolShares[pool.ShareID] = pool.ID
+	}
+	return nil
+}
+
+// GetPool retrieves a saved pool by ID.
+func (s *MemoryStore) GetPool(poolID string) (*models.Pool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pool, ok := s.pools[poolID]
+	if !ok {
+		return nil, fmt.Errorf("pool %s: %w", poolID, ErrNotFound)
+	}
+	return pool, nil
+}
+
+// GetPoolByShareID retrieves a saved pool by its public share ID.
+func (s *MemoryStore) GetPoolByShareID(shareID string) (*models.Pool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	poolID, ok := s.poolShares[shareID]
+	if !ok {
+		return nil, fmt.Errorf("shared pool %s: %w", shareID, ErrNotFound)
+	}
+	return s.pools[poolID], nil
+}
+
+// ListPools returns the user's saved pools in the order they were created.
+func (s *MemoryStore) ListPools(userID string) []*models.Pool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pools := make([]*models.Pool, 0, len(s.userPools[userID]))
+	for _, id := range s.userPools[userID] {
+		pools = append(pools, s.pools[id])
+	}
+	return pools
+}
+
+// DeletePool removes a saved pool, and with it its share link.
+func (s *MemoryStore) DeletePool(poolID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pool, ok := s.pools[poolID]
+	if !ok {
+		return fmt.Errorf("pool %s: %w", poolID, ErrNotFound)
+	}
+	delete(s.pools, poolID)
+	delete(s.poolShares, pool.ShareID)
+
+	ids := s.userPools[pool.UserID]
+	for i, id := range ids {
+		if id == poolID {
+			s.userPools[pool.UserID] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
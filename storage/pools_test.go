@@ -0,0 +1,72 @@
+// Package storage provides in-memory storage for sessions and users.
+package storage
+
+import (
+	"spotify-heardle/models"
+	"testing"
+)
+
+func TestSaveAndListPools(t *testing.T) {
+	store := NewMemoryStore()
+	store.SavePool(models.NewPool("pool1", "user1", "Road trip", []string{"p1"}))
+	store.SavePool(models.NewPool("pool2", "user2", "Office", []string{"p2"}))
+	store.SavePool(models.NewPool("pool3", "user1", "Eighties", []string{"p3"}))
+
+	pools := store.ListPools("user1")
+	if len(pools) != 2 || pools[0].ID != "pool1" || pools[1].ID != "pool3" {
+		t.Fatalf("ListPools() = %+v, want pool1 and pool3", pools)
+	}
+
+	// Saving an existing pool updates it in place.
+	pools[0].Name = "Long drive"
+	store.SavePool(pools[0])
+	if pools := store.ListPools("user1"); len(pools) != 2 || pools[0].Name != "Long drive" {
+		t.Errorf("ListPools() after update = %+v", pools)
+	}
+
+	if pools := store.ListPools("nobody"); len(pools) != 0 {
+		t.Errorf("ListPools() for unknown user = %+v, want empty", pools)
+	}
+}
+
+func TestGetPoolByShareID(t *testing.T) {
+	store := NewMemoryStore()
+	pool := models.NewPool("pool1", "user1", "Road trip", []string{"p1"})
+	store.SavePool(pool)
+
+	if _, err := store.GetPoolByShareID("share1"); err == nil {
+		t.Error("GetPoolByShareID() succeeded before sharing, want error")
+	}
+
+	pool.ShareID = "share1"
+	store.SavePool(pool)
+
+	got, err := store.GetPoolByShareID("share1")
+	if err != nil || got.ID != "pool1" {
+		t.Errorf("GetPoolByShareID() = %v, %v, want pool1", got, err)
+	}
+}
+
+func TestDeletePool(t *testing.T) {
+	store := NewMemoryStore()
+	pool := models.NewPool("pool1", "user1", "Road trip", []string{"p1"})
+	pool.ShareID = "share1"
+	store.SavePool(pool)
+
+	if err := store.DeletePool("pool1"); err != nil {
+		t.Fatalf("DeletePool() failed: %v", err)
+	}
+
+	if _, err := store.GetPool("pool1"); err == nil {
+		t.Error("GetPool() succeeded after deletion, want error")
+	}
+	if _, err := store.GetPoolByShareID("share1"); err == nil {
+		t.Error("GetPoolByShareID() succeeded after deletion, want error")
+	}
+	if pools := store.ListPools("user1"); len(pools) != 0 {
+		t.Errorf("ListPools() after deletion = %+v, want empty", pools)
+	}
+	if err := store.DeletePool("pool1"); err == nil {
+		t.Error("DeletePool() succeeded twice, want error")
+	}
+}
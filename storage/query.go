@@ -0,0 +1,167 @@
+// Package storage provides in-memory storage for sessions and users.
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"spotify-heardle/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryLimit = 20
+	maxQueryLimit     = 100
+)
+
+// SessionQuery selects a page of a user's completed game sessions, newest first.
+type SessionQuery struct {
+	UserID     string
+	Mode       models.GameMode
+	From       time.Time
+	To         time.Time
+	PlaylistID string
+	Won        *bool
+	Cursor     string
+	Limit      int
+}
+
+// ListCompletedSessions returns the completed sessions matching the query and
+// a cursor for the next page, which is empty when there are no more results.
+func (s *MemoryStore) ListCompletedSessions(query SessionQuery) ([]*models.GameSession, string, error) {
+	var after *sessionCursor
+	if query.Cursor != "" {
+		cursor, err := decodeSessionCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &cursor
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	s.mu.RLock()
+	matches := make([]*models.GameSession, 0)
+	for _, id := range s.userSessions[query.UserID] {
+		session := s.sessions[id]
+		if session.IsComplete && query.matches(session) {
+			matches = append(matches, session.Clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return newerThan(matches[i], matches[j])
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			return after.before(matches[i])
+		})
+	}
+
+	end := start + limit
+	if end >= len(matches) {
+		return matches[start:], "", nil
+	}
+
+	page := matches[start:end]
+	return page, encodeSessionCursor(page[len(page)-1]), nil
+}
+
+// CompletedSessionsBetween returns every user's sessions completed in
+// [from, to). A zero bound leaves that side of the range open.
+func (s *MemoryStore) CompletedSessionsBetween(from, to time.Time) []*models.GameSession {
+	query := SessionQuery{From: from, To: to}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make([]*models.GameSession, 0)
+	for _, ids := range s.userSessions {
+		for _, id := range ids {
+			session := s.sessions[id]
+			if session.IsComplete && query.matches(session) {
+				sessions = append(sessions, session.Clone())
+			}
+		}
+	}
+	return sessions
+}
+
+func (q SessionQuery) matches(session *models.GameSession) bool {
+	if !q.From.IsZero() && session.CompletedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !session.CompletedAt.Before(q.To) {
+		return false
+	}
+	if q.Won != nil && session.Won != *q.Won {
+		return false
+	}
+	if q.Mode != "" && session.Mode != q.Mode {
+		return false
+	}
+	if q.PlaylistID != "" {
+		for _, id := range session.PlaylistIDs {
+			if id == q.PlaylistID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// newerThan orders sessions by completion time, newest first, breaking ties
+// by ID so pages are stable.
+func newerThan(a, b *models.GameSession) bool {
+	if !a.CompletedAt.Equal(b.CompletedAt) {
+		return a.CompletedAt.After(b.CompletedAt)
+	}
+	return a.ID > b.ID
+}
+
+// sessionCursor identifies the last session of a page.
+type sessionCursor struct {
+	completedAt time.Time
+	id          string
+}
+
+// before reports whether session sorts after the cursor position.
+func (c sessionCursor) before(session *models.GameSession) bool {
+	return newerThan(&models.GameSession{ID: c.id, CompletedAt: c.completedAt}, session)
+}
+
+func encodeSessionCursor(session *models.GameSession) string {
+	raw := strconv.FormatInt(session.CompletedAt.UnixNano(), 10) + ":" + session.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSessionCursor(cursor string) (sessionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return sessionCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return sessionCursor{}, fmt.Errorf("invalid cursor: %s", cursor)
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return sessionCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return sessionCursor{completedAt: time.Unix(0, n), id: id}, nil
+}
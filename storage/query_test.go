@@ -0,0 +1,125 @@
+// Package storage provides in-memory storage for sessions and users.
+package storage
+
+import (
+	"spotify-heardle/models"
+	"testing"
+	"time"
+)
+
+func seedCompletedSessions(store *MemoryStore, base time.Time) {
+	store.SaveSession(&models.GameSession{ID: "s1", UserID: "user1", PlaylistIDs: []string{"p1"}, IsComplete: true, Won: true, CompletedAt: base.Add(1 * time.Hour)})
+	store.SaveSession(&models.GameSession{ID: "s2", UserID: "user1", PlaylistIDs: []string{"p2"}, IsComplete: true, Won: false, CompletedAt: base.Add(2 * time.Hour)})
+	store.SaveSession(&models.GameSession{ID: "s3", UserID: "user1", PlaylistIDs: []string{"p1", "p2"}, IsComplete: true, Won: true, CompletedAt: base.Add(3 * time.Hour)})
+	store.SaveSession(&models.GameSession{ID: "s4", UserID: "user1", PlaylistIDs: []string{"p1"}, IsComplete: false})
+	store.SaveSession(&models.GameSession{ID: "s5", UserID: "user2", PlaylistIDs: []string{"p1"}, IsComplete: true, CompletedAt: base})
+}
+
+func sessionIDs(sessions []*models.GameSession) []string {
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	return ids
+}
+
+func TestListCompletedSessions(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	won := true
+
+	tests := []struct {
+		name  string
+		query SessionQuery
+		want  []string
+	}{
+		{"all", SessionQuery{UserID: "user1"}, []string{"s3", "s2", "s1"}},
+		{"playlist", SessionQuery{UserID: "user1", PlaylistID: "p2"}, []string{"s3", "s2"}},
+		{"won", SessionQuery{UserID: "user1", Won: &won}, []string{"s3", "s1"}},
+		{"date range", SessionQuery{UserID: "user1", From: base.Add(90 * time.Minute), To: base.Add(3 * time.Hour)}, []string{"s2"}},
+		{"other user", SessionQuery{UserID: "user2"}, []string{"s5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			seedCompletedSessions(store, base)
+
+			sessions, next, err := store.ListCompletedSessions(tt.query)
+			if err != nil {
+				t.Fatalf("ListCompletedSessions() failed: %v", err)
+			}
+
+			got := sessionIDs(sessions)
+			if len(got) != len(tt.want) {
+				t.Fatalf("sessions = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sessions = %v, want %v", got, tt.want)
+					break
+				}
+			}
+
+			if next != "" {
+				t.Errorf("next cursor = %q, want empty", next)
+			}
+		})
+	}
+}
+
+func TestListCompletedSessionsPagination(t *testing.T) {
+	store := NewMemoryStore()
+	seedCompletedSessions(store, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	first, cursor, err := store.ListCompletedSessions(SessionQuery{UserID: "user1", Limit: 2})
+	if err != nil {
+		t.Fatalf("ListCompletedSessions() failed: %v", err)
+	}
+
+	if got := sessionIDs(first); len(got) != 2 || got[0] != "s3" || got[1] != "s2" {
+		t.Fatalf("first page = %v, want [s3 s2]", got)
+	}
+
+	if cursor == "" {
+		t.Fatal("cursor is empty, want next page cursor")
+	}
+
+	second, cursor, err := store.ListCompletedSessions(SessionQuery{UserID: "user1", Limit: 2, Cursor: cursor})
+	if err != nil {
+		t.Fatalf("ListCompletedSessions() failed: %v", err)
+	}
+
+	if got := sessionIDs(second); len(got) != 1 || got[0] != "s1" {
+		t.Errorf("second page = %v, want [s1]", got)
+	}
+
+	if cursor != "" {
+		t.Errorf("cursor = %q, want empty on last page", cursor)
+	}
+}
+
+func TestListCompletedSessionsInvalidCursor(t *testing.T) {
+	store := NewMemoryStore()
+
+	_, _, err := store.ListCompletedSessions(SessionQuery{UserID: "user1", Cursor: "not a cursor"})
+	if err == nil {
+		t.Error("ListCompletedSessions() succeeded with invalid cursor, want error")
+	}
+}
+
+func TestCompletedSessionsBetween(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	seedCompletedSessions(store, base)
+
+	sessions := store.CompletedSessionsBetween(base, base.Add(150*time.Minute))
+
+	got := make(map[string]bool)
+	for _, session := range sessions {
+		got[session.ID] = true
+	}
+
+	if len(got) != 3 || !got["s1"] || !got["s2"] || !got["s5"] {
+		t.Errorf("sessions = %v, want s1, s2 and s5", got)
+	}
+}